@@ -0,0 +1,34 @@
+//go:build go1.18
+// +build go1.18
+
+package cli
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Argv returns argv object of ctx as *T, error returned if argv isn't a *T,
+// e.g.
+//
+//	argv, err := cli.Argv[argT](ctx)
+func Argv[T any](ctx *Context) (*T, error) {
+	argv := ctx.Argv()
+	if argv == nil {
+		return nil, argvError{isEmpty: true}
+	}
+	t, ok := argv.(*T)
+	if !ok {
+		return nil, argvError{ith: 0, msg: fmt.Sprintf("type %T isn't %v", argv, reflect.TypeOf(t))}
+	}
+	return t, nil
+}
+
+// MustArgv likes Argv but panics if argv isn't a *T
+func MustArgv[T any](ctx *Context) *T {
+	t, err := Argv[T](ctx)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericArgv(t *testing.T) {
+	type argT struct {
+		Name string `cli:"name"`
+	}
+	type otherT struct{}
+
+	ctx := &Context{argvList: []interface{}{&argT{Name: "cli"}}}
+	argv, err := Argv[argT](ctx)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "cli", argv.Name)
+	}
+	assert.Equal(t, "cli", MustArgv[argT](ctx).Name)
+
+	// mismatched type
+	_, err = Argv[otherT](ctx)
+	if assert.Error(t, err) {
+		assert.Equal(t, "0th argv: type *cli.argT isn't *cli.otherT", err.Error())
+	}
+	assert.Panics(t, func() { MustArgv[otherT](ctx) })
+
+	// empty argv
+	_, err = Argv[argT](&Context{})
+	assert.Equal(t, argvError{isEmpty: true}, err)
+}
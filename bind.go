@@ -0,0 +1,430 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MaxBodySize is the max number of bytes read from body of HTTP request
+// by Context.BindJSONBody
+var MaxBodySize int64 = 1 << 20
+
+type (
+	// BindOption customizes binding of HTTP request
+	BindOption func(*bindOptions)
+
+	bindOptions struct {
+		override bool
+		order    []BindSource
+
+		// markSet marks flags changed by JSON as set, so they win later sources
+		markSet bool
+		// noCheck skips checking values after binding, they're checked by BindHTTP
+		noCheck bool
+	}
+
+	// BindSource is a source of HTTP request bound by Context.BindHTTP
+	BindSource int
+)
+
+// Sources of HTTP request
+const (
+	BindSourceQuery  BindSource = iota // bound by BindQuery
+	BindSourceBody                     // bound by BindJSONBody
+	BindSourceHeader                   // bound by BindHeaders
+	BindSourceCookie                   // bound by BindCookies
+)
+
+// defaultBindOrder is order of sources bound by BindHTTP
+var defaultBindOrder = []BindSource{BindSourceQuery, BindSourceBody, BindSourceHeader, BindSourceCookie}
+
+// BindOverride makes values of HTTP request override values of flags
+// which are set from command line
+func BindOverride() BindOption {
+	return func(opts *bindOptions) {
+		opts.override = true
+	}
+}
+
+// BindOrder specifies order of sources bound by BindHTTP, sources absent
+// from order aren't bound
+func BindOrder(sources ...BindSource) BindOption {
+	return func(opts *bindOptions) {
+		opts.order = sources
+	}
+}
+
+func newBindOptions(opts []BindOption) *bindOptions {
+	o := &bindOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// checkBound checks values of flags after binding like values from command
+// line, see tags `choices`, `min`, `max` and `validate`
+func (ctx *Context) checkBound(options *bindOptions) error {
+	if options.noCheck {
+		return nil
+	}
+	return ctx.checkValues()
+}
+
+// BindHTTP binds all sources of HTTPRequest to argv in order, which is
+// query, JSON body, headers and cookies by default and could be specified by
+// BindOrder. Flags set from command line win, and then the first source
+// which has value of the flag, e.g. query parameter `?port=1` wins header
+// `X-Port: 2`. If BindOverride specified, later sources override earlier
+// sources and command line instead. Absent sources are skipped, and binding
+// stops at the first error. Values are checked after all sources bound.
+func (ctx *Context) BindHTTP(opts ...BindOption) error {
+	if ctx.HTTPRequest == nil {
+		return errHTTPRequestNotSet
+	}
+	options := newBindOptions(opts)
+	order := options.order
+	if order == nil {
+		order = defaultBindOrder
+	}
+	opts = append(append([]BindOption{}, opts...), func(o *bindOptions) { o.noCheck = true })
+	for _, source := range order {
+		var err error
+		switch source {
+		case BindSourceQuery:
+			err = ctx.BindQuery(opts...)
+		case BindSourceBody:
+			err = ctx.BindJSONBody(opts...)
+		case BindSourceHeader:
+			err = ctx.BindHeaders(opts...)
+		case BindSourceCookie:
+			err = ctx.BindCookies(opts...)
+		default:
+			err = fmt.Errorf("unknown bind source %d", source)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.checkBound(options)
+}
+
+// BindJSONBody unmarshals JSON body of HTTPRequest into argv. It does nothing
+// if Content-Type of request isn't JSON or body is empty. Flags set from
+// command line keep their values unless BindOverride specified.
+func (ctx *Context) BindJSONBody(opts ...BindOption) error {
+	if ctx.HTTPRequest == nil {
+		return errHTTPRequestNotSet
+	}
+	argv := ctx.Argv()
+	if argv == nil || ctx.HTTPRequest.Body == nil || !isJSONContentType(ctx.HTTPRequest.Header.Get("Content-Type")) {
+		return nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(ctx.HTTPRequest.Body, MaxBodySize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > MaxBodySize {
+		return bodyTooLargeError{limit: MaxBodySize}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	options := newBindOptions(opts)
+	options.markSet = true
+	if err := ctx.unmarshalJSON(data, argv, options); err != nil {
+		return jsonBodyError{err: err}
+	}
+	return ctx.checkBound(options)
+}
+
+// BindStdinJSON unmarshals JSON object read from stdin into argv, e.g.
+//
+//	type argT struct {
+//		Name      string `cli:"name" json:"name"`
+//		StdinJSON bool   `cli:"stdin-json" usage:"read flags from stdin as JSON" json:"-"`
+//	}
+//
+//	// echo '{"name":"x"}' | app --stdin-json
+//	if argv.StdinJSON {
+//		if err := ctx.BindStdinJSON(); err != nil {
+//			return err
+//		}
+//	}
+//
+// It reads the rest of Stdin of ctx, so input after prompts is read too, and
+// does nothing if Stdin is nil or empty. Flags set from command line keep
+// their values, slices and maps included, unless BindOverride specified.
+func (ctx *Context) BindStdinJSON(opts ...BindOption) error {
+	argv := ctx.Argv()
+	if argv == nil {
+		return nil
+	}
+	stdin := ctx.Stdin()
+	if stdin == nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	options := newBindOptions(opts)
+	if err := ctx.unmarshalJSON(data, argv, options); err != nil {
+		return stdinJSONError{err: err}
+	}
+	return ctx.checkBound(options)
+}
+
+// unmarshalJSON unmarshals data into argv, flags changed by data are
+// marked as assigned, or set if markSet specified, and flags set from
+// command line are restored unless override specified. Snapshots are deep
+// copies since json.Unmarshal reuses slices and maps of argv, and argv is
+// restored entirely if data couldn't be unmarshaled.
+func (ctx *Context) unmarshalJSON(data []byte, argv interface{}, options *bindOptions) error {
+	var flags []*flag
+	if ctx.flagSet != nil {
+		flags = ctx.flagSet.flagSlice
+	}
+	values := make([]reflect.Value, len(flags))
+	for i, fl := range flags {
+		values[i] = deepCopyValue(fl.value)
+	}
+	origin := deepCopyValue(reflect.ValueOf(argv).Elem())
+	if err := json.Unmarshal(data, argv); err != nil {
+		reflect.ValueOf(argv).Elem().Set(origin)
+		for i, fl := range flags {
+			fl.value.Set(values[i])
+		}
+		return err
+	}
+	for i, fl := range flags {
+		if fl.isSet && !options.override {
+			fl.value.Set(values[i])
+		} else if !reflect.DeepEqual(values[i].Interface(), fl.value.Interface()) {
+			fl.isSet = fl.isSet || options.markSet
+			fl.isAssigned = true
+			fl.isLoaded = ctx.flagSet.loadingDefaults
+			fl.normalizeValue()
+		}
+	}
+	return nil
+}
+
+// deepCopyValue returns a copy of v which shares no slices, maps and
+// pointers with v, unexported fields of structs are copied shallowly
+func deepCopyValue(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return c
+		}
+		c.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return c
+		}
+		c.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		for _, key := range v.MapKeys() {
+			c.SetMapIndex(key, deepCopyValue(v.MapIndex(key)))
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			return c
+		}
+		c.Set(reflect.New(v.Type().Elem()))
+		c.Elem().Set(deepCopyValue(v.Elem()))
+	case reflect.Struct:
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopyValue(v.Field(i)))
+			}
+		}
+	default:
+		c.Set(v)
+	}
+	return c
+}
+
+// BindQuery sets flags of argv by query parameters of HTTPRequest, e.g.
+// `/hello?port=8080&tag=a&tag=b` sets flags `--port` and `--tag`. Values are
+// converted the same way as command line, and flags absent from query keep
+// their default values. Flags set from command line keep their values
+// unless BindOverride specified.
+func (ctx *Context) BindQuery(opts ...BindOption) error {
+	if ctx.HTTPRequest == nil {
+		return errHTTPRequestNotSet
+	}
+	if ctx.flagSet == nil || ctx.HTTPRequest.URL == nil {
+		return nil
+	}
+	options := newBindOptions(opts)
+	if err := ctx.bindValues(ctx.HTTPRequest.URL.Query(), options); err != nil {
+		return err
+	}
+	return ctx.checkBound(options)
+}
+
+// bindValues sets flags by values, keys of values are names of flags
+// with or without leading dashes
+func (ctx *Context) bindValues(values map[string][]string, options *bindOptions) error {
+	for _, fl := range ctx.flagSet.flagSlice {
+		if fl.isSet && !options.override {
+			continue
+		}
+		var (
+			name string
+			vals []string
+		)
+		for _, n := range append(append([]string{}, fl.tag.shortNames...), fl.tag.longNames...) {
+			for _, key := range []string{n, strings.TrimLeft(n, dashOne)} {
+				if vs, ok := values[key]; ok && len(vs) > 0 {
+					if name == "" {
+						name = n
+					}
+					vals = append(vals, vs...)
+				}
+			}
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		if err := ctx.bindFlag(fl, name, vals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindFlag sets fl by vals, values replace default value of slices and
+// maps, and only the last value is used by other flags
+func (ctx *Context) bindFlag(fl *flag, name string, vals []string) error {
+	if fl.isSlice() || fl.isMap() {
+		fl.value.Set(reflect.Zero(fl.value.Type()))
+	} else {
+		vals = vals[len(vals)-1:]
+	}
+	for _, v := range vals {
+		if v == "" && fl.isBoolean() {
+			// `?verbose` likes `--verbose`
+			v = "true"
+		}
+		if err := fl.setWithNoDelay(name, v, ctx.color); err != nil {
+			return TypeConversionError{Flag: name, Value: v, Err: err, clr: ctx.color}
+		}
+	}
+	return nil
+}
+
+// BindCookies sets flags tagged by `cookie` from cookies of HTTPRequest, e.g.
+//
+//	type argT struct {
+//		Session string `cli:"session" cookie:"sid"`
+//	}
+//
+// Values are converted the same way as command line, and flags whose
+// cookies are absent keep their default values. Flags set from command
+// line keep their values unless BindOverride specified.
+func (ctx *Context) BindCookies(opts ...BindOption) error {
+	if ctx.HTTPRequest == nil {
+		return errHTTPRequestNotSet
+	}
+	if ctx.flagSet == nil {
+		return nil
+	}
+	options := newBindOptions(opts)
+	for _, fl := range ctx.flagSet.flagSlice {
+		if fl.tag.cookie == "" || (fl.isSet && !options.override) {
+			continue
+		}
+		cookie, err := ctx.HTTPRequest.Cookie(fl.tag.cookie)
+		if err != nil {
+			continue
+		}
+		if err := ctx.bindFlag(fl, fl.name(), []string{cookie.Value}); err != nil {
+			return err
+		}
+	}
+	return ctx.checkBound(options)
+}
+
+// BindHeaders sets flags tagged by `header` from headers of HTTPRequest, e.g.
+//
+//	type argT struct {
+//		RequestID string   `cli:"request-id" header:"X-Request-Id"`
+//		Accept    []string `cli:"accept" header:"Accept"`
+//	}
+//
+// Names of headers are matched case-insensitively. Multiple values of a
+// header are elements of slices or maps, and joined by tag `sep` for other
+// flags, or the last one used if `sep` absent. Values are converted the
+// same way as command line, and flags whose headers are absent keep their
+// default values. Flags set from command line keep their values unless
+// BindOverride specified.
+func (ctx *Context) BindHeaders(opts ...BindOption) error {
+	if ctx.HTTPRequest == nil {
+		return errHTTPRequestNotSet
+	}
+	if ctx.flagSet == nil {
+		return nil
+	}
+	options := newBindOptions(opts)
+	for _, fl := range ctx.flagSet.flagSlice {
+		if fl.tag.header == "" || (fl.isSet && !options.override) {
+			continue
+		}
+		vals := headerValues(ctx.HTTPRequest.Header, fl.tag.header)
+		if len(vals) == 0 {
+			continue
+		}
+		if len(vals) > 1 && fl.tag.sep != "" && !fl.isSlice() && !fl.isMap() {
+			vals = []string{strings.Join(vals, fl.tag.sep)}
+		}
+		if err := ctx.bindFlag(fl, fl.name(), vals); err != nil {
+			return err
+		}
+	}
+	return ctx.checkBound(options)
+}
+
+// headerValues returns values of header name, name is case-insensitive
+func headerValues(header map[string][]string, name string) []string {
+	var keys []string
+	for key := range header {
+		if strings.EqualFold(key, name) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var vals []string
+	for _, key := range keys {
+		vals = append(vals, header[key]...)
+	}
+	return vals
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
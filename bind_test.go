@@ -0,0 +1,430 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindT struct {
+	Name string   `cli:"name" json:"name"`
+	Port int      `cli:"port" dft:"80" json:"port"`
+	Tags []string `cli:"tag" json:"tags"`
+}
+
+func newBindTestContext(t *testing.T, args []string, argv interface{}) *Context {
+	clr := color.Color{}
+	clr.Disable()
+	ctx, err := newContext("", nil, args, []interface{}{argv}, clr)
+	require.Nil(t, err)
+	return ctx
+}
+
+func TestBindJSONBody(t *testing.T) {
+	for i, tt := range []struct {
+		args        []string
+		contentType string
+		body        string
+		opts        []BindOption
+		want        bindT
+	}{
+		{nil, "application/json", `{"name":"cli","port":8080,"tags":["a","b"]}`, nil, bindT{Name: "cli", Port: 8080, Tags: []string{"a", "b"}}},
+		{nil, "application/json; charset=utf-8", `{"name":"cli"}`, nil, bindT{Name: "cli", Port: 80}},
+		{nil, "application/vnd.api+json", `{"port":1}`, nil, bindT{Port: 1}},
+		{nil, "application/json", ``, nil, bindT{Port: 80}},
+		{nil, "application/json", " \n", nil, bindT{Port: 80}},
+		{nil, "text/plain", `{"name":"cli"}`, nil, bindT{Port: 80}},
+		{nil, "", `{"name":"cli"}`, nil, bindT{Port: 80}},
+		// flags win
+		{[]string{"--port=90", "--name=x"}, "application/json", `{"name":"cli","port":8080}`, nil, bindT{Name: "x", Port: 90}},
+		// default values don't win
+		{[]string{"--name=x"}, "application/json", `{"name":"cli","port":8080}`, nil, bindT{Name: "x", Port: 8080}},
+		// body wins
+		{[]string{"--port=90", "--name=x"}, "application/json", `{"name":"cli"}`, []BindOption{BindOverride()}, bindT{Name: "cli", Port: 90}},
+	} {
+		argv := new(bindT)
+		ctx := newBindTestContext(t, tt.args, argv)
+		ctx.HTTPRequest = httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		ctx.HTTPRequest.Header.Set("Content-Type", tt.contentType)
+		assert.Nil(t, ctx.BindJSONBody(tt.opts...), "case %d", i)
+		assert.Equal(t, tt.want, *argv, "case %d", i)
+	}
+
+	// request not set
+	ctx := newBindTestContext(t, nil, new(bindT))
+	assert.Equal(t, errHTTPRequestNotSet, ctx.BindJSONBody())
+
+	// malformed JSON
+	argv := new(bindT)
+	ctx = newBindTestContext(t, []string{"--port=90"}, argv)
+	ctx.HTTPRequest = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"cli","port":1`))
+	ctx.HTTPRequest.Header.Set("Content-Type", "application/json")
+	err := ctx.BindJSONBody()
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "malformed JSON body: "), err.Error())
+	assert.Equal(t, 90, argv.Port)
+
+	// oversized body
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	MaxBodySize = 16
+	argv = new(bindT)
+	ctx = newBindTestContext(t, nil, argv)
+	ctx.HTTPRequest = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"0123456789"}`))
+	ctx.HTTPRequest.Header.Set("Content-Type", "application/json")
+	err = ctx.BindJSONBody()
+	var tooLarge bodyTooLargeError
+	assert.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, "request body too large: more than 16 bytes", err.Error())
+	assert.Equal(t, bindT{Port: 80}, *argv)
+}
+
+func TestBindJSONBodyKeepsSliceAndMapFlags(t *testing.T) {
+	type argT struct {
+		Tags   []string          `cli:"tag" json:"tags"`
+		Labels map[string]string `cli:"label" json:"labels"`
+		Hosts  []string          `cli:"host" json:"hosts"`
+	}
+	body := `{"tags":["x","y"],"labels":{"evil":"1"},"hosts":["h1"]}`
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+
+	// flags set from command line win
+	argv := new(argT)
+	ctx := newBindTestContext(t, []string{"--tag=a", "--tag=b", "--label", "k=v"}, argv)
+	ctx.HTTPRequest = newRequest(body)
+	assert.Nil(t, ctx.BindJSONBody())
+	assert.Equal(t, []string{"a", "b"}, argv.Tags)
+	assert.Equal(t, map[string]string{"k": "v"}, argv.Labels)
+	assert.Equal(t, []string{"h1"}, argv.Hosts)
+	fl, _ := ctx.lookupFlag("--host")
+	assert.True(t, fl.isSet)
+
+	// in-place change of default value detected
+	argv = &argT{Hosts: []string{"h0"}}
+	ctx = newBindTestContext(t, nil, argv)
+	ctx.HTTPRequest = newRequest(`{"hosts":["h1"]}`)
+	assert.Nil(t, ctx.BindJSONBody())
+	assert.Equal(t, []string{"h1"}, argv.Hosts)
+	fl, _ = ctx.lookupFlag("--host")
+	assert.True(t, fl.isSet)
+
+	// restored if partially unmarshaled
+	argv = new(argT)
+	ctx = newBindTestContext(t, []string{"--tag=a", "--label", "k=v"}, argv)
+	ctx.HTTPRequest = newRequest(`{"tags":["x"],"labels":{"evil":"1"},"hosts":[1]}`)
+	assert.Error(t, ctx.BindJSONBody())
+	assert.Equal(t, argT{Tags: []string{"a"}, Labels: map[string]string{"k": "v"}}, *argv)
+}
+
+func TestBindStdinJSON(t *testing.T) {
+	for i, tt := range []struct {
+		args  []string
+		stdin string
+		opts  []BindOption
+		want  bindT
+	}{
+		{nil, `{"name":"cli","port":8080,"tags":["a","b"]}`, nil, bindT{Name: "cli", Port: 8080, Tags: []string{"a", "b"}}},
+		{nil, "", nil, bindT{Port: 80}},
+		{nil, " \n\t", nil, bindT{Port: 80}},
+		// flags win
+		{[]string{"--port=90"}, `{"name":"cli","port":8080}`, nil, bindT{Name: "cli", Port: 90}},
+		{[]string{"--port=90"}, `{"name":"cli","port":8080}`, []BindOption{BindOverride()}, bindT{Name: "cli", Port: 8080}},
+	} {
+		argv := new(bindT)
+		ctx := newBindTestContext(t, tt.args, argv)
+		ctx.reader = strings.NewReader(tt.stdin)
+		assert.Nil(t, ctx.BindStdinJSON(tt.opts...), "case %d", i)
+		assert.Equal(t, tt.want, *argv, "case %d", i)
+	}
+
+	// malformed JSON
+	argv := new(bindT)
+	ctx := newBindTestContext(t, []string{"--name=x"}, argv)
+	ctx.reader = strings.NewReader(`{"name":"cli",`)
+	err := ctx.BindStdinJSON()
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "malformed JSON from stdin: "), err.Error())
+	assert.Equal(t, bindT{Name: "x", Port: 80}, *argv)
+
+	// input after prompt, slices and maps of command line kept
+	type mapT struct {
+		Tags   []string          `cli:"tag" json:"tags"`
+		Labels map[string]string `cli:"label" json:"labels"`
+		Env    map[string]string `cli:"env" json:"env"`
+	}
+	margv := new(mapT)
+	ctx = newBindTestContext(t, []string{"--tag=a", "--label", "k=v"}, margv)
+	ctx.writer = ioutil.Discard
+	ctx.reader = strings.NewReader("bob\n" + `{"tags":["x"],"labels":{"k":"x","y":"z"},"env":{"e":"1"}}`)
+	name, err := ctx.Prompt("name")
+	require.Nil(t, err)
+	assert.Equal(t, "bob", name)
+	require.Nil(t, ctx.BindStdinJSON())
+	assert.Equal(t, mapT{Tags: []string{"a"}, Labels: map[string]string{"k": "v"}, Env: map[string]string{"e": "1"}}, *margv)
+
+	// nothing to do without stdin
+	defer func(r io.Reader) { PromptReader = r }(PromptReader)
+	PromptReader = nil
+	argv = new(bindT)
+	ctx = newBindTestContext(t, []string{"--name=x"}, argv)
+	ctx.reader = nil
+	assert.Nil(t, ctx.BindStdinJSON())
+	assert.Equal(t, bindT{Name: "x", Port: 80}, *argv)
+}
+
+func TestBindQuery(t *testing.T) {
+	type argT struct {
+		Name    string   `cli:"n,name"`
+		Port    int      `cli:"port" dft:"80"`
+		Verbose bool     `cli:"v,verbose"`
+		Tags    []string `cli:"tag" dft:"x"`
+		Ids     []int    `cli:"id"`
+	}
+	for i, tt := range []struct {
+		args  []string
+		query string
+		opts  []BindOption
+		want  argT
+	}{
+		{nil, "", nil, argT{Port: 80, Tags: []string{"x"}}},
+		{nil, "name=cli&port=8080&v=true", nil, argT{Name: "cli", Port: 8080, Verbose: true, Tags: []string{"x"}}},
+		{nil, "n=a&name=b", nil, argT{Name: "b", Port: 80, Tags: []string{"x"}}},
+		{nil, "--port=16&verbose", nil, argT{Port: 16, Verbose: true, Tags: []string{"x"}}},
+		{nil, "tag=a&tag=b&id=1&id=2&id=3", nil, argT{Port: 80, Tags: []string{"a", "b"}, Ids: []int{1, 2, 3}}},
+		{[]string{"--port=90", "--id=1"}, "port=8080&id=2", nil, argT{Port: 90, Tags: []string{"x"}, Ids: []int{1}}},
+		{[]string{"--port=90", "--id=1"}, "port=8080&id=2", []BindOption{BindOverride()}, argT{Port: 8080, Tags: []string{"x"}, Ids: []int{2}}},
+	} {
+		argv := new(argT)
+		ctx := newBindTestContext(t, tt.args, argv)
+		ctx.HTTPRequest = httptest.NewRequest("GET", "/?"+tt.query, nil)
+		assert.Nil(t, ctx.BindQuery(tt.opts...), "case %d", i)
+		assert.Equal(t, tt.want, *argv, "case %d", i)
+	}
+
+	// invalid conversions
+	for i, tt := range []struct {
+		query string
+		err   string
+	}{
+		{"port=abc", "parameter --port invalid: `abc' couldn't converted to an int"},
+		{"v=what", "parameter -v invalid: `what' couldn't converted to a bool"},
+		{"id=1&id=x", "parameter --id invalid: `x' couldn't converted to an int"},
+	} {
+		ctx := newBindTestContext(t, nil, new(argT))
+		ctx.HTTPRequest = httptest.NewRequest("GET", "/?"+tt.query, nil)
+		err := ctx.BindQuery()
+		var convErr TypeConversionError
+		if assert.True(t, errors.As(err, &convErr), "case %d", i) {
+			assert.Equal(t, tt.err, err.Error(), "case %d", i)
+		}
+	}
+}
+
+func TestBindCookies(t *testing.T) {
+	type argT struct {
+		Session string  `cli:"session" cookie:"sid"`
+		Limit   int     `cli:"limit" cookie:"limit" dft:"10"`
+		Ratio   float64 `cli:"ratio" cookie:"ratio"`
+		Name    string  `cli:"name"`
+	}
+	for i, tt := range []struct {
+		args    []string
+		cookies map[string]string
+		opts    []BindOption
+		want    argT
+	}{
+		{nil, nil, nil, argT{Limit: 10}},
+		{nil, map[string]string{"sid": "abc", "limit": "20", "ratio": "0.5"}, nil, argT{Session: "abc", Limit: 20, Ratio: 0.5}},
+		{nil, map[string]string{"sid": "abc", "name": "x", "session": "y"}, nil, argT{Session: "abc", Limit: 10}},
+		{[]string{"--limit=5"}, map[string]string{"limit": "20"}, nil, argT{Limit: 5}},
+		{[]string{"--limit=5"}, map[string]string{"limit": "20"}, []BindOption{BindOverride()}, argT{Limit: 20}},
+	} {
+		argv := new(argT)
+		ctx := newBindTestContext(t, tt.args, argv)
+		ctx.HTTPRequest = httptest.NewRequest("GET", "/", nil)
+		for name, value := range tt.cookies {
+			ctx.HTTPRequest.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		assert.Nil(t, ctx.BindCookies(tt.opts...), "case %d", i)
+		assert.Equal(t, tt.want, *argv, "case %d", i)
+	}
+
+	// invalid conversion
+	ctx := newBindTestContext(t, nil, new(argT))
+	ctx.HTTPRequest = httptest.NewRequest("GET", "/", nil)
+	ctx.HTTPRequest.AddCookie(&http.Cookie{Name: "limit", Value: "many"})
+	err := ctx.BindCookies()
+	var convErr TypeConversionError
+	if assert.True(t, errors.As(err, &convErr)) {
+		assert.Equal(t, "parameter --limit invalid: `many' couldn't converted to an int", err.Error())
+	}
+
+	// request not set
+	ctx = newBindTestContext(t, nil, new(argT))
+	assert.Equal(t, errHTTPRequestNotSet, ctx.BindCookies())
+}
+
+func TestBindHeaders(t *testing.T) {
+	type argT struct {
+		RequestID string   `cli:"request-id" header:"X-Request-Id"`
+		Retries   int      `cli:"retries" header:"x-retries" dft:"3"`
+		Accept    []string `cli:"accept" header:"Accept"`
+		Forwarded string   `cli:"forwarded" header:"X-Forwarded-For" sep:", "`
+		Via       string   `cli:"via" header:"Via"`
+	}
+	for i, tt := range []struct {
+		args   []string
+		header map[string][]string
+		opts   []BindOption
+		want   argT
+	}{
+		{nil, nil, nil, argT{Retries: 3}},
+		{nil, map[string][]string{"X-Request-Id": {"abc"}, "X-Retries": {"5"}}, nil, argT{RequestID: "abc", Retries: 5}},
+		// case-insensitive
+		{nil, map[string][]string{"x-request-id": {"abc"}, "X-RETRIES": {"5"}}, nil, argT{RequestID: "abc", Retries: 5}},
+		// multiple values
+		{nil, map[string][]string{"Accept": {"text/plain", "application/json"}}, nil, argT{Retries: 3, Accept: []string{"text/plain", "application/json"}}},
+		{nil, map[string][]string{"X-Forwarded-For": {"1.1.1.1", "2.2.2.2"}}, nil, argT{Retries: 3, Forwarded: "1.1.1.1, 2.2.2.2"}},
+		{nil, map[string][]string{"Via": {"a", "b"}}, nil, argT{Retries: 3, Via: "b"}},
+		{[]string{"--retries=1"}, map[string][]string{"X-Retries": {"5"}}, nil, argT{Retries: 1}},
+		{[]string{"--retries=1"}, map[string][]string{"X-Retries": {"5"}}, []BindOption{BindOverride()}, argT{Retries: 5}},
+	} {
+		argv := new(argT)
+		ctx := newBindTestContext(t, tt.args, argv)
+		ctx.HTTPRequest = httptest.NewRequest("GET", "/", nil)
+		for key, vals := range tt.header {
+			ctx.HTTPRequest.Header[key] = vals
+		}
+		assert.Nil(t, ctx.BindHeaders(tt.opts...), "case %d", i)
+		assert.Equal(t, tt.want, *argv, "case %d", i)
+	}
+
+	// invalid conversion
+	ctx := newBindTestContext(t, nil, new(argT))
+	ctx.HTTPRequest = httptest.NewRequest("GET", "/", nil)
+	ctx.HTTPRequest.Header.Set("X-Retries", "many")
+	err := ctx.BindHeaders()
+	var convErr TypeConversionError
+	if assert.True(t, errors.As(err, &convErr)) {
+		assert.Equal(t, "parameter --retries invalid: `many' couldn't converted to an int", err.Error())
+	}
+
+	// request not set
+	ctx = newBindTestContext(t, nil, new(argT))
+	assert.Equal(t, errHTTPRequestNotSet, ctx.BindHeaders())
+}
+
+func TestBindHTTP(t *testing.T) {
+	type argT struct {
+		Name  string `cli:"name" json:"name" header:"X-Name" cookie:"name"`
+		Port  int    `cli:"port" json:"port" header:"X-Port" dft:"80"`
+		Token string `cli:"token" json:"-" header:"X-Token" cookie:"token"`
+	}
+	for i, tt := range []struct {
+		args    []string
+		query   string
+		body    string
+		header  map[string]string
+		cookies map[string]string
+		opts    []BindOption
+		want    argT
+	}{
+		// absent sources skipped
+		{nil, "", "", nil, nil, nil, argT{Port: 80}},
+		{nil, "name=q", `{"port":8080}`, map[string]string{"X-Token": "h"}, nil, nil, argT{Name: "q", Port: 8080, Token: "h"}},
+		// query wins body, and body wins headers
+		{nil, "name=q", `{"name":"b","port":8080}`, map[string]string{"X-Name": "h", "X-Port": "90"}, nil, nil, argT{Name: "q", Port: 8080}},
+		// headers win cookies
+		{nil, "", "", map[string]string{"X-Token": "h"}, map[string]string{"token": "c", "name": "c"}, nil, argT{Name: "c", Port: 80, Token: "h"}},
+		// command line wins
+		{[]string{"--port=1"}, "port=2", `{"port":3}`, map[string]string{"X-Port": "4"}, nil, nil, argT{Port: 1}},
+		// later sources override
+		{[]string{"--port=1"}, "port=2&name=q", `{"port":3}`, map[string]string{"X-Port": "4"}, nil, []BindOption{BindOverride()}, argT{Name: "q", Port: 4}},
+		// custom order
+		{nil, "name=q", `{"name":"b"}`, map[string]string{"X-Name": "h"}, nil, []BindOption{BindOrder(BindSourceHeader, BindSourceQuery)}, argT{Name: "h", Port: 80}},
+		{nil, "name=q", `{"name":"b"}`, nil, nil, []BindOption{BindOrder(BindSourceBody)}, argT{Name: "b", Port: 80}},
+	} {
+		argv := new(argT)
+		ctx := newBindTestContext(t, tt.args, argv)
+		ctx.HTTPRequest = httptest.NewRequest("POST", "/?"+tt.query, strings.NewReader(tt.body))
+		if tt.body != "" {
+			ctx.HTTPRequest.Header.Set("Content-Type", "application/json")
+		}
+		for key, value := range tt.header {
+			ctx.HTTPRequest.Header.Set(key, value)
+		}
+		for name, value := range tt.cookies {
+			ctx.HTTPRequest.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		assert.Nil(t, ctx.BindHTTP(tt.opts...), "case %d", i)
+		assert.Equal(t, tt.want, *argv, "case %d", i)
+	}
+
+	// stops at the first error
+	argv := new(argT)
+	ctx := newBindTestContext(t, nil, argv)
+	ctx.HTTPRequest = httptest.NewRequest("POST", "/?port=x", strings.NewReader(`{"name":"b"}`))
+	ctx.HTTPRequest.Header.Set("Content-Type", "application/json")
+	err := ctx.BindHTTP()
+	var convErr TypeConversionError
+	assert.True(t, errors.As(err, &convErr))
+	assert.Equal(t, "", argv.Name)
+
+	ctx = newBindTestContext(t, nil, new(argT))
+	ctx.HTTPRequest = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":`))
+	ctx.HTTPRequest.Header.Set("Content-Type", "application/json")
+	err = ctx.BindHTTP()
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "malformed JSON body: "), err.Error())
+
+	// values checked after binding
+	type checkT struct {
+		Mode string `cli:"mode" json:"mode" choices:"prod|dev"`
+		Port int    `cli:"port" json:"port" header:"X-Port" min:"1"`
+	}
+	for i, tt := range []struct {
+		query  string
+		body   string
+		header string
+		opts   []BindOption
+		err    string
+	}{
+		{"mode=dev", `{"port":8080}`, "", nil, ""},
+		{"mode=test", "", "", nil, "parameter --mode invalid: `test' is not one of prod|dev"},
+		{"", `{"mode":"test"}`, "", nil, "parameter --mode invalid: `test' is not one of prod|dev"},
+		{"", "", "0", nil, "parameter --port invalid: `0' should be at least 1"},
+		// checked after all sources bound
+		{"port=0", "", "2", []BindOption{BindOverride()}, ""},
+	} {
+		ctx := newBindTestContext(t, nil, new(checkT))
+		ctx.HTTPRequest = httptest.NewRequest("POST", "/?"+tt.query, strings.NewReader(tt.body))
+		ctx.HTTPRequest.Header.Set("Content-Type", "application/json")
+		if tt.header != "" {
+			ctx.HTTPRequest.Header.Set("X-Port", tt.header)
+		}
+		err := ctx.BindHTTP(tt.opts...)
+		if tt.err == "" {
+			assert.Nil(t, err, "case %d", i)
+		} else if assert.Error(t, err, "case %d", i) {
+			assert.Equal(t, tt.err, err.Error(), "case %d", i)
+		}
+	}
+	ctx = newBindTestContext(t, nil, new(checkT))
+	ctx.HTTPRequest = httptest.NewRequest("GET", "/?mode=test", nil)
+	assert.Error(t, ctx.BindQuery())
+
+	// request not set
+	ctx = newBindTestContext(t, nil, new(argT))
+	assert.Equal(t, errHTTPRequestNotSet, ctx.BindHTTP())
+}
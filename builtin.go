@@ -24,6 +24,11 @@ func (h Helper2) AutoHelp() bool {
 	return h.Help
 }
 
+// Outputer is builtin output format flag used by Context.Render
+type Outputer struct {
+	Output string `cli:"o,output" usage:"output format: json|yaml|table|csv" dft:"json"`
+}
+
 // Deprecated: Addr is builtin host,port flag
 type Addr struct {
 	Host string `cli:"host" usage:"specify host" dft:"0.0.0.0"`
@@ -15,7 +15,8 @@ func Run(argv interface{}, fn CommandFunc, descs ...string) int {
 	return RunWithArgs(argv, os.Args, fn, descs...)
 }
 
-// RunWithArgs is similar to Run, but with args instead of os.Args
+// RunWithArgs is similar to Run, but with args instead of os.Args.
+// It returns exit code of error returned by fn, see ExitCodeOf
 func RunWithArgs(argv interface{}, args []string, fn CommandFunc, descs ...string) int {
 	desc := ""
 	if len(descs) > 0 {
@@ -26,17 +27,18 @@ func RunWithArgs(argv interface{}, args []string, fn CommandFunc, descs ...strin
 		Desc:        desc,
 		Argv:        func() interface{} { return argv },
 		CanSubRoute: true,
+		HelpFlag:    true,
 		Fn:          fn,
 	}).Run(args[1:])
-	if err != nil {
+	if err != nil && err.Error() != "" {
 		fmt.Fprintln(os.Stderr, err)
-		return 1
 	}
-	return 0
+	return ExitCodeOf(err)
 }
 
-// Root registers forest for root and returns root
+// Root registers forest for root and returns root, HelpFlag of root enabled
 func Root(root *Command, forest ...*CommandTree) *Command {
+	root.HelpFlag = true
 	root.RegisterTree(forest...)
 	return root
 }
@@ -60,9 +62,15 @@ func parseArgv(args []string, argv interface{}, clr color.Color) *flagSet {
 	return parseArgvList(args, []interface{}{argv}, clr)
 }
 
-func parseArgvList(args []string, argvList []interface{}, clr color.Color) *flagSet {
-	flagSet := newFlagSet()
-	for _, argv := range argvList {
+// parseArgvList parses args to argvList, persistentList contains argv
+// objects of ancestors whose persistent flags are inherited
+func parseArgvList(args []string, argvList []interface{}, clr color.Color, persistentList ...interface{}) *flagSet {
+	return parseArgvListTo(newFlagSet(), args, argvList, clr, persistentList...)
+}
+
+// parseArgvListTo is similar to parseArgvList, but parses to flagSet
+func parseArgvListTo(flagSet *flagSet, args []string, argvList []interface{}, clr color.Color, persistentList ...interface{}) *flagSet {
+	for i, argv := range append(append([]interface{}{}, argvList...), persistentList...) {
 		if argv == nil {
 			continue
 		}
@@ -76,7 +84,7 @@ func parseArgvList(args []string, argvList []interface{}, clr color.Color) *flag
 				flagSet.err = errNotAPointerToStruct
 				return flagSet
 			}
-			initFlagSet(typ, val, flagSet, clr, false)
+			initFlagSet(typ, val, flagSet, clr, false, i >= len(argvList))
 			if flagSet.err != nil {
 				return flagSet
 			}
@@ -90,12 +98,51 @@ func parseArgvList(args []string, argvList []interface{}, clr color.Color) *flag
 }
 
 func usage(argvList []interface{}, clr color.Color, style UsageStyle) string {
-	flagSet := newFlagSet()
+	flagSet := usageFlagSet(argvList, clr)
+	if flagSet.err != nil {
+		return ""
+	}
 	buf := bytes.NewBufferString("")
-	for i := len(argvList) - 1; i >= 0; i-- {
-		v := argvList[i]
+	buf.WriteString(flagSlice(flagSet.flagSlice).visible().StringWithStyle(clr, style))
+	return buf.String()
+}
+
+// usageWithGroups returns usage of flags with section headers, flags are
+// grouped by tag `group`, and ungrouped flags placed in section `Options`
+func usageWithGroups(argvList []interface{}, clr color.Color, style UsageStyle, persistentList ...interface{}) string {
+	var (
+		flagSet = usageFlagSet(argvList, clr, persistentList...)
+		flags   flagSlice
+	)
+	if flagSet.err == nil {
+		flags = flagSlice(flagSet.flagSlice).visible()
+	}
+	groups := flags.groups()
+	if len(groups) == 0 {
+		groups = []flagGroup{{}}
+	}
+	buf := bytes.NewBufferString("")
+	for i, g := range groups {
+		name := g.name
+		if name == "" {
+			name = defaultFlagGroup
+		}
+		if i != 0 {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(buf, "%s:\n\n%s", clr.Bold(name), g.flags.stringWithStyleAndLayout(clr, style, flags))
+	}
+	return buf.String()
+}
+
+// usageFlagSet creates flagSet from argvList without setting values,
+// flags of parents placed before flags of current command, and persistent
+// flags inherited from ancestors placed last
+func usageFlagSet(argvList []interface{}, clr color.Color, persistentList ...interface{}) *flagSet {
+	flagSet := newFlagSet()
+	add := func(v interface{}, persistentOnly bool) {
 		if v == nil {
-			continue
+			return
 		}
 		var (
 			typ = reflect.TypeOf(v)
@@ -104,17 +151,21 @@ func usage(argvList []interface{}, clr color.Color, style UsageStyle) string {
 		if typ.Kind() == reflect.Ptr &&
 			reflect.Indirect(val).Type().Kind() == reflect.Struct {
 			// initialize flagSet
-			initFlagSet(typ, val, flagSet, clr, true)
-			if flagSet.err != nil {
-				return ""
-			}
+			initFlagSet(typ, val, flagSet, clr, true, persistentOnly)
 		}
 	}
-	buf.WriteString(flagSlice(flagSet.flagSlice).StringWithStyle(clr, style))
-	return buf.String()
+	for i := len(argvList) - 1; i >= 0 && flagSet.err == nil; i-- {
+		add(argvList[i], false)
+	}
+	for i := 0; i < len(persistentList) && flagSet.err == nil; i++ {
+		add(persistentList[i], true)
+	}
+	return flagSet
 }
 
-func initFlagSet(typ reflect.Type, val reflect.Value, flagSet *flagSet, clr color.Color, dontSetValue bool) {
+// initFlagSet adds flags of argv to flagSet. If persistentOnly is true, only
+// persistent flags are added and flags whose names already exist are skipped.
+func initFlagSet(typ reflect.Type, val reflect.Value, flagSet *flagSet, clr color.Color, dontSetValue, persistentOnly bool) {
 	var (
 		typElem  = typ.Elem()
 		valElem  = val.Elem()
@@ -141,12 +192,15 @@ func initFlagSet(typ reflect.Type, val reflect.Value, flagSet *flagSet, clr colo
 				subType  = reflect.TypeOf(subObj)
 				subValue = reflect.ValueOf(subObj)
 			)
-			initFlagSet(subType, subValue, flagSet, clr, dontSetValue)
+			initFlagSet(subType, subValue, flagSet, clr, dontSetValue, persistentOnly)
 			if flagSet.err != nil {
 				return
 			}
 			continue
 		}
+		if persistentOnly && (!tag.isPersistent || flagSet.hasAnyName(tag)) {
+			continue
+		}
 		fl, err := newFlag(typField, valField, tag, clr, dontSetValue)
 		if flagSet.err = err; err != nil {
 			return
@@ -155,6 +209,10 @@ func initFlagSet(typ reflect.Type, val reflect.Value, flagSet *flagSet, clr colo
 		if fl == nil {
 			continue
 		}
+		if fl.tag.position != nil {
+			flagSet.positionals = append(flagSet.positionals, fl)
+			continue
+		}
 		flagSet.flagSlice = append(flagSet.flagSlice, fl)
 
 		// encode flag value
@@ -174,8 +232,12 @@ func initFlagSet(typ reflect.Type, val reflect.Value, flagSet *flagSet, clr colo
 
 		names := append(fl.tag.shortNames, fl.tag.longNames...)
 		for i, name := range names {
-			if _, ok := flagSet.flagMap[name]; ok {
-				flagSet.err = fmt.Errorf("option %s repeated", clr.Bold(name))
+			if other, ok := flagSet.flagMap[name]; ok {
+				if other == fl {
+					flagSet.err = fmt.Errorf("option %s repeated in field %s", clr.Bold(name), typField.Name)
+				} else {
+					flagSet.err = fmt.Errorf("option %s of field %s conflicts with field %s", clr.Bold(name), typField.Name, other.field.Name)
+				}
 				return
 			}
 			flagSet.flagMap[name] = fl
@@ -189,7 +251,26 @@ func initFlagSet(typ reflect.Type, val reflect.Value, flagSet *flagSet, clr colo
 	}
 }
 
+// freeArgs returns free args of args without parsing flags, it's used by
+// commands which have no flags. Args after `--` are free args.
+func freeArgs(args []string) []string {
+	free := []string{}
+	for i, arg := range args {
+		if arg == dashTwo {
+			return append(free, args[i+1:]...)
+		}
+		if !strings.HasPrefix(arg, dashOne) {
+			free = append(free, arg)
+		}
+	}
+	return free
+}
+
 func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
+	for _, fl := range flagSet.flagSlice {
+		fl.readValues = flagSet.readValues[fl.name()]
+		fl.stdin = flagSet.stdin
+	}
 	size := len(args)
 	for i := 0; i < size; i++ {
 		arg := args[i]
@@ -204,7 +285,8 @@ func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
 			offset = 0
 		)
 		if i+1 < size {
-			if !strings.HasPrefix(args[i+1], dashOne) {
+			// single dash is a value, e.g. `-i -` means stdin conventionally
+			if !strings.HasPrefix(args[i+1], dashOne) || args[i+1] == dashOne {
 				next = args[i+1]
 				offset = 1
 			}
@@ -231,8 +313,25 @@ func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
 		arg = strs[0]
 		fl, ok := flagSet.flagMap[arg]
 
+		// try unambiguous prefix of long flag: `--ver` for `--verbose`
+		if nfl, _ := findNegatableFlag(flagSet, arg); !ok && nfl == nil && flagSet.allowAbbrev && strings.HasPrefix(arg, dashTwo) {
+			var name string
+			if fl, name, flagSet.err = findAbbrevFlag(flagSet, arg, clr); flagSet.err != nil {
+				return
+			}
+			if ok = fl != nil; ok {
+				arg = name
+				strs[0] = name
+			}
+		}
+
 		// found in flagMap
 		if ok {
+			// `--num -1`: negative number is a value rather than a flag
+			if offset == 0 && len(strs) == 1 && i+1 < size && fl.isSignedNumber() && isNumber(args[i+1]) {
+				next = args[i+1]
+				offset = 1
+			}
 			retOffset := parseToFoundFlag(flagSet, fl, strs, arg, next, offset, clr)
 			if flagSet.err != nil {
 				return
@@ -242,9 +341,24 @@ func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
 		}
 
 		// not found in flagMap
+		// try negatable boolean flag: `--no-<name>`
+		if fl, key := findNegatableFlag(flagSet, arg); fl != nil {
+			if len(strs) > 1 {
+				flagSet.err = fmt.Errorf("parameter %s doesn't accept a value", clr.Bold(arg))
+				return
+			}
+			fl.set(arg, "false", clr)
+			flagSet.values[key] = []string{"false"}
+			continue
+		}
+
 		// it's an invalid flag if arg has prefix `--`
 		if strings.HasPrefix(arg, dashTwo) {
-			flagSet.err = fmt.Errorf("undefined option %s", clr.Bold(arg))
+			if flagSet.skipUnknown || flagSet.keepUnknown {
+				flagSet.unknownFlags = append(flagSet.unknownFlags, args[i])
+				continue
+			}
+			flagSet.err = UnknownFlagError{Flag: arg, clr: clr}
 			return
 		}
 
@@ -256,18 +370,27 @@ func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
 		}
 
 		// other cases, find flag char by char
-		arg = strings.TrimPrefix(arg, dashOne)
-		parseFlagCharByChar(flagSet, arg, clr)
+		i += parseFlagCharByChar(flagSet, args[i][len(dashOne):], next, offset, clr)
 		if flagSet.err != nil {
 			return
 		}
 		continue
 	}
+	if flagSet.err == nil {
+		flagSet.assignPositionals(clr)
+	}
 
 	// read delay flags
 	for _, fl := range flagSet.flagSlice {
 		if fl.isNeedDelaySet && fl.isAssigned {
 			err := setWithProperType(fl, fl.field.Type, fl.value, fl.lastValue, clr, false)
+			if err != nil {
+				if !fl.isSet && fl.envName != "" {
+					err = fl.envError(err, clr)
+				} else {
+					err = TypeConversionError{Flag: fl.name(), Value: fl.lastValue, Err: err, clr: clr}
+				}
+			}
 			if flagSet.err == nil && err != nil {
 				flagSet.err = err
 			}
@@ -277,16 +400,33 @@ func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
 		}
 	}
 
-	// read prompt flags
-	if !flagSet.hasForce {
+	if flagSet.skipUnknown {
+		return
+	}
+
+	// load defaults before prompts and checks, flags set from command line win
+	if flagSet.err == nil && flagSet.loadDefaults != nil {
+		flagSet.loadingDefaults = true
+		flagSet.err = flagSet.loadDefaults()
+		flagSet.loadingDefaults = false
 		if flagSet.err != nil {
 			return
 		}
-		flagSet.readPrompt(os.Stdout, clr)
+	}
+
+	// read prompt flags
+	if !flagSet.hasForce {
 		if flagSet.err != nil {
 			return
 		}
-		flagSet.readEditor(clr)
+		if flagSet.readValues != nil {
+			flagSet.setReadValues(clr)
+		} else {
+			flagSet.readPrompt(clr)
+			if flagSet.err == nil {
+				flagSet.readEditor(clr)
+			}
+		}
 		if flagSet.err != nil {
 			return
 		}
@@ -294,69 +434,141 @@ func parseArgsToFlagSet(args []string, flagSet *flagSet, clr color.Color) {
 		flagSet.err = nil
 	}
 
-	buff := bytes.NewBufferString("")
+	// check choices and range of flags
+	if !flagSet.hasForce {
+		flagSet.checkValues(clr)
+		if flagSet.err != nil {
+			return
+		}
+	}
+
+	if !flagSet.hasForce {
+		flagSet.checkMutex(clr)
+		if flagSet.err != nil {
+			return
+		}
+		flagSet.checkRequired(clr)
+	}
+}
+
+// findNegatableFlag finds boolean flag `--<name>` or `-<name>` by `--no-<name>`
+func findNegatableFlag(flagSet *flagSet, arg string) (*flag, string) {
+	if !strings.HasPrefix(arg, negatablePrefix) {
+		return nil, ""
+	}
+	name := strings.TrimPrefix(arg, negatablePrefix)
+	for _, key := range []string{dashTwo + name, dashOne + name} {
+		if fl, ok := flagSet.flagMap[key]; ok && fl.isBoolean() {
+			return fl, key
+		}
+	}
+	return nil, ""
+}
+
+// findAbbrevFlag finds the flag which has a long name starting with prefix,
+// an error returned if prefix matches more than one flag
+func findAbbrevFlag(flagSet *flagSet, prefix string, clr color.Color) (*flag, string, error) {
+	var (
+		matched    []*flag
+		candidates []string
+	)
 	for _, fl := range flagSet.flagSlice {
-		if !fl.isAssigned && fl.tag.isRequired {
-			if buff.Len() > 0 {
-				buff.WriteByte('\n')
+		for _, name := range fl.tag.longNames {
+			if strings.HasPrefix(name, prefix) {
+				matched = append(matched, fl)
+				candidates = append(candidates, name)
+				break
 			}
-			fmt.Fprintf(buff, "required parameter %s missing", clr.Bold(fl.name()))
 		}
 	}
-	if buff.Len() > 0 && !flagSet.hasForce {
-		flagSet.err = fmt.Errorf(buff.String())
+	switch len(matched) {
+	case 0:
+		return nil, "", nil
+	case 1:
+		return matched[0], candidates[0], nil
+	}
+	for i := range candidates {
+		candidates[i] = clr.Bold(candidates[i])
 	}
+	return nil, "", fmt.Errorf("ambiguous option %s, candidates: %s", clr.Bold(prefix), strings.Join(candidates, ", "))
 }
 
 func parseToFoundFlag(flagSet *flagSet, fl *flag, strs []string, arg, next string, offset int, clr color.Color) int {
 	retOffset := 0
+	value := ""
 	l := len(strs)
 	if l == 1 {
+		// NOTE: boolean flag never consumes the following token,
+		// use `--flag=false` to set false explicitly
 		if fl.isBoolean() {
-			flagSet.err = fl.set(arg, "true", clr)
+			value = "true"
+			flagSet.err = fl.set(arg, value, clr)
 		} else if fl.isCounter() {
-			fl.counterIncr("", clr)
+			fl.counterIncr(arg, clr)
 		} else if offset > 0 {
-			flagSet.err = fl.set(arg, next, clr)
+			value = next
+			flagSet.err = fl.set(arg, value, clr)
 			retOffset = offset
+		} else if fl.isValueRequired() {
+			flagSet.err = MissingValueError{Flag: arg, clr: clr}
+			return retOffset
 		} else {
-			//flagSet.err = fmt.Errorf("missing argument")
-			flagSet.err = fl.set(arg, "", clr)
+			flagSet.err = fl.set(arg, value, clr)
 		}
 	} else if l == 2 {
-		flagSet.err = fl.set(arg, strs[1], clr)
+		value = strs[1]
+		flagSet.err = fl.set(arg, value, clr)
 	} else {
 		flagSet.err = fmt.Errorf("too many(%d) arguments", l)
 	}
 	if flagSet.err != nil {
-		flagSet.err = fmt.Errorf("parameter %s invalid: %v", clr.Bold(arg), flagSet.err)
+		flagSet.err = TypeConversionError{Flag: arg, Value: value, Err: flagSet.err, clr: clr}
 		return retOffset
 	}
 	flagSet.values[arg] = []string{fmt.Sprintf("%v", fl.value.Interface())}
 	return retOffset
 }
 
-func parseFlagCharByChar(flagSet *flagSet, arg string, clr color.Color) {
-	// NOTE: each fold flag should be boolean
-	chars := []byte(arg)
-	for _, c := range chars {
-		tmp := dashOne + string([]byte{c})
+// parseFlagCharByChar parses combined short flags, e.g. `-abc` is equivalent
+// to `-a -b -c`. Flags should be boolean or counter, except that the rest of
+// arg is value of the first other flag, e.g. `-abofile` is equivalent to
+// `-a -b -o file`. Value is next if the rest is empty, and offset returned.
+func parseFlagCharByChar(flagSet *flagSet, arg, next string, offset int, clr color.Color) int {
+	for i := 0; i < len(arg); i++ {
+		if arg[i] == '=' && i > 0 {
+			flagSet.err = fmt.Errorf("parameter %s doesn't accept a value", clr.Bold(dashOne+arg[i-1:i]))
+			return 0
+		}
+		tmp := dashOne + arg[i:i+1]
 		fl, ok := flagSet.flagMap[tmp]
 		if !ok {
-			flagSet.err = fmt.Errorf("undefined option %s", clr.Bold(tmp))
-			return
+			if flagSet.skipUnknown || flagSet.keepUnknown {
+				flagSet.unknownFlags = append(flagSet.unknownFlags, dashOne+arg[i:])
+				return 0
+			}
+			flagSet.err = UnknownFlagError{Flag: tmp, clr: clr}
+			return 0
 		}
 
 		if fl.isBoolean() {
 			fl.set(tmp, "true", clr)
 			flagSet.values[tmp] = []string{"true"}
-		} else if fl.isCounter() {
-			fl.counterIncr("", clr)
-		} else {
-			flagSet.err = fmt.Errorf("each fold option should be boolean, but %s not", clr.Bold(tmp))
-			return
+			continue
 		}
+		if fl.isCounter() {
+			fl.counterIncr(tmp, clr)
+			flagSet.values[tmp] = []string{fmt.Sprintf("%v", fl.value.Interface())}
+			continue
+		}
+
+		// the rest is value
+		strs := []string{tmp}
+		if rest := arg[i+1:]; rest != "" {
+			strs = append(strs, strings.TrimPrefix(rest, "="))
+		}
+		return parseToFoundFlag(flagSet, fl, strs, tmp, next, offset, clr)
 	}
+	return 0
 }
 
 func parseSiameseFlag(flagSet *flagSet, firstHalf, latterHalf string, clr color.Color) (*flag, bool) {
@@ -366,7 +578,8 @@ func parseSiameseFlag(flagSet *flagSet, firstHalf, latterHalf string, clr color.
 		if fl.isCounter() {
 			return nil, false
 		}
-		if flagSet.err = fl.set(key, val, clr); flagSet.err != nil {
+		if err := fl.set(key, val, clr); err != nil {
+			flagSet.err = TypeConversionError{Flag: key, Value: val, Err: err, clr: clr}
 			return fl, false
 		}
 		return fl, true
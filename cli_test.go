@@ -3,10 +3,17 @@ package cli
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/labstack/gommon/color"
@@ -479,6 +486,96 @@ func TestUsage(t *testing.T) {
 	assert.Equal(t, got, want)
 }
 
+func TestUsageWidth(t *testing.T) {
+	type argT struct {
+		Mode    string `cli:"m,mode" usage:"open the file in read-only mode, writes are rejected with an error"`
+		Verbose bool   `cli:"*verbose" usage:"print details\nof every step, including the time-consuming ones"`
+		Port    int    `cli:"port" usage:"short" dft:"8080"`
+	}
+	defer SetUsageWidth(0)
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		width int
+		want  string
+	}{
+		{40, `  -m, --mode          open the file in
+                      read-only mode,
+                      writes are
+                      rejected with an
+                      error
+      --verbose      *print details
+                      of every step,
+                      including the
+                      time-consuming
+                      ones
+      --port[=8080]   short
+`},
+		{80, `  -m, --mode          open the file in read-only mode, writes are rejected with
+                      an error
+      --verbose      *print details
+                      of every step, including the time-consuming ones
+      --port[=8080]   short
+`},
+		{0, `  -m, --mode          open the file in read-only mode, writes are rejected with an error
+      --verbose      *print details
+of every step, including the time-consuming ones
+      --port[=8080]   short
+`},
+	} {
+		SetUsageWidth(tt.width)
+		got := usage([]interface{}{new(argT)}, clr, NormalStyle)
+		assert.Equal(t, tt.want, got, "case %d", i)
+		for _, line := range strings.Split(got, "\n") {
+			if tt.width > 0 {
+				assert.True(t, len(line) <= tt.width, "case %d: line %q too long", i, line)
+			}
+		}
+	}
+}
+
+func TestUsageGroups(t *testing.T) {
+	type argT struct {
+		User    string `cli:"u,user" usage:"user name" group:"Authentication"`
+		Output  string `cli:"o,output" usage:"output file" group:"Output"`
+		Verbose bool   `cli:"v,verbose" usage:"verbose mode"`
+		Token   string `cli:"token" usage:"access token" group:"Authentication"`
+		Debug   bool   `cli:"debug" usage:"debug mode" group:"Debugging"`
+		Color   bool   `cli:"color" usage:"colorful output" group:"Output"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	got := usageWithGroups([]interface{}{new(argT)}, clr, NormalStyle)
+	want := `Options:
+
+  -v, --verbose   verbose mode
+
+Authentication:
+
+  -u, --user      user name
+      --token     access token
+
+Output:
+
+  -o, --output    output file
+      --color     colorful output
+
+Debugging:
+
+      --debug     debug mode
+`
+	assert.Equal(t, want, got)
+
+	// without groups
+	got = usageWithGroups([]interface{}{new(argT)}[:0], clr, NormalStyle)
+	assert.Equal(t, "Options:\n\n", got)
+	type noGroupT struct {
+		Verbose bool `cli:"v,verbose" usage:"verbose mode"`
+	}
+	got = usageWithGroups([]interface{}{new(noGroupT)}, clr, NormalStyle)
+	assert.Equal(t, "Options:\n\n  -v, --verbose   verbose mode\n", got)
+}
+
 func TestStructField(t *testing.T) {
 	type BaseT struct {
 		Help bool `cli:"!h,help" usage:"display help"`
@@ -629,6 +726,859 @@ func TestSliceAndMap(t *testing.T) {
 	}
 }
 
+func TestMapFlag(t *testing.T) {
+	type T struct {
+		Label map[string]string `cli:"l,label"`
+		Port  map[string]int    `cli:"port" sep:":"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want T
+		err  string
+	}{
+		{args: []string{"--label", "env=prod", "--label", "team=core"}, want: T{Label: map[string]string{"env": "prod", "team": "core"}}},
+		{args: []string{"--label=env=prod,team=core"}, want: T{Label: map[string]string{"env": "prod", "team": "core"}}},
+		{args: []string{"-l", "env=dev", "-l", "env=prod"}, want: T{Label: map[string]string{"env": "prod"}}},
+		{args: []string{"-l", "expr=a=b"}, want: T{Label: map[string]string{"expr": "a=b"}}},
+		{args: []string{"-l", "list=a,b"}, want: T{Label: map[string]string{"list": "a,b"}}},
+		{args: []string{"-l", "empty="}, want: T{Label: map[string]string{"empty": ""}}},
+		{args: []string{"--port", "http:80,https:443", "--port", "http:8080"}, want: T{Port: map[string]int{"http": 8080, "https": 443}}},
+		{args: []string{"--label", "env"}, err: "parameter --label invalid: `env' isn't a key=value pair"},
+		{args: []string{"--port", "http=80"}, err: "parameter --port invalid: `http=80' isn't a key:value pair"},
+		{args: []string{"--port", "http:x"}, err: "parameter --port invalid: `x' couldn't converted to an int"},
+	} {
+		v := new(T)
+		flagSet := parseArgv(tt.args, v, clr)
+		if tt.err != "" {
+			if assert.Error(t, flagSet.err, "case %d", i) {
+				assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+}
+
+func TestSliceOfStructFlag(t *testing.T) {
+	type serverT struct {
+		Host string
+		Port int    `cli:"p,port"`
+		TLS  bool   `cli:"tls"`
+		note string // unexported
+	}
+	type T struct {
+		Servers []serverT `cli:"server"`
+		Upper   []serverT `cli:"upstream" sep:":"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want T
+		err  string
+	}{
+		{args: []string{"--server", "host=a,port=1", "--server", "host=b,p=2,tls=true"}, want: T{Servers: []serverT{{Host: "a", Port: 1}, {Host: "b", Port: 2, TLS: true}}}},
+		{args: []string{"--server=Host=a", "--upstream", "host:b,port:3"}, want: T{Servers: []serverT{{Host: "a"}}, Upper: []serverT{{Host: "b", Port: 3}}}},
+		{args: []string{"--server", "host=a,user=x"}, err: "parameter --server invalid: unknown key `user', valid keys: host|port|tls"},
+		{args: []string{"--server", "note=x"}, err: "parameter --server invalid: unknown key `note', valid keys: host|port|tls"},
+		{args: []string{"--server", "port=x"}, err: "parameter --server invalid: `x' couldn't converted to an int"},
+		{args: []string{"--server", "host"}, err: "parameter --server invalid: `host' isn't a key=value pair"},
+	} {
+		v := new(T)
+		flagSet := parseArgv(tt.args, v, clr)
+		if tt.err != "" {
+			if assert.Error(t, flagSet.err, "case %d", i) {
+				assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+}
+
+func TestPositionalArgs(t *testing.T) {
+	type T struct {
+		Src     string `positional:"0" required:"true"`
+		Count   int    `positional:"1" dft:"1"`
+		DstFile string `positional:"2" name:"dst"`
+		Verbose bool   `cli:"v"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args     []string
+		want     T
+		freeArgs []string
+		err      string
+	}{
+		{args: []string{"a"}, want: T{Src: "a", Count: 1}, freeArgs: []string{}},
+		{args: []string{"a", "-v", "3", "b", "extra", "more"}, want: T{Src: "a", Count: 3, DstFile: "b", Verbose: true}, freeArgs: []string{"extra", "more"}},
+		{args: []string{"-v", "--", "-a", "2"}, want: T{Src: "-a", Count: 2, Verbose: true}, freeArgs: []string{}},
+		{args: []string{"a", "x"}, err: "parameter <count> invalid: `x' couldn't converted to an int"},
+		{args: []string{"-v"}, err: "required parameter <src> missing"},
+	} {
+		v := new(T)
+		flagSet := parseArgv(tt.args, v, clr)
+		if tt.err != "" {
+			if assert.Error(t, flagSet.err, "case %d", i) {
+				assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+			assert.Equal(t, tt.freeArgs, flagSet.args, "case %d", i)
+		}
+	}
+
+	// values of positionals checked
+	type checkT struct {
+		Mode  string `positional:"0" choices:"fast|slow"`
+		Count int    `positional:"1" dft:"1" min:"1" max:"9"`
+	}
+	for i, tt := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"fast", "3"}, ""},
+		{[]string{"slow"}, ""},
+		{[]string{"quick"}, "parameter <mode> invalid: `quick' is not one of fast|slow"},
+		{[]string{"slow", "10"}, "parameter <count> invalid: `10' should be in range [1, 9]"},
+	} {
+		err := parseArgv(tt.args, new(checkT), clr).err
+		if tt.err == "" {
+			assert.Nil(t, err, "case %d", i)
+		} else if assert.Error(t, err, "case %d", i) {
+			assert.Equal(t, tt.err, err.Error(), "case %d", i)
+		}
+	}
+
+	// invalid index
+	type invalidT struct {
+		A string `positional:"-1"`
+	}
+	assert.Equal(t, "field A: invalid positional index -1", parseArgv(nil, new(invalidT), clr).err.Error())
+
+	// synopsis
+	root := Root(&Command{Name: "app"},
+		Tree(&Command{
+			Name: "copy",
+			Desc: "copy file",
+			Argv: func() interface{} { return new(T) },
+			Fn:   donothing,
+		}),
+	)
+	cmd, _ := root.Find("copy")
+	ctx := &Context{color: clr}
+	assert.Equal(t, "copy file\n\nUsage:\n\n  app copy [flags] <src> <count> <dst>\n\nOptions:\n\n  -v   \n", cmd.Usage(ctx))
+}
+
+func TestFlagAliases(t *testing.T) {
+	type T struct {
+		Color string `cli:"c,color,colour" usage:"when to use colors"`
+		Quiet bool   `cli:"q,quiet,silent,s"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want T
+	}{
+		{[]string{"--color=auto"}, T{Color: "auto"}},
+		{[]string{"--colour", "never"}, T{Color: "never"}},
+		{[]string{"-c", "always", "--silent"}, T{Color: "always", Quiet: true}},
+		{[]string{"-s"}, T{Quiet: true}},
+	} {
+		v := new(T)
+		flagSet := parseArgv(tt.args, v, clr)
+		if assert.Nil(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+
+	// the first long name is canonical
+	ctx, err := newContext("", nil, []string{"--colour=auto"}, []interface{}{new(T)}, clr)
+	assert.Nil(t, err)
+	assert.True(t, ctx.IsSet("--color", "-c"))
+	fl, _ := ctx.lookupFlag("c")
+	fl.actualFlagName = ""
+	assert.Equal(t, "--color", fl.name())
+	assert.Equal(t, "      -c, --color, --colour   when to use colors\n  -q, -s, --quiet, --silent   \n", usage([]interface{}{new(T)}, clr, NormalStyle))
+
+	// collisions
+	type conflictT struct {
+		Color  string `cli:"color"`
+		Colour string `cli:"colour,color"`
+	}
+	err = parseArgv(nil, new(conflictT), clr).err
+	if assert.Error(t, err) {
+		assert.Equal(t, "option --color of field Colour conflicts with field Color", err.Error())
+	}
+	type repeatedT struct {
+		Color string `cli:"c,color,c"`
+	}
+	err = parseArgv(nil, new(repeatedT), clr).err
+	if assert.Error(t, err) {
+		assert.Equal(t, "option -c repeated in field Color", err.Error())
+	}
+}
+
+func TestSliceFlagDefault(t *testing.T) {
+	type T struct {
+		Tags  []string `cli:"t,tag" dft:"x"`
+		Ports []int    `cli:"port" dft:"80,443" sep:","`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want T
+		err  string
+	}{
+		{args: []string{}, want: T{Tags: []string{"x"}, Ports: []int{80, 443}}},
+		{args: []string{"--tag", "a"}, want: T{Tags: []string{"a"}, Ports: []int{80, 443}}},
+		{args: []string{"--tag", "a", "-t", "b"}, want: T{Tags: []string{"a", "b"}, Ports: []int{80, 443}}},
+		{args: []string{"--tag=a,b"}, want: T{Tags: []string{"a,b"}, Ports: []int{80, 443}}},
+		{args: []string{"--port=8080"}, want: T{Tags: []string{"x"}, Ports: []int{8080}}},
+		{args: []string{"--port=1,2", "--port", "3"}, want: T{Tags: []string{"x"}, Ports: []int{1, 2, 3}}},
+		{args: []string{"--port=1,a"}, err: "parameter --port invalid: `a' couldn't converted to an int"},
+	} {
+		v := new(T)
+		flagSet := parseArgv(tt.args, v, clr)
+		if tt.err != "" {
+			if assert.Error(t, flagSet.err, "case %d", i) {
+				assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+}
+
+func TestFlagValueForms(t *testing.T) {
+	type T struct {
+		Name    string   `cli:"name"`
+		Other   string   `cli:"other"`
+		Num     int      `cli:"n,num"`
+		Float   float64  `cli:"f"`
+		Slice   []string `cli:"s,slice"`
+		Verbose bool     `cli:"v,verbose"`
+	}
+	for i, tt := range []struct {
+		args   []string
+		want   T
+		free   []string
+		errMsg string
+	}{
+		{args: []string{"--name=foo"}, want: T{Name: "foo"}},
+		{args: []string{"--name", "foo"}, want: T{Name: "foo"}},
+		{args: []string{"--num=3"}, want: T{Num: 3}},
+		{args: []string{"--num", "3"}, want: T{Num: 3}},
+		{args: []string{"--num", "-3"}, want: T{Num: -3}},
+		{args: []string{"-f", "-1.5"}, want: T{Float: -1.5}},
+		{args: []string{"--slice=a", "--slice", "b"}, want: T{Slice: []string{"a", "b"}}},
+		{args: []string{"--name="}, want: T{}},
+		{args: []string{"--name=", "--other", "x"}, want: T{Other: "x"}},
+		// boolean flag never consumes the following token
+		{args: []string{"--verbose", "foo"}, want: T{Verbose: true}, free: []string{"foo"}},
+		{args: []string{"-v", "false"}, want: T{Verbose: true}, free: []string{"false"}},
+		// missing value
+		{args: []string{"--name", "--other", "x"}, errMsg: "parameter --name invalid: missing value"},
+		{args: []string{"--name"}, errMsg: "parameter --name invalid: missing value"},
+		{args: []string{"--num"}, errMsg: "parameter --num invalid: missing value"},
+		{args: []string{"--slice", "-v"}, errMsg: "parameter --slice invalid: missing value"},
+		{args: []string{"--name", "-1"}, errMsg: "parameter --name invalid: missing value"},
+	} {
+		clr := color.Color{}
+		clr.Disable()
+		v := new(T)
+		fset := parseArgv(tt.args, v, clr)
+		err := fset.err
+		if tt.errMsg != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.errMsg, err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+		if tt.free != nil {
+			assert.Equal(t, tt.free, fset.args, "case %d", i)
+		}
+	}
+}
+
+func TestEnvFallback(t *testing.T) {
+	type T struct {
+		Host  string   `cli:"host" env:"CLI_TEST_HOST1,CLI_TEST_HOST2" dft:"localhost"`
+		Port  int      `cli:"port" env:"CLI_TEST_PORT" dft:"8080"`
+		Slice []string `cli:"s" env:"CLI_TEST_SLICE"`
+	}
+	setenv := func(kvs ...string) {
+		for _, k := range []string{"CLI_TEST_HOST1", "CLI_TEST_HOST2", "CLI_TEST_PORT", "CLI_TEST_SLICE"} {
+			os.Unsetenv(k)
+		}
+		for i := 0; i+1 < len(kvs); i += 2 {
+			os.Setenv(kvs[i], kvs[i+1])
+		}
+	}
+	defer setenv()
+
+	for i, tt := range []struct {
+		env   []string
+		args  []string
+		want  T
+		isErr bool
+	}{
+		// default
+		{args: []string{}, want: T{Host: "localhost", Port: 8080}},
+		// env > default
+		{env: []string{"CLI_TEST_HOST2", "h2", "CLI_TEST_PORT", "9000"}, want: T{Host: "h2", Port: 9000}},
+		// first set env wins
+		{env: []string{"CLI_TEST_HOST1", "h1", "CLI_TEST_HOST2", "h2"}, want: T{Host: "h1", Port: 8080}},
+		// cli > env
+		{env: []string{"CLI_TEST_HOST1", "h1", "CLI_TEST_PORT", "9000"}, args: []string{"--host=cli", "--port", "1"}, want: T{Host: "cli", Port: 1}},
+		{env: []string{"CLI_TEST_SLICE", "x"}, want: T{Host: "localhost", Port: 8080, Slice: []string{"x"}}},
+		// conversion failure
+		{env: []string{"CLI_TEST_PORT", "not-a-number"}, isErr: true},
+		// invalid env value overridden by cli
+		{env: []string{"CLI_TEST_PORT", "not-a-number"}, args: []string{"--port=2"}, want: T{Host: "localhost", Port: 2}},
+	} {
+		setenv(tt.env...)
+		v := new(T)
+		err := Parse(tt.args, v)
+		if tt.isErr {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Contains(t, err.Error(), "CLI_TEST_PORT", "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+
+	// value from environment is not set by command line
+	setenv("CLI_TEST_HOST1", "h1")
+	RunWithArgs(new(T), []string{"app"}, func(ctx *Context) error {
+		assert.Equal(t, "h1", ctx.Argv().(*T).Host)
+		assert.False(t, ctx.IsSet("--host"))
+		return nil
+	})
+}
+
+func TestRequiredTag(t *testing.T) {
+	type T struct {
+		Name  string `cli:"name" required:"true"`
+		Age   int    `cli:"*age"`
+		Email string `cli:"email" required:"false"`
+		Token string `cli:"token" required:"true" env:"CLI_TEST_TOKEN"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	os.Unsetenv("CLI_TEST_TOKEN")
+	defer os.Unsetenv("CLI_TEST_TOKEN")
+
+	for i, tt := range []struct {
+		args    []string
+		env     string
+		missing []string
+	}{
+		{args: []string{}, missing: []string{"--name", "--age", "--token"}},
+		{args: []string{"--name=x", "--age=1"}, missing: []string{"--token"}},
+		{args: []string{"--name=x", "--age=1", "--token=t"}},
+		// environment variable satisfies the requirement
+		{args: []string{"--name=x", "--age=1"}, env: "t"},
+		{args: []string{"--age=1"}, env: "t", missing: []string{"--name"}},
+	} {
+		os.Setenv("CLI_TEST_TOKEN", tt.env)
+		err := parseArgv(tt.args, new(T), clr).err
+		if tt.missing == nil {
+			assert.Nil(t, err, "case %d", i)
+			continue
+		}
+		e, ok := err.(MissingRequiredError)
+		if assert.True(t, ok, "case %d: %v", i, err) {
+			assert.Equal(t, tt.missing, e.Flags, "case %d", i)
+		}
+	}
+	err := parseArgv([]string{"--token=t"}, new(T), clr).err
+	assert.Equal(t, "required parameter --name missing\nrequired parameter --age missing", err.Error())
+
+	// required flag with default value
+	type badT struct {
+		Name string `cli:"name" required:"true" dft:"x"`
+	}
+	assert.Error(t, Parse([]string{}, new(badT)))
+	type badT2 struct {
+		Name string `cli:"*name" dft:"x"`
+	}
+	assert.Error(t, Parse([]string{}, new(badT2)))
+	type badT3 struct {
+		Name string `cli:"name" required:"yes!"`
+	}
+	assert.Error(t, Parse([]string{}, new(badT3)))
+}
+
+func TestChoicesTag(t *testing.T) {
+	type T struct {
+		Format string   `cli:"f,format" choices:"json|yaml|table"`
+		Level  string   `cli:"level" choices:"debug|info" choices_ci:"true"`
+		Tags   []string `cli:"t" choices:"a|b"`
+		Num    int      `cli:"n" choices:"1|2|3" dft:"1"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args   []string
+		want   T
+		errMsg string
+	}{
+		{args: []string{}, want: T{Num: 1}},
+		{args: []string{"-f", "yaml", "--level=INFO", "-t", "a", "-t", "b", "-n3"},
+			want: T{Format: "yaml", Level: "INFO", Tags: []string{"a", "b"}, Num: 3}},
+		{args: []string{"-f", "xml"}, errMsg: "parameter -f invalid: `xml' is not one of json|yaml|table"},
+		{args: []string{"-f", "JSON"}, errMsg: "parameter -f invalid: `JSON' is not one of json|yaml|table"},
+		{args: []string{"--format="}, errMsg: "parameter --format invalid: `' is not one of json|yaml|table"},
+		{args: []string{"-t", "a", "-t", "c"}, errMsg: "parameter -t invalid: `c' is not one of a|b"},
+		{args: []string{"-n", "4"}, errMsg: "parameter -n invalid: `4' is not one of 1|2|3"},
+	} {
+		v := new(T)
+		err := parseArgv(tt.args, v, clr).err
+		if tt.errMsg != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.errMsg, err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	type T struct {
+		Email  string   `cli:"email" normalize:"trim,lower"`
+		Format string   `cli:"f" normalize:"trim, lower" choices:"json|yaml"`
+		Tags   []string `cli:"t" normalize:"upper"`
+		Name   string   `cli:"name" normalize:"reverse"`
+	}
+	RegisterNormalizer("reverse", func(s string) string {
+		runes := []rune(s)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes)
+	})
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args   []string
+		want   T
+		errMsg string
+	}{
+		{args: []string{"--email", "  Bob@Example.COM "}, want: T{Email: "bob@example.com"}},
+		{args: []string{"-f", " JSON "}, want: T{Format: "json"}},
+		{args: []string{"-t", "a", "-t", "b"}, want: T{Tags: []string{"A", "B"}}},
+		{args: []string{"--name=abc"}, want: T{Name: "cba"}},
+		{args: []string{"-f", " XML"}, errMsg: "parameter -f invalid: `xml' is not one of json|yaml"},
+	} {
+		v := new(T)
+		err := parseArgv(tt.args, v, clr).err
+		if tt.errMsg != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.errMsg, err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+
+	// values of all sources normalized
+	type sourceT struct {
+		Mode  string `cli:"mode" env:"ZZ_NORMALIZE_MODE" normalize:"lower" choices:"prod|dev"`
+		Level string `cli:"level" dft:"INFO" normalize:"lower"`
+		Name  string `cli:"name" normalize:"reverse"`
+		File  string `positional:"0" normalize:"trim"`
+	}
+	os.Setenv("ZZ_NORMALIZE_MODE", "Prod")
+	defer os.Unsetenv("ZZ_NORMALIZE_MODE")
+	ctx, err := newContext("", nil, []string{" a.txt "}, []interface{}{new(sourceT)}, clr)
+	if assert.Nil(t, err) {
+		ctx.HTTPRequest = httptest.NewRequest("GET", "/?name=abc", nil)
+		assert.Nil(t, ctx.BindQuery())
+		assert.Equal(t, sourceT{Mode: "prod", Level: "info", Name: "cba", File: "a.txt"}, *ctx.Argv().(*sourceT))
+	}
+
+	type badT struct {
+		Name string `cli:"name" normalize:"trim,unknown"`
+	}
+	err = parseArgv([]string{}, new(badT), clr).err
+	if assert.Error(t, err) {
+		assert.Equal(t, "field Name: unknown normalizer unknown", err.Error())
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	type T struct {
+		Endpoint string   `cli:"e,endpoint" validate:"url"`
+		Email    string   `cli:"email" validate:"email"`
+		Name     string   `cli:"name" validate:"regexp:^[a-z][a-z0-9-]*$"`
+		Tags     []string `cli:"t" validate:"regexp:^[a-z]+$"`
+		Port     int      `cli:"p" validate:"even"`
+	}
+	RegisterFlagValidator("even", func(name, value string) error {
+		if n, err := strconv.Atoi(value); err != nil || n%2 != 0 {
+			return fmt.Errorf("%s should be an even number", name)
+		}
+		return nil
+	})
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args   []string
+		want   T
+		errMsg string
+	}{
+		{args: []string{}, want: T{}},
+		{args: []string{"-e", "https://example.com/api", "--email=bob@example.com", "--name=web-1", "-t", "a", "-t", "b", "-p8080"},
+			want: T{Endpoint: "https://example.com/api", Email: "bob@example.com", Name: "web-1", Tags: []string{"a", "b"}, Port: 8080}},
+		{args: []string{"-e", "example.com"}, errMsg: "parameter -e invalid: `example.com' is not a valid URL"},
+		{args: []string{"--endpoint=http://"}, errMsg: "parameter --endpoint invalid: `http://' is not a valid URL"},
+		{args: []string{"--email", "Bob <bob@example.com>"}, errMsg: "parameter --email invalid: `Bob <bob@example.com>' is not a valid email address"},
+		{args: []string{"--name", "Web"}, errMsg: "parameter --name invalid: `Web' does not match ^[a-z][a-z0-9-]*$"},
+		{args: []string{"-t", "a", "-t", "b1"}, errMsg: "parameter -t invalid: `b1' does not match ^[a-z]+$"},
+		{args: []string{"-p", "81"}, errMsg: "parameter -p invalid: -p should be an even number"},
+	} {
+		v := new(T)
+		err := parseArgv(tt.args, v, clr).err
+		if tt.errMsg != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.errMsg, err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+
+	for i, tt := range []struct {
+		argv   interface{}
+		errMsg string
+	}{
+		{new(struct {
+			Name string `cli:"name" validate:"unknown"`
+		}), "field Name: unknown validator unknown"},
+		{new(struct {
+			Name string `cli:"name" validate:"regexp:[a-"`
+		}), "field Name: error parsing regexp: missing closing ]: `[a-`"},
+	} {
+		err := parseArgv([]string{}, tt.argv, clr).err
+		if assert.Error(t, err, "case %d", i) {
+			assert.Equal(t, tt.errMsg, err.Error(), "case %d", i)
+		}
+	}
+
+	// regexp compiled once
+	fn1, _ := lookupFlagValidator("regexp:^[a-z]+$")
+	fn2, _ := lookupFlagValidator("regexp:^[a-z]+$")
+	assert.Equal(t, reflect.ValueOf(fn1).Pointer(), reflect.ValueOf(fn2).Pointer())
+
+	// values of other sources validated
+	type sourceT struct {
+		Name string `cli:"name" env:"ZZ_VALIDATE_NAME" json:"name" validate:"regexp:^[a-z]+$"`
+		File string `positional:"0" validate:"regexp:^[a-z]+\\.txt$"`
+	}
+	dir, err := ioutil.TempDir("", "cli")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	envFile, configFile := filepath.Join(dir, ".env"), filepath.Join(dir, "config.json")
+	assert.Nil(t, ioutil.WriteFile(envFile, []byte("ZZ_VALIDATE_NAME=Bob\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(configFile, []byte(`{"name":"Bob"}`), 0644))
+	nameErr := "parameter --name invalid: `Bob' does not match ^[a-z]+$"
+	for i, tt := range []struct {
+		args   []string
+		bind   func(*Context) error
+		errMsg string
+	}{
+		{[]string{"A.txt"}, nil, "parameter <file> invalid: `A.txt' does not match ^[a-z]+\\.txt$"},
+		{nil, func(ctx *Context) error {
+			ctx.HTTPRequest = httptest.NewRequest("GET", "/?name=Bob", nil)
+			return ctx.BindQuery()
+		}, nameErr},
+		{nil, func(ctx *Context) error { return ctx.LoadEnvFile(envFile) }, nameErr},
+		{nil, func(ctx *Context) error { return ctx.LoadConfig(configFile) }, nameErr},
+	} {
+		ctx, err := newContext("", nil, tt.args, []interface{}{new(sourceT)}, clr)
+		if tt.bind != nil {
+			assert.Nil(t, err, "case %d", i)
+			err = tt.bind(ctx)
+		}
+		if assert.Error(t, err, "case %d", i) {
+			assert.Equal(t, tt.errMsg, err.Error(), "case %d", i)
+		}
+	}
+}
+
+func TestMutexTag(t *testing.T) {
+	type T struct {
+		JSON  bool   `cli:"json" mutex:"output"`
+		Table bool   `cli:"table" mutex:"output"`
+		YAML  bool   `cli:"yaml" mutex:"output"`
+		User  string `cli:"u,user" mutex:"auth" mutex_required:"true"`
+		Token string `cli:"token" mutex:"auth"`
+	}
+	type dftT struct {
+		User  string `cli:"user" mutex:"auth" mutex_required:"true" dft:"root"`
+		Token string `cli:"token" mutex:"auth"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		argv interface{}
+		args []string
+		err  string
+	}{
+		{new(T), []string{"-u", "x"}, ""},
+		{new(T), []string{"--token=x", "--json"}, ""},
+		{new(T), []string{"-u", "x", "--json", "--yaml"}, "parameters --json, --yaml are mutually exclusive"},
+		{new(T), []string{"-u", "x", "--json", "--table", "--yaml"}, "parameters --json, --table, --yaml are mutually exclusive"},
+		{new(T), []string{"--json"}, "one of --user, --token required"},
+		{new(T), []string{"--user=a", "--token=b"}, "parameters --user, --token are mutually exclusive"},
+		// default value satisfies required, and doesn't conflict
+		{new(dftT), []string{}, ""},
+		{new(dftT), []string{"--token=b"}, ""},
+	} {
+		flagSet := parseArgv(tt.args, tt.argv, clr)
+		if tt.err == "" {
+			assert.Nil(t, flagSet.err, "case %d", i)
+		} else if assert.Error(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+			_, ok := flagSet.err.(MutexFlagsError)
+			assert.True(t, ok, "case %d", i)
+		}
+	}
+
+	type invalidT struct {
+		A bool `cli:"a" mutex_required:"true"`
+	}
+	assert.Error(t, parseArgv([]string{}, new(invalidT), clr).err)
+}
+
+func TestMinMaxTag(t *testing.T) {
+	type T struct {
+		Threads int     `cli:"threads" min:"1" max:"16" dft:"4"`
+		Retry   uint    `cli:"retry" max:"3"`
+		Ratio   float64 `cli:"ratio" min:"0.5"`
+		Ports   []int   `cli:"port" min:"1" max:"65535"`
+		Weight  float32 `cli:"weight" min:"-1" max:"1"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{}},
+		{args: []string{"--threads=1", "--retry=3", "--ratio=0.5", "--port=1", "--port=65535", "--weight=-1"}},
+		{args: []string{"--threads=16", "--retry=0", "--ratio=1e9", "--weight=1"}},
+		{args: []string{"--threads=0"}, err: "parameter --threads invalid: `0' should be in range [1, 16]"},
+		{args: []string{"--threads=17"}, err: "parameter --threads invalid: `17' should be in range [1, 16]"},
+		{args: []string{"--retry=4"}, err: "parameter --retry invalid: `4' should be at most 3"},
+		{args: []string{"--ratio=0.4"}, err: "parameter --ratio invalid: `0.4' should be at least 0.5"},
+		{args: []string{"--port=80", "--port=0"}, err: "parameter --port invalid: `0' should be in range [1, 65535]"},
+		{args: []string{"--weight=1.5"}, err: "parameter --weight invalid: `1.5' should be in range [-1, 1]"},
+	} {
+		v := new(T)
+		flagSet := parseArgv(tt.args, v, clr)
+		if tt.err == "" {
+			assert.Nil(t, flagSet.err, "case %d", i)
+		} else if assert.Error(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+		}
+	}
+
+	// invalid tags
+	type notNumberT struct {
+		Name string `cli:"name" min:"1"`
+	}
+	type invalidMinT struct {
+		N int `cli:"n" min:"x"`
+	}
+	type minGreaterThanMaxT struct {
+		N int `cli:"n" min:"2" max:"1"`
+	}
+	for i, tt := range []struct {
+		argv interface{}
+		err  string
+	}{
+		{new(notNumberT), "field Name: min/max only used for number flag"},
+		{new(invalidMinT), "tag min of field N invalid: strconv.ParseFloat: parsing \"x\": invalid syntax"},
+		{new(minGreaterThanMaxT), "field N: min 2 greater than max 1"},
+	} {
+		flagSet := parseArgv([]string{}, tt.argv, clr)
+		if assert.Error(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+		}
+	}
+}
+
+func TestNegatableFlag(t *testing.T) {
+	type T struct {
+		Verbose bool   `cli:"verbose" dft:"true"`
+		Color   bool   `cli:"c,color"`
+		Name    string `cli:"name"`
+	}
+	for i, tt := range []struct {
+		args  []string
+		want  T
+		isSet bool
+		isErr bool
+	}{
+		{args: []string{}, want: T{Verbose: true}},
+		{args: []string{"--no-verbose"}, want: T{}, isSet: true},
+		{args: []string{"--verbose", "--no-verbose"}, want: T{}, isSet: true},
+		{args: []string{"--no-verbose", "--verbose"}, want: T{Verbose: true}, isSet: true},
+		{args: []string{"-c", "--no-c"}, want: T{Verbose: true}},
+		{args: []string{"--no-color"}, want: T{Verbose: true}},
+		{args: []string{"--no-verbose=true"}, isErr: true},
+		// only boolean flag is negatable
+		{args: []string{"--no-name"}, isErr: true},
+	} {
+		v := new(T)
+		var isSet bool
+		err := (&Command{
+			Name: "app",
+			Argv: func() interface{} { return v },
+			Fn: func(ctx *Context) error {
+				isSet = ctx.IsSet("--verbose")
+				return nil
+			},
+		}).Run(tt.args)
+		if tt.isErr {
+			assert.Error(t, err, "case %d", i)
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+			assert.Equal(t, tt.isSet, isSet, "case %d", i)
+		}
+	}
+}
+
+func TestCountFlag(t *testing.T) {
+	type T struct {
+		Verbose int  `cli:"v,verbose" count:"true"`
+		Level   uint `cli:"l" count:"true" dft:"1"`
+		Bool    bool `cli:"b"`
+	}
+	for i, tt := range []struct {
+		args  []string
+		want  T
+		isSet bool
+	}{
+		{args: []string{}, want: T{Level: 1}},
+		{args: []string{"-v"}, want: T{Verbose: 1, Level: 1}, isSet: true},
+		{args: []string{"-vvv"}, want: T{Verbose: 3, Level: 1}, isSet: true},
+		{args: []string{"-v", "-v", "-v"}, want: T{Verbose: 3, Level: 1}, isSet: true},
+		{args: []string{"-vv", "--verbose", "-bv"}, want: T{Verbose: 4, Level: 1, Bool: true}, isSet: true},
+		{args: []string{"--verbose=2"}, want: T{Verbose: 2, Level: 1}, isSet: true},
+		{args: []string{"--verbose=2", "-v"}, want: T{Verbose: 3, Level: 1}, isSet: true},
+		{args: []string{"-ll"}, want: T{Level: 3}},
+		// the following token is never consumed
+		{args: []string{"--verbose", "2"}, want: T{Verbose: 1, Level: 1}, isSet: true},
+	} {
+		v := new(T)
+		var isSet bool
+		err := (&Command{
+			Name: "app",
+			Argv: func() interface{} { return v },
+			Fn: func(ctx *Context) error {
+				isSet = ctx.IsSet("-v")
+				return nil
+			},
+		}).Run(tt.args)
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+			assert.Equal(t, tt.isSet, isSet, "case %d", i)
+		}
+	}
+
+	type badT struct {
+		Verbose string `cli:"v" count:"true"`
+	}
+	assert.Error(t, Parse([]string{"-v"}, new(badT)))
+}
+
+func TestFromFileFlag(t *testing.T) {
+	type T struct {
+		Data  string   `cli:"data" fromfile:"true"`
+		Keys  []string `cli:"k" fromfile:"true"`
+		Email string   `cli:"email"`
+	}
+	filename := "cli_test_fromfile.tmp"
+	require.Nil(t, ioutil.WriteFile(filename, []byte(`{"a":1}`), 0644))
+	defer os.Remove(filename)
+
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args   []string
+		want   T
+		errMsg string
+	}{
+		{args: []string{"--data", "@" + filename}, want: T{Data: `{"a":1}`}},
+		{args: []string{"--data=plain", "-k", "@" + filename, "-k", "x"}, want: T{Data: "plain", Keys: []string{`{"a":1}`, "x"}}},
+		// `@` is literal for flag without fromfile tag
+		{args: []string{"--email", "@" + filename}, want: T{Email: "@" + filename}},
+		{args: []string{"--data", "@not-found.tmp"}, errMsg: "parameter --data invalid: read from file not-found.tmp: open not-found.tmp: no such file or directory"},
+		{args: []string{"--data", "@"}, errMsg: "parameter --data invalid: read from file : missing filename after @"},
+	} {
+		v := new(T)
+		err := parseArgv(tt.args, v, clr).err
+		if tt.errMsg != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.errMsg, err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+
+	// `@-` reads stdin of context
+	var got T
+	app := &Command{
+		Argv: func() interface{} { return new(T) },
+		Fn: func(ctx *Context) error {
+			got = *ctx.Argv().(*T)
+			return nil
+		},
+	}
+	stdin := strings.NewReader("from stdin")
+	assert.Nil(t, app.RunWithOptions([]string{"--data", "@-"}, WithStdin(stdin), WithStdout(ioutil.Discard)))
+	assert.Equal(t, T{Data: "from stdin"}, got)
+
+	type badT struct {
+		Data int `cli:"data" fromfile:"true"`
+	}
+	assert.Error(t, Parse([]string{}, new(badT)))
+}
+
 func TestErrorSliceType(t *testing.T) {
 	type A struct {
 		Value string
@@ -694,6 +1644,48 @@ func TestHelpCommand(t *testing.T) {
 	assert.Error(t, root.RunWith([]string{"help", "not-found"}, nil, nil))
 }
 
+func TestBuiltinHelpCommand(t *testing.T) {
+	clr := color.Color{}
+	clr.Disable()
+	newRoot := func() *Command {
+		return Root(&Command{Name: "app", Fn: donothing},
+			Tree(&Command{Name: "deploy", Desc: "deploy app", Fn: donothing},
+				Tree(&Command{Name: "prod", Desc: "deploy to production", Fn: donothing}),
+			),
+		)
+	}
+	for i, tt := range []struct {
+		args []string
+		path []string
+	}{
+		{args: []string{"help"}, path: []string{}},
+		{args: []string{"help", "deploy"}, path: []string{"deploy"}},
+		{args: []string{"help", "deploy", "prod"}, path: []string{"deploy", "prod"}},
+	} {
+		root := newRoot()
+		w := bytes.NewBufferString("")
+		assert.Nil(t, root.RunWith(tt.args, w, nil), "case %d", i)
+		want := root.Route(tt.path).Usage(&Context{color: clr})
+		assert.Equal(t, want, w.String(), "case %d", i)
+	}
+
+	// unknown command path
+	err := newRoot().RunWith([]string{"help", "deploy", "dev"}, bytes.NewBufferString(""), nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "command deploy dev not found")
+	}
+
+	// disabled if root has a child named "help"
+	w := bytes.NewBufferString("")
+	root := newRoot()
+	root.Register(&Command{Name: "help", CanSubRoute: true, Fn: func(ctx *Context) error {
+		ctx.String("custom")
+		return nil
+	}})
+	assert.Nil(t, root.RunWith([]string{"help", "deploy"}, w, nil))
+	assert.Equal(t, "custom", w.String())
+}
+
 func TestError(t *testing.T) {
 	assert.Equal(t, ExitError.Error(), "exit")
 	assert.Equal(t, throwCommandNotFound("cmd").Error(), "command cmd not found")
@@ -708,6 +1700,80 @@ func TestError(t *testing.T) {
 	assert.Equal(t, argvError{ith: 1, msg: "ERROR MSG"}.Error(), "1th argv: ERROR MSG")
 }
 
+func TestExitCode(t *testing.T) {
+	type argT struct{}
+	for i, tt := range []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("plain"), 1},
+		{NewExitError(3, "not found"), 3},
+		{NewExitError(0, ""), 0},
+		{fmt.Errorf("wrapped: %w", NewExitError(64, "usage")), 64},
+	} {
+		assert.Equal(t, tt.want, ExitCodeOf(tt.err), "case %d", i)
+		err := tt.err
+		code := RunWithArgs(new(argT), []string{"app"}, func(ctx *Context) error { return err })
+		assert.Equal(t, tt.want, code, "case %d", i)
+	}
+	assert.Equal(t, "not found", NewExitError(3, "not found").Error())
+}
+
+func TestStructuredError(t *testing.T) {
+	type T struct {
+		Name string `cli:"n,name"`
+		Num  int    `cli:"num"`
+		Hex  uint8  `cli:"x"`
+		Bool bool   `cli:"b"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+
+	err := parseArgv([]string{"--unknown"}, new(T), clr).err
+	var unknownErr UnknownFlagError
+	if assert.True(t, errors.As(err, &unknownErr)) {
+		assert.Equal(t, "--unknown", unknownErr.Flag)
+		assert.Equal(t, "undefined option --unknown", err.Error())
+	}
+	err = parseArgv([]string{"-bq"}, new(T), clr).err
+	if assert.True(t, errors.As(err, &unknownErr)) {
+		assert.Equal(t, "-q", unknownErr.Flag)
+	}
+
+	err = parseArgv([]string{"--name"}, new(T), clr).err
+	var missingErr MissingValueError
+	if assert.True(t, errors.As(err, &missingErr)) {
+		assert.Equal(t, "--name", missingErr.Flag)
+	}
+
+	var convErr TypeConversionError
+	for _, args := range [][]string{
+		{"--num", "abc"},
+		{"--num=abc"},
+		{"-xabc"},
+		{"-b=abc"},
+	} {
+		err = parseArgv(args, new(T), clr).err
+		if assert.True(t, errors.As(err, &convErr), "%v: %v", args, err) {
+			assert.Equal(t, "abc", convErr.Value)
+			assert.NotNil(t, errors.Unwrap(err))
+		}
+	}
+	err = parseArgv([]string{"--num=abc"}, new(T), clr).err
+	assert.Equal(t, "parameter --num invalid: `abc' couldn't converted to an int", err.Error())
+
+	// error returned by Command.Run
+	err = (&Command{
+		Name: "app",
+		Argv: func() interface{} { return new(T) },
+		Fn:   donothing,
+	}).Run([]string{"--num=abc"})
+	if assert.True(t, errors.As(err, &convErr)) {
+		assert.Equal(t, "--num", convErr.Flag)
+	}
+}
+
 type customT struct {
 	K1 string
 	K2 int
@@ -727,3 +1793,315 @@ func TestDecoder(t *testing.T) {
 	assert.Nil(t, flagSet.err)
 	assert.Equal(t, v.D, customT{K1: "string", K2: 2})
 }
+
+func TestColorSwitch(t *testing.T) {
+	defer func(v *bool) { forceColor = v }(forceColor)
+	noColor, hasNoColor := os.LookupEnv("NO_COLOR")
+	defer func() {
+		if hasNoColor {
+			os.Setenv("NO_COLOR", noColor)
+		} else {
+			os.Unsetenv("NO_COLOR")
+		}
+	}()
+
+	enabled := func() bool {
+		var clr color.Color
+		assert.Nil(t, (&Command{
+			Fn: func(ctx *Context) error {
+				clr = *ctx.Color()
+				return nil
+			},
+		}).RunWith(nil, bytes.NewBufferString(""), nil))
+		return clr.Red("x") != "x"
+	}
+	for i, tt := range []struct {
+		noColor string
+		force   *bool
+		want    bool
+	}{
+		{"", nil, false}, // not a terminal
+		{"1", nil, false},
+		{"1", &[]bool{true}[0], true},
+		{"", &[]bool{true}[0], true},
+		{"", &[]bool{false}[0], false},
+	} {
+		if tt.noColor == "" {
+			os.Unsetenv("NO_COLOR")
+		} else {
+			os.Setenv("NO_COLOR", tt.noColor)
+		}
+		forceColor = nil
+		if tt.force != nil {
+			ForceColor(*tt.force)
+		}
+		assert.Equal(t, tt.want, enabled(), "case %d", i)
+	}
+}
+
+func TestPromptFlag(t *testing.T) {
+	type argT struct {
+		Name     string `cli:"*name" prompt:"Enter your name"`
+		Password string `pw:"*p,password" prompt:"Password"`
+		Age      int    `cli:"age" prompt:"Age"`
+		Agree    bool   `cli:"agree" prompt:"Agree"`
+	}
+	defer func(r io.Reader, w io.Writer) { PromptReader, PromptWriter = r, w }(PromptReader, PromptWriter)
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args   []string
+		input  string
+		want   argT
+		output string
+		err    string
+	}{
+		{
+			input:  "tom\nsecret\n18\ny\n",
+			want:   argT{Name: "tom", Password: "secret", Age: 18, Agree: true},
+			output: "Enter your name: Password: \nAge: Agree: ",
+		},
+		{
+			args:   []string{"--name=jerry", "--agree"},
+			input:  "secret\n\n",
+			want:   argT{Name: "jerry", Password: "secret", Agree: true},
+			output: "Password: \nAge: ",
+		},
+		{
+			input:  "tom\n",
+			output: "Enter your name: Password: \n",
+			err:    "required parameter --password missing",
+		},
+		{
+			args:  []string{"--name=tom", "-p", "x"},
+			input: "abc\n",
+			err:   "parameter --age invalid: `abc' couldn't converted to an int",
+		},
+	} {
+		var (
+			v = new(argT)
+			w = bytes.NewBufferString("")
+		)
+		PromptReader, PromptWriter = strings.NewReader(tt.input), w
+		flagSet := parseArgv(tt.args, v, clr)
+		if tt.err != "" {
+			if assert.Error(t, flagSet.err, "case %d", i) {
+				assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+			}
+		} else if assert.Nil(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+		if tt.output != "" {
+			assert.Equal(t, tt.output, w.String(), "case %d", i)
+			assert.NotContains(t, w.String(), "secret", "case %d", i)
+		}
+	}
+
+	// not a terminal
+	f, err := ioutil.TempFile("", "prompt")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString("tom\n")
+	f.Seek(0, 0)
+	w := bytes.NewBufferString("")
+	PromptReader, PromptWriter = f, w
+	flagSet := parseArgv([]string{"-p", "x"}, new(argT), clr)
+	if assert.Error(t, flagSet.err) {
+		assert.Equal(t, "required parameter --name missing", flagSet.err.Error())
+	}
+	assert.Equal(t, "", w.String())
+}
+
+func TestCombinedShortFlags(t *testing.T) {
+	type argT struct {
+		A       bool   `cli:"a"`
+		B       bool   `cli:"b"`
+		Verbose int    `cli:"v" count:"true"`
+		Output  string `cli:"o"`
+		N       int    `cli:"n"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want argT
+		free []string
+		err  string
+	}{
+		{args: []string{"-ab"}, want: argT{A: true, B: true}},
+		{args: []string{"-bavv"}, want: argT{A: true, B: true, Verbose: 2}},
+		{args: []string{"-ofile"}, want: argT{Output: "file"}},
+		{args: []string{"-abofile"}, want: argT{A: true, B: true, Output: "file"}},
+		{args: []string{"-aofile", "x"}, want: argT{A: true, Output: "file"}, free: []string{"x"}},
+		{args: []string{"-ao=file"}, want: argT{A: true, Output: "file"}},
+		{args: []string{"-ao", "file", "x"}, want: argT{A: true, Output: "file"}, free: []string{"x"}},
+		{args: []string{"-vn3"}, want: argT{Verbose: 1, N: 3}},
+		{args: []string{"-axb"}, err: "undefined option -x"},
+		{args: []string{"-ao"}, err: "parameter -o invalid: missing value"},
+		{args: []string{"-an3x"}, err: "parameter -n invalid: `3x' couldn't converted to an int"},
+		{args: []string{"-ab=true"}, err: "parameter -b doesn't accept a value"},
+	} {
+		v := new(argT)
+		flagSet := parseArgv(tt.args, v, clr)
+		if tt.err != "" {
+			if assert.Error(t, flagSet.err, "case %d", i) {
+				assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+			if tt.free == nil {
+				tt.free = []string{}
+			}
+			assert.Equal(t, tt.free, flagSet.args, "case %d", i)
+		}
+	}
+}
+
+func TestUsageDefaultInDesc(t *testing.T) {
+	type argT struct {
+		Port   int               `cli:"p,port" usage:"listening port" dft:"8000+80"`
+		Tags   []string          `cli:"tag" usage:"tags" dft:"a,b" sep:","`
+		Labels map[string]string `cli:"label" usage:"labels" dft:"y=2,x=1"`
+		Token  string            `cli:"token" usage:"api token" secret:"true" dft:"abc"`
+		Old    string            `cli:"old" usage:"old flag" dft:"x" deprecated:"use --new"`
+		Name   string            `cli:"name" usage:"no default"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	UsageDefaultInDesc = true
+	defer func() { UsageDefaultInDesc = false }()
+	assert.Equal(t, `  -p, --port    listening port (default: 8080)
+      --tag     tags (default: a,b)
+      --label   labels (default: x=1,y=2)
+      --token   api token (default: ****)
+      --old     old flag (deprecated: use --new) (default: x)
+      --name    no default
+`, usage([]interface{}{new(argT)}, clr, NormalStyle))
+	assert.Contains(t, usage([]interface{}{new(argT)}, clr, ManualStyle), "  -p, --port\n      listening port (default: 8080)\n")
+}
+
+func TestAbbrevFlags(t *testing.T) {
+	type argT struct {
+		Verbose bool   `cli:"v,verbose"`
+		Version bool   `cli:"version"`
+		Name    string `cli:"name,nickname"`
+		Number  int    `cli:"number"`
+	}
+	for i, tt := range []struct {
+		args   []string
+		abbrev bool
+		want   argT
+		err    string
+	}{
+		{args: []string{"--verb"}, abbrev: true, want: argT{Verbose: true}},
+		{args: []string{"--vers"}, abbrev: true, want: argT{Version: true}},
+		{args: []string{"--na", "x"}, abbrev: true, want: argT{Name: "x"}},
+		{args: []string{"--nick=x"}, abbrev: true, want: argT{Name: "x"}},
+		{args: []string{"--num", "-3"}, abbrev: true, want: argT{Number: -3}},
+		{args: []string{"--version"}, abbrev: true, want: argT{Version: true}},
+		{args: []string{"--ver"}, abbrev: true, err: "ambiguous option --ver, candidates: --verbose, --version"},
+		{args: []string{"--n", "x"}, abbrev: true, err: "ambiguous option --n, candidates: --name, --number"},
+		{args: []string{"--verb"}, err: "undefined option --verb"},
+	} {
+		argv := new(argT)
+		clr := color.Color{}
+		clr.Disable()
+		ctx := &Context{
+			command:    &Command{AbbrevFlags: tt.abbrev},
+			argvList:   []interface{}{argv},
+			nativeArgs: tt.args,
+			color:      clr,
+		}
+		err := ctx.parse(nil)
+		if tt.err != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.err, err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *argv, "case %d", i)
+		}
+	}
+
+	// inherited by descendants
+	argv := new(struct {
+		Verbose bool `cli:"verbose"`
+	})
+	root := &Command{AbbrevFlags: true}
+	root.Register(&Command{
+		Name: "sub",
+		Argv: func() interface{} { return argv },
+		Fn:   donothing,
+	})
+	assert.Nil(t, root.Run([]string{"sub", "--verb"}))
+	assert.True(t, argv.Verbose)
+}
+
+func TestIgnoreUnknownFlags(t *testing.T) {
+	type argT struct {
+		Name    string `cli:"name"`
+		Verbose bool   `cli:"v"`
+		N       int    `cli:"n"`
+	}
+	for i, tt := range []struct {
+		args    []string
+		ignore  bool
+		want    argT
+		unknown []string
+		free    []string
+		err     string
+	}{
+		{
+			args:    []string{"--foo=1", "--name", "a", "-x", "-v", "--bar", "b", "-n3"},
+			ignore:  true,
+			want:    argT{Name: "a", Verbose: true, N: 3},
+			unknown: []string{"--foo=1", "-x", "--bar"},
+			free:    []string{"b"},
+		},
+		{
+			args:    []string{"-vxy", "--name=a"},
+			ignore:  true,
+			want:    argT{Name: "a", Verbose: true},
+			unknown: []string{"-xy"},
+			free:    []string{},
+		},
+		{
+			args:   []string{"--name", "a"},
+			ignore: true,
+			want:   argT{Name: "a"},
+			free:   []string{},
+		},
+		{args: []string{"--foo=1", "--name", "a"}, err: "undefined option --foo"},
+		{args: []string{"--name", "a", "-x"}, err: "undefined option -x"},
+	} {
+		var (
+			argv = new(argT)
+			ctx  *Context
+		)
+		err := (&Command{
+			Name:               "app",
+			Argv:               func() interface{} { return argv },
+			CanSubRoute:        true,
+			IgnoreUnknownFlags: tt.ignore,
+			Fn: func(c *Context) error {
+				ctx = c
+				return nil
+			},
+		}).RunWith(tt.args, bytes.NewBufferString(""), nil)
+		if tt.err != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Contains(t, err.Error(), tt.err, "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *argv, "case %d", i)
+			assert.Equal(t, tt.unknown, ctx.UnknownFlags(), "case %d", i)
+			assert.Equal(t, tt.free, ctx.Args(), "case %d", i)
+		}
+	}
+}
@@ -14,8 +14,28 @@ import (
 	"github.com/mattn/go-isatty"
 )
 
+// forceColor overrides detection of color if it's not nil
+var forceColor *bool
+
+// ForceColor enables or disables color regardless of NO_COLOR and terminal,
+// e.g. ForceColor(true) for piping to `less -R`
+func ForceColor(enabled bool) {
+	forceColor = &enabled
+}
+
+// colorSwitch enables color if w (or fds[0]) is a terminal, and NO_COLOR
+// not set (see https://no-color.org)
 func colorSwitch(clr *color.Color, w io.Writer, fds ...uintptr) {
 	clr.Disable()
+	if forceColor != nil {
+		if *forceColor {
+			clr.Enable()
+		}
+		return
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return
+	}
 	if len(fds) > 0 {
 		if isatty.IsTerminal(fds[0]) {
 			clr.Enable()
@@ -2,17 +2,22 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/exec"
+	"reflect"
 	"regexp"
+	"runtime"
+	runtimedebug "runtime/debug"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/labstack/gommon/color"
-	"github.com/mattn/go-colorable"
 	"github.com/mkideal/pkg/debug"
 )
 
@@ -30,11 +35,25 @@ type (
 	// ArgvFunc ...
 	ArgvFunc func() interface{}
 
+	// ArgvContextFunc creates argv object with context of parent command,
+	// whose global argv objects are parsed already, see Command.ArgvContext
+	ArgvContextFunc func(*Context) interface{}
+
 	// NumCheckFunc represents function type which used to check num of args
 	NumCheckFunc func(n int) bool
 
 	// UsageFunc represents custom function of usage
 	UsageFunc func() string
+
+	// Middleware wraps CommandFunc, e.g.
+	//
+	//	func logging(next cli.CommandFunc) cli.CommandFunc {
+	//		return func(ctx *cli.Context) error {
+	//			log.Printf("run %s", ctx.Path())
+	//			return next(ctx)
+	//		}
+	//	}
+	Middleware func(next CommandFunc) CommandFunc
 )
 
 // ExactN returns a NumCheckFunc which checks if a number is equal to num
@@ -69,6 +88,68 @@ type (
 		// Global indicates whether it's argv object should be used to sub-command
 		Global bool
 
+		// GlobalFlags of root command creates argv object whose flags are parsed
+		// for root and all descendants, see Context.Global. Flags of commands
+		// registered to the tree mustn't collide with global flags.
+		GlobalFlags ArgvFunc
+
+		// Examples are shown in section "Examples" of usage
+		Examples []Example
+
+		// ExternalCommandPrefix of root command makes unknown commands dispatched
+		// to executables in PATH like git, e.g. `app foo -x` runs `app-foo -x`
+		// if prefix is "app", and exit code of the executable propagated.
+		ExternalCommandPrefix string
+
+		// AbbrevFlags makes long flags of the command and its descendants
+		// matched by unambiguous prefix, e.g. `--ver` for `--verbose`.
+		// Ambiguous prefix is an error.
+		AbbrevFlags bool
+
+		// IgnoreUnknownFlags makes unknown flags collected rather than an
+		// error, see Context.UnknownFlags. Value of unknown flag should be
+		// joined by "=", e.g. `--foo=1`, or it's parsed as a free arg.
+		IgnoreUnknownFlags bool
+
+		// Hidden indicates whether the command omitted from usage and completion,
+		// it's still dispatchable when explicitly invoked
+		Hidden bool
+
+		// HelpFlag makes `-h` and `--help` show usage of the command and its
+		// descendants without running Fn, unless they are flags of argv.
+		// It's enabled for root by Root and Run. Root which has HelpFlag and
+		// children also supports builtin command `help <command path>`,
+		// unless it has a child named "help".
+		HelpFlag bool
+
+		// Version of root command is printed by `-V` or `--version` unless they
+		// are flags of argv, Commit and BuildDate are optional build metadata,
+		// see VersionString
+		Version   string
+		Commit    string
+		BuildDate string
+
+		// CancelOnSignal makes context.Context of Context canceled on SIGINT or
+		// SIGTERM while running the command, see Context.GoContext
+		CancelOnSignal bool
+
+		// Deprecated is guidance shown while the command invoked, e.g.
+		// "use `app new` instead". Deprecated command still works.
+		Deprecated string
+
+		// LoadDefaults loads default layers of flags, e.g. config file, after
+		// command line parsed but before prompts and checks of flags, so that
+		// path of config file could be a flag, e.g.
+		//
+		//	LoadDefaults: func(ctx *cli.Context) error {
+		//		return ctx.LoadConfigOptional(ctx.Argv().(*argT).Config)
+		//	},
+		//
+		// Values loaded by Context.LoadConfig satisfy required flags, and are
+		// checked the same way as command line. LoadDefaults of the nearest
+		// ancestor is used if the command has none.
+		LoadDefaults func(*Context) error
+
 		// functions
 		Fn        CommandFunc  // Command handler
 		UsageFn   UsageFunc    // Custom usage function
@@ -76,9 +157,39 @@ type (
 		NumArg    NumCheckFunc // NumArg check number of args
 		NumOption NumCheckFunc // NumOption check num of options
 
+		// MinArgs and MaxArgs check number of free args after parsing, an
+		// error like "expected 2 args, got 1" returned with usage appended.
+		// 0 means not configured so unchecked, and MaxArgs -1 means unlimited
+		// too, use NumArg ExactN(0) to refuse any args. Negative MinArgs,
+		// MaxArgs less than -1, or MinArgs greater than positive MaxArgs is
+		// an error of TryRegister and Run.
+		MinArgs int
+		MaxArgs int
+
+		// ArgvContext is used instead of Argv if Argv is nil, it receives
+		// context of parent in which flags of global ancestors parsed from
+		// args of the command, e.g. default of child derived from parent flag
+		//
+		//	ArgvContext: func(ctx *cli.Context) interface{} {
+		//		parent := ctx.Argv().(*parentT)
+		//		return &childT{Dir: parent.Root + "/build"}
+		//	},
+		//
+		// Unknown flags are ignored while parsing flags of parent, and the
+		// context parsed from empty args while rendering usage.
+		ArgvContext ArgvContextFunc
+
 		HTTPRouters []string
 		HTTPMethods []string
 
+		// BufferedOutput makes output of Context buffered while running the
+		// command, it's flushed after the command completed even if panicked,
+		// see Context.EnableBuffer
+		BufferedOutput bool
+
+		// Stderr is writer for errors and usage on failure, default is stderr
+		Stderr io.Writer
+
 		// hooks for current command
 		OnBefore func(*Context) error
 		OnAfter  func(*Context) error
@@ -88,16 +199,34 @@ type (
 		OnRootBefore       func(*Context) error
 		OnRootAfter        func(*Context) error
 
-		routersMap map[string]string
+		// hooks for current command and all descendants, parent's PreRun runs
+		// before child's and parent's PostRun runs after child's.
+		// PostRun receives the error returned by command and could wrap or suppress it.
+		PreRun  func(*Context) error
+		PostRun func(*Context, error) error
+
+		routersMap  map[string]string
+		middlewares []Middleware
 
 		parent   *Command
 		children []*Command
 
 		isServer bool
 
-		locker     sync.Mutex // protect following data
-		usage      string
-		usageStyle UsageStyle
+		locker      sync.Mutex // protect following data
+		usage       string
+		usageStyle  UsageStyle
+		usageWidth  int
+		lastContext *Context
+	}
+
+	// Example is an example of command shown in usage, `./app` in Command is
+	// replaced with name of root command if it's set, e.g.
+	//
+	//	Example{Command: "./app deploy --env prod", Desc: "deploy to production"}
+	Example struct {
+		Command string
+		Desc    string
 	}
 
 	// CommandTree represents a tree of commands
@@ -107,34 +236,61 @@ type (
 	}
 )
 
-// Register registers a child command
+// Register registers a child command, it panics if TryRegister returns an error
 func (cmd *Command) Register(child *Command) *Command {
+	if _, err := cmd.TryRegister(child); err != nil {
+		debug.Panicf("%v", err)
+	}
+	return child
+}
+
+// TryRegister registers a child command, an error returned if child is nil,
+// name or aliases of child are invalid, or name and aliases collide with siblings
+func (cmd *Command) TryRegister(child *Command) (*Command, error) {
 	if child == nil {
-		debug.Panicf("command `%s` try register a nil command", cmd.Name)
+		return nil, fmt.Errorf("command `%s` try register a nil command", cmd.Name)
 	}
 	if !IsValidCommandName(child.Name) {
-		debug.Panicf("illegal command name `%s`", cmd.Name)
-	}
-	if cmd.children == nil {
-		cmd.children = []*Command{}
+		return nil, fmt.Errorf("illegal command name `%s`", child.Name)
 	}
 	if child.parent != nil {
-		debug.Panicf("command `%s` has been child of `%s`", child.Name, child.parent.Name)
+		return nil, fmt.Errorf("command `%s` has been child of `%s`", child.Name, child.parent.Name)
+	}
+	if err := child.checkArgRange(); err != nil {
+		return nil, err
 	}
 	if cmd.findChild(child.Name) != nil {
-		debug.Panicf("repeat register child `%s` for command `%s`", child.Name, cmd.Name)
+		return nil, fmt.Errorf("repeat register child `%s` for command `%s`", child.Name, cmd.Name)
 	}
-	if child.Aliases != nil {
-		for _, alias := range child.Aliases {
-			if cmd.findChild(alias) != nil {
-				debug.Panicf("repeat register child `%s` for command `%s`", alias, cmd.Name)
+	for i, alias := range child.Aliases {
+		if !IsValidCommandName(alias) {
+			return nil, fmt.Errorf("illegal alias `%s` of command `%s`", alias, child.Name)
+		}
+		if cmd.findChild(alias) != nil {
+			return nil, fmt.Errorf("repeat register child `%s` for command `%s`", alias, cmd.Name)
+		}
+		for _, prev := range append([]string{child.Name}, child.Aliases[:i]...) {
+			if prev == alias {
+				return nil, fmt.Errorf("repeat alias `%s` of command `%s`", alias, child.Name)
 			}
 		}
 	}
+	if global := cmd.globalArgv(); global != nil {
+		cmds := []*Command{child}
+		if cmd.parent == nil && len(cmd.children) == 0 {
+			cmds = append(cmds, cmd)
+		}
+		if err := checkGlobalFlags(global, cmds...); err != nil {
+			return nil, err
+		}
+	}
+	if cmd.children == nil {
+		cmd.children = []*Command{}
+	}
 	cmd.children = append(cmd.children, child)
 	child.parent = cmd
 
-	return child
+	return child, nil
 }
 
 // RegisterFunc registers handler as child command
@@ -152,6 +308,21 @@ func (cmd *Command) RegisterTree(forest ...*CommandTree) {
 	}
 }
 
+// TryRegisterTree is similar to RegisterTree, but returns an error instead of panic
+func (cmd *Command) TryRegisterTree(forest ...*CommandTree) error {
+	for _, tree := range forest {
+		if _, err := cmd.TryRegister(tree.command); err != nil {
+			return err
+		}
+		if tree.forest != nil && len(tree.forest) > 0 {
+			if err := tree.command.TryRegisterTree(tree.forest...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Parent returns command's parent
 func (cmd *Command) Parent() *Command {
 	return cmd.parent
@@ -179,9 +350,71 @@ func (cmd *Command) Run(args []string) error {
 
 // RunWith runs the command with args and writer,httpMethods
 func (cmd *Command) RunWith(args []string, writer io.Writer, resp http.ResponseWriter, httpMethods ...string) error {
+	return cmd.runWith(context.Background(), args, writer, resp, runOptions{}, httpMethods...)
+}
+
+// RunWithGoContext runs the command with goCtx which is returned by
+// Context.GoContext, commands could observe cancellation of goCtx
+func (cmd *Command) RunWithGoContext(goCtx context.Context, args []string, writer io.Writer) error {
+	return cmd.runWith(goCtx, args, writer, nil, runOptions{})
+}
+
+// LastContext returns Context of the last run of the command, it's context of
+// the deepest invoked sub-command, e.g.
+//
+//	root.Run([]string{"sub", "--name=x"})
+//	ctx := root.LastContext() // ctx.Path() == "sub"
+//
+// Context is returned even though parsing failed or command returned an error,
+// but nil returned if routing failed or command never run.
+func (cmd *Command) LastContext() *Context {
+	cmd.locker.Lock()
+	defer cmd.locker.Unlock()
+	return cmd.lastContext
+}
+
+// RunOption replaces standard IO of command, see RunWithOptions
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// WithStdin sets reader of prompts, it's used by Context.Prompt, Context.Confirm
+// and flags which have tag `prompt`, instead of PromptReader
+func WithStdin(r io.Reader) RunOption {
+	return func(opts *runOptions) { opts.stdin = r }
+}
+
+// WithStdout sets writer of Context and prompts of flags, instead of stdout
+func WithStdout(w io.Writer) RunOption {
+	return func(opts *runOptions) { opts.stdout = w }
+}
+
+// WithStderr sets writer for errors, it overrides Stderr of command
+func WithStderr(w io.Writer) RunOption {
+	return func(opts *runOptions) { opts.stderr = w }
+}
+
+// RunWithOptions runs the command with args, standard IO replaced by options,
+// e.g.
+//
+//	var out bytes.Buffer
+//	err := cmd.RunWithOptions(args, cli.WithStdin(strings.NewReader("y\n")), cli.WithStdout(&out))
+func (cmd *Command) RunWithOptions(args []string, opts ...RunOption) error {
+	var o runOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return cmd.runWith(context.Background(), args, o.stdout, nil, o)
+}
+
+func (cmd *Command) runWith(goCtx context.Context, args []string, writer io.Writer, resp http.ResponseWriter, opts runOptions, httpMethods ...string) error {
 	fds := []uintptr{}
 	if writer == nil {
-		writer = colorable.NewColorableStdout()
+		writer = newStdoutWriter()
 		fds = append(fds, os.Stdout.Fd())
 	}
 	clr := color.Color{}
@@ -189,10 +422,18 @@ func (cmd *Command) RunWith(args []string, writer io.Writer, resp http.ResponseW
 
 	var ctx *Context
 	var suggestion string
-	ctx, suggestion, err := cmd.prepare(clr, args, writer, resp, httpMethods...)
+	ctx, suggestion, err := cmd.prepare(clr, args, writer, resp, opts, httpMethods...)
+	cmd.locker.Lock()
+	cmd.lastContext = ctx
+	cmd.locker.Unlock()
 	if err == ExitError {
 		return nil
 	}
+	if _, ok := err.(commandNotFoundError); ok && resp == nil {
+		if found, err := cmd.runExternal(args, writer, opts); found {
+			return err
+		}
+	}
 
 	if err != nil {
 		if cmd.OnRootPrepareError != nil {
@@ -203,15 +444,70 @@ func (cmd *Command) RunWith(args []string, writer io.Writer, resp http.ResponseW
 		}
 		return nil
 	}
+	ctx.goCtx = goCtx
+	if cmd.CancelOnSignal || ctx.command.CancelOnSignal {
+		stop := ctx.cancelOnSignal(os.Interrupt, syscall.SIGTERM)
+		defer stop()
+	}
+	return cmd.run(ctx)
+}
 
+// run runs hooks and Fn of command of ctx
+func (cmd *Command) run(ctx *Context) (err error) {
+	if RecoverPanics {
+		defer func() {
+			if v := recover(); v != nil {
+				err = ctx.panicError(v, runtimedebug.Stack())
+			}
+		}()
+	}
+	if ctx.command.BufferedOutput {
+		ctx.EnableBuffer()
+		defer ctx.Flush()
+	}
+	defer ctx.logTimings()
 	if ctx.command.NoHook {
-		return ctx.command.Fn(ctx)
+		return ctx.command.handler()(ctx)
 	}
 
+	// PreRun hooks run from root to current command, PostRun hooks run reversely.
+	// A PreRun error skips the rest, and only PostRun hooks of commands
+	// whose PreRun succeeded would be called.
+	var chain []*Command
+	for c := ctx.command; c != nil; c = c.parent {
+		chain = append([]*Command{c}, chain...)
+	}
+	entered := 0
+	for _, c := range chain {
+		if c.PreRun != nil {
+			if err = c.PreRun(ctx); err != nil {
+				break
+			}
+		}
+		entered++
+	}
+	if err == nil {
+		err = cmd.runFuncs(ctx)
+	}
+	for i := entered - 1; i >= 0; i-- {
+		if err == ExitError {
+			err = nil
+		}
+		if chain[i].PostRun != nil {
+			err = chain[i].PostRun(ctx, err)
+		}
+	}
+	if err == ExitError {
+		return nil
+	}
+	return err
+}
+
+func (cmd *Command) runFuncs(ctx *Context) error {
 	funcs := []func(*Context) error{
 		ctx.command.OnBefore,
 		cmd.OnRootBefore,
-		ctx.command.Fn,
+		ctx.command.handler(),
 		cmd.OnRootAfter,
 		ctx.command.OnAfter,
 	}
@@ -228,6 +524,37 @@ func (cmd *Command) RunWith(args []string, writer io.Writer, resp http.ResponseW
 	return nil
 }
 
+// loadDefaultsFunc returns LoadDefaults of the command or the nearest ancestor
+func (cmd *Command) loadDefaultsFunc() func(*Context) error {
+	for c := cmd; c != nil; c = c.parent {
+		if c.LoadDefaults != nil {
+			return c.LoadDefaults
+		}
+	}
+	return nil
+}
+
+// Use registers middlewares for the command and all descendants, middlewares
+// of parent wrap middlewares of child, and middlewares run in order of registration.
+func (cmd *Command) Use(middlewares ...Middleware) *Command {
+	cmd.middlewares = append(cmd.middlewares, middlewares...)
+	return cmd
+}
+
+// handler returns Fn wrapped by middlewares of the command and ancestors
+func (cmd *Command) handler() CommandFunc {
+	fn := cmd.Fn
+	if fn == nil {
+		return nil
+	}
+	for c := cmd; c != nil; c = c.parent {
+		for i := len(c.middlewares) - 1; i >= 0; i-- {
+			fn = c.middlewares[i](fn)
+		}
+	}
+	return fn
+}
+
 func isEmptyArgvList(argvList []interface{}) bool {
 	if argvList == nil {
 		return true
@@ -241,25 +568,247 @@ func isEmptyArgvList(argvList []interface{}) bool {
 }
 
 func (cmd *Command) argvList() []interface{} {
-	argvList := make([]interface{}, 0, 1)
+	clr := color.Color{}
+	clr.Disable()
+	return cmd.argvListWith(nil, clr)
+}
+
+// argvListWith is similar to argvList, but argv created by ArgvContext
+// with context parsed from args
+func (cmd *Command) argvListWith(args []string, clr color.Color) []interface{} {
+	ancestorList, _ := cmd.ancestorArgvListsWith(args, clr)
+	return append([]interface{}{cmd.newArgv(args, clr)}, ancestorList...)
+}
+
+// ancestorArgvList returns argv objects of global ancestors from parent to
+// root, nil for others
+func (cmd *Command) ancestorArgvList() []interface{} {
+	clr := color.Color{}
+	clr.Disable()
+	ancestorList, _ := cmd.ancestorArgvListsWith(nil, clr)
+	return ancestorList
+}
+
+// ancestorArgvListsWith returns argv objects of ancestors from parent to
+// root, ancestorList has argv of global ancestors and nil for others, and
+// persistentList has argv of other ancestors which have flags. Argv of
+// ancestors declared by ArgvContext created with contexts parsed from args,
+// the contexts are created top-down from root, so each of them created once
+// and parents declared by ArgvContext don't recurse.
+func (cmd *Command) ancestorArgvListsWith(args []string, clr color.Color) (ancestorList, persistentList []interface{}) {
+	var ancestors []*Command
+	for next := cmd.parent; next != nil; next = next.parent {
+		ancestors = append(ancestors, next)
+	}
+	contexts := make([]*Context, len(ancestors))
+	lists := func(from int) (ancestorList, persistentList []interface{}) {
+		for i := from; i < len(ancestors); i++ {
+			var argv interface{}
+			if next := ancestors[i]; next.Argv != nil {
+				argv = next.Argv()
+			} else if next.ArgvContext != nil {
+				argv = next.ArgvContext(contexts[i])
+			}
+			if ancestors[i].Global {
+				ancestorList = append(ancestorList, argv)
+				continue
+			}
+			ancestorList = append(ancestorList, nil)
+			if argv == nil {
+				continue
+			}
+			if fs := usageFlagSet(nil, color.Color{}, argv); fs.err == nil && len(fs.flagSlice) > 0 {
+				persistentList = append(persistentList, argv)
+			}
+		}
+		return
+	}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		if ancestors[i].Argv == nil && ancestors[i].ArgvContext != nil {
+			ancestorList, persistentList := lists(i + 1)
+			contexts[i] = ancestors[i].parentContextOf(args, ancestorList, persistentList, clr)
+		}
+	}
+	return lists(0)
+}
+
+// newArgv creates argv object by Argv or ArgvContext, nil returned if
+// both are nil
+func (cmd *Command) newArgv(args []string, clr color.Color) interface{} {
 	if cmd.Argv != nil {
-		argvList = append(argvList, cmd.Argv())
-	} else {
-		argvList = append(argvList, nil)
+		return cmd.Argv()
 	}
-	next := cmd.parent
-	for next != nil {
-		if next.Argv != nil && next.Global {
-			argvList = append(argvList, next.Argv())
-		} else {
-			argvList = append(argvList, nil)
+	if cmd.ArgvContext == nil {
+		return nil
+	}
+	return cmd.ArgvContext(cmd.parentContext(args, clr))
+}
+
+// parentContext creates context of parent, flags of global ancestors and
+// inherited persistent flags parsed from args, unknown flags ignored
+func (cmd *Command) parentContext(args []string, clr color.Color) *Context {
+	ancestorList, persistentList := cmd.ancestorArgvListsWith(args, clr)
+	return cmd.parentContextOf(args, ancestorList, persistentList, clr)
+}
+
+// parentContextOf creates context of parent like parentContext by argv
+// objects of ancestors, see ancestorArgvListsWith
+func (cmd *Command) parentContextOf(args []string, ancestorList, persistentList []interface{}, clr color.Color) *Context {
+	ctx := &Context{
+		nativeArgs: args,
+		global:     cmd.globalArgv(),
+		color:      clr,
+	}
+	if cmd.parent != nil {
+		ctx.command = cmd.parent
+		ctx.path = cmd.parent.Path()
+		ctx.argvList = ancestorList
+	}
+	flagSet := newFlagSet()
+	flagSet.skipUnknown = true
+	ctx.flagSet = parseArgvListTo(flagSet, args, withGlobalArgv(ctx.argvList, ctx.global), clr, persistentList...)
+	return ctx
+}
+
+// runExternal runs executable `<prefix>-<commands>` in PATH for unknown
+// command of args, found is false if root has no ExternalCommandPrefix or
+// the executable not found. Error returned carries exit code of the executable.
+func (cmd *Command) runExternal(args []string, writer io.Writer, opts runOptions) (found bool, err error) {
+	root := cmd.Root()
+	if root.ExternalCommandPrefix == "" {
+		return false, nil
+	}
+	router := []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, dashOne) {
+			break
+		}
+		router = append(router, arg)
+	}
+	_, end := cmd.SubRoute(router)
+	if end >= len(router) {
+		return false, nil
+	}
+	name := strings.Join(append([]string{root.ExternalCommandPrefix}, router[:end+1]...), "-")
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return false, nil
+	}
+	external := exec.Command(path, args[end+1:]...)
+	external.Stdin, external.Stdout, external.Stderr = opts.stdin, writer, opts.stderr
+	if external.Stdin == nil {
+		external.Stdin = os.Stdin
+	}
+	if external.Stderr == nil {
+		external.Stderr = root.Stderr
+	}
+	if external.Stderr == nil {
+		external.Stderr = os.Stderr
+	}
+	if err := external.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return true, NewExitError(exitErr.ExitCode(), "")
+		}
+		return true, err
+	}
+	return true, nil
+}
+
+// globalArgv creates argv object by GlobalFlags of root, nil returned if
+// root has no GlobalFlags
+func (cmd *Command) globalArgv() interface{} {
+	root := cmd.Root()
+	if root.GlobalFlags == nil {
+		return nil
+	}
+	return root.GlobalFlags()
+}
+
+// abbrevFlags reports whether AbbrevFlags of the command or an ancestor is true
+func (cmd *Command) abbrevFlags() bool {
+	for c := cmd; c != nil; c = c.parent {
+		if c.AbbrevFlags {
+			return true
+		}
+	}
+	return false
+}
+
+// withGlobalArgv appends global to a copy of argvList if global isn't nil,
+// flags of global are parsed like flags of root
+func withGlobalArgv(argvList []interface{}, global interface{}) []interface{} {
+	if global == nil {
+		return argvList
+	}
+	return append(append(make([]interface{}, 0, len(argvList)+1), argvList...), global)
+}
+
+// checkGlobalFlags returns an error if flags of cmds or their descendants
+// collide with flags of global
+func checkGlobalFlags(global interface{}, cmds ...*Command) error {
+	clr := color.Color{}
+	clr.Disable()
+	globalSet := usageFlagSet([]interface{}{global}, clr)
+	if globalSet.err != nil {
+		return globalSet.err
+	}
+	for len(cmds) > 0 {
+		cmd := cmds[0]
+		cmds = append(cmds[1:], cmd.children...)
+		argv := cmd.newArgv(nil, clr)
+		if argv == nil {
+			continue
+		}
+		// invalid argv reported while running
+		flagSet := usageFlagSet([]interface{}{argv}, clr)
+		for _, fl := range flagSet.flagSlice {
+			for _, name := range append(append([]string{}, fl.tag.shortNames...), fl.tag.longNames...) {
+				if _, ok := globalSet.flagMap[name]; ok {
+					return fmt.Errorf("flag %s of command `%s` collides with global flag", name, cmd.Name)
+				}
+			}
 		}
-		next = next.parent
 	}
-	return argvList
+	return nil
+}
+
+// persistentArgvList returns argv objects of ancestors which aren't global
+// but have persistent flags, these flags are inherited by cmd
+func (cmd *Command) persistentArgvList() []interface{} {
+	clr := color.Color{}
+	clr.Disable()
+	return cmd.persistentArgvListWith(nil, clr)
+}
+
+// persistentArgvListWith is similar to persistentArgvList, but argv created
+// by ArgvContext with context parsed from args
+func (cmd *Command) persistentArgvListWith(args []string, clr color.Color) []interface{} {
+	_, persistentList := cmd.ancestorArgvListsWith(args, clr)
+	return persistentList
 }
 
-func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, resp http.ResponseWriter, httpMethods ...string) (ctx *Context, suggestion string, err error) {
+// suggestionOf formats suggestions of command path which isn't found
+func (cmd *Command) suggestionOf(path string, clr color.Color) string {
+	suggestions := cmd.Suggestions(path)
+	buff := bytes.NewBufferString("")
+	if len(suggestions) == 1 {
+		fmt.Fprintf(buff, "\nDid you mean \"%s\"?", clr.Bold(suggestions[0]))
+	} else if len(suggestions) > 1 {
+		fmt.Fprintf(buff, "\n\nDid you mean one of these?\n")
+		for _, sug := range suggestions {
+			fmt.Fprintf(buff, "    %s\n", sug)
+		}
+	}
+	return buff.String()
+}
+
+// hasHelpCommand reports whether builtin command `help` is available, it's
+// available for root which has HelpFlag and children but no child `help`
+func (cmd *Command) hasHelpCommand() bool {
+	return cmd.parent == nil && cmd.HelpFlag && !cmd.nochild() && cmd.findChild("help") == nil
+}
+
+func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, resp http.ResponseWriter, opts runOptions, httpMethods ...string) (ctx *Context, suggestion string, err error) {
 	// split args
 	router := []string{}
 	for _, arg := range args {
@@ -269,23 +818,42 @@ func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, re
 		router = append(router, arg)
 	}
 	path := strings.Join(router, " ")
+	errWriter := cmd.Stderr
+	if opts.stderr != nil {
+		errWriter = opts.stderr
+	}
+
+	// `help <command path>`
+	if cmd.hasHelpCommand() && resp == nil && len(router) > 0 && router[0] == "help" {
+		target, end := cmd.SubRoute(router[1:])
+		if end != len(router)-1 {
+			path = strings.Join(router[1:], " ")
+			suggestion = cmd.suggestionOf(path, clr)
+			err = throwCommandNotFound(clr.Yellow(path))
+			return
+		}
+		ctx = &Context{
+			path:       target.Path(),
+			router:     router[1:],
+			argvList:   target.argvList(),
+			nativeArgs: args[len(router):],
+			flagSet:    newFlagSet(),
+			command:    target,
+			writer:     writer,
+			errWriter:  errWriter,
+			reader:     opts.stdin,
+			color:      clr,
+		}
+		ctx.WriteUsage()
+		err = ExitError
+		return
+	}
+
 	child, end := cmd.SubRoute(router)
 
 	// if route fail
 	if !child.CanSubRoute && end != len(router) {
-		suggestions := cmd.Suggestions(path)
-		buff := bytes.NewBufferString("")
-		if suggestions != nil && len(suggestions) > 0 {
-			if len(suggestions) == 1 {
-				fmt.Fprintf(buff, "\nDid you mean %s?", clr.Bold(suggestions[0]))
-			} else {
-				fmt.Fprintf(buff, "\n\nDid you mean one of these?\n")
-				for _, sug := range suggestions {
-					fmt.Fprintf(buff, "    %s\n", sug)
-				}
-			}
-		}
-		suggestion = buff.String()
+		suggestion = cmd.suggestionOf(path, clr)
 		err = throwCommandNotFound(clr.Yellow(path))
 		return
 	}
@@ -310,16 +878,53 @@ func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, re
 	}
 
 	// create argvList
-	argvList := child.argvList()
+	argvList := child.argvListWith(args[end:], clr)
+
+	// `-h` and `--help` without AutoHelper
+	if child.isHelpRequested(args[end:], argvList, clr) {
+		ctx = &Context{
+			path:       child.Path(),
+			router:     router[:end],
+			argvList:   argvList,
+			nativeArgs: args[end:],
+			flagSet:    newFlagSet(),
+			command:    child,
+			writer:     writer,
+			errWriter:  errWriter,
+			reader:     opts.stdin,
+			color:      clr,
+		}
+		ctx.WriteUsage()
+		err = ExitError
+		return
+	}
+
+	// `-V` and `--version` if root has version
+	if child.isVersionRequested(args[end:], argvList, clr) {
+		fmt.Fprint(writer, cmd.VersionString())
+		err = ExitError
+		return
+	}
 
 	// create Context
 	path = child.Path()
-	ctx, err = newContext(path, router[:end], args[end:], argvList, clr)
-	ctx.command = child
-	ctx.writer = writer
+	ctx = &Context{
+		path:       path,
+		router:     router[:end],
+		argvList:   argvList,
+		nativeArgs: args[end:],
+		flagSet:    newFlagSet(),
+		command:    child,
+		writer:     writer,
+		errWriter:  errWriter,
+		reader:     opts.stdin,
+		global:     child.globalArgv(),
+		color:      clr,
+	}
+	err = ctx.parse(opts.stdout, child.persistentArgvListWith(args[end:], clr)...)
 	if !ctx.flagSet.hasForce {
 		if !child.checkNumOption(ctx.NOpt()) || !ctx.command.checkNumArg(ctx.NArg()) {
-			ctx.WriteUsage()
+			fmt.Fprint(ctx.Stderr(), ctx.Usage())
 			err = ExitError
 			return
 		}
@@ -327,7 +932,14 @@ func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, re
 	if err != nil {
 		return
 	}
+	if !ctx.flagSet.hasForce {
+		if err = child.checkArgCount(ctx.NArg()); err != nil {
+			suggestion = "\n\n" + ctx.Usage()
+			return
+		}
+	}
 	ctx.HTTPResponse = resp
+	ctx.warnDeprecated()
 
 	// auto help
 	for _, argv := range argvList {
@@ -347,14 +959,9 @@ func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, re
 
 	if !ctx.flagSet.hasForce {
 		for _, argv := range argvList {
-			// validate argv if argv implements interface Validator
-			if argv != nil {
-				if validator, ok := argv.(Validator); ok {
-					err = validator.Validate(ctx)
-					if err != nil {
-						return
-					}
-				}
+			// validate argv if argv implements interface MultiValidator or Validator
+			if err = validateArgv(ctx, argv); err != nil {
+				return
 			}
 		}
 	}
@@ -362,10 +969,175 @@ func (cmd *Command) prepare(clr color.Color, args []string, writer io.Writer, re
 	return
 }
 
+// validateArgv validates argv by MultiValidator or Validator, errors of
+// MultiValidator combined
+func validateArgv(ctx *Context, argv interface{}) error {
+	if validator, ok := argv.(MultiValidator); ok {
+		return CombineErrors(validator.ValidateAll(ctx))
+	}
+	if validator, ok := argv.(Validator); ok {
+		return validator.Validate(ctx)
+	}
+	return nil
+}
+
+// isHelpRequested reports whether args contains `-h` or `--help` which
+// aren't flags of the command, HelpFlag of the command or an ancestor required
+func (cmd *Command) isHelpRequested(args []string, argvList []interface{}, clr color.Color) bool {
+	enabled := false
+	for c := cmd; c != nil && !enabled; c = c.parent {
+		enabled = c.HelpFlag
+	}
+	return enabled && cmd.hasUndefinedFlag(args, argvList, clr, "-h", "--help")
+}
+
+// isVersionRequested reports whether args contains `-V` or `--version` which
+// aren't flags of the command, Version of root required
+func (cmd *Command) isVersionRequested(args []string, argvList []interface{}, clr color.Color) bool {
+	return cmd.Root().Version != "" && cmd.hasUndefinedFlag(args, argvList, clr, "-V", "--version")
+}
+
+// hasUndefinedFlag reports whether args before `--` contains any of names
+// which isn't defined by flags of the command
+func (cmd *Command) hasUndefinedFlag(args []string, argvList []interface{}, clr color.Color, names ...string) bool {
+	var flagSet *flagSet
+	for _, arg := range args {
+		if arg == dashTwo {
+			break
+		}
+		found := false
+		for _, name := range names {
+			found = found || arg == name
+		}
+		if !found {
+			continue
+		}
+		if flagSet == nil {
+			flagSet = usageFlagSet(withGlobalArgv(argvList, cmd.globalArgv()), clr, cmd.persistentArgvList()...)
+		}
+		if _, ok := flagSet.flagMap[arg]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// VersionString returns version information of root, e.g.
+//
+//	app v1.2.0
+//
+// Commit, build date and go version appended if Commit or BuildDate of root set
+//
+//	app v1.2.0
+//	commit: 1a2b3c4
+//	built: 2020-01-02T15:04:05Z
+//	go: go1.13.5
+func (cmd *Command) VersionString() string {
+	root := cmd.Root()
+	buf := bytes.NewBufferString("")
+	if root.Name != "" {
+		fmt.Fprintf(buf, "%s ", root.Name)
+	}
+	fmt.Fprintln(buf, root.Version)
+	if root.Commit != "" || root.BuildDate != "" {
+		if root.Commit != "" {
+			fmt.Fprintf(buf, "commit: %s\n", root.Commit)
+		}
+		if root.BuildDate != "" {
+			fmt.Fprintf(buf, "built: %s\n", root.BuildDate)
+		}
+		fmt.Fprintf(buf, "go: %s\n", runtime.Version())
+	}
+	return buf.String()
+}
+
+var (
+	// RecoverPanics enables recovering panics of hooks and Fn of commands,
+	// a recovered panic is written to stderr and returned as PanicError.
+	// Disable it to get the crash with raw stack trace while developing.
+	RecoverPanics = true
+
+	// DebugEnv is name of environment variable which prints stack trace of
+	// recovered panics if it's not empty, as well as flag `--debug` set
+	DebugEnv = "CLI_DEBUG"
+)
+
+// panicError writes friendly message of panic value v to stderr and returns
+// PanicError, stack written only in debug mode
+func (ctx *Context) panicError(v interface{}, stack []byte) error {
+	w := ctx.Stderr()
+	fmt.Fprintln(w, ctx.color.Red(fmt.Sprintf("panic: %v", v)))
+	if ctx.isDebug() {
+		fmt.Fprintf(w, "\n%s", stack)
+	} else {
+		fmt.Fprintf(w, "set %s=1 or --debug to print stack trace\n", DebugEnv)
+	}
+	return PanicError{Value: v, Stack: stack}
+}
+
+// isDebug reports whether environment variable DebugEnv or bool flag
+// `--debug` set
+func (ctx *Context) isDebug() bool {
+	if DebugEnv != "" && os.Getenv(DebugEnv) != "" {
+		return true
+	}
+	if fl, ok := ctx.lookupFlag("--debug"); ok && fl.value.Kind() == reflect.Bool {
+		return fl.value.Bool()
+	}
+	return false
+}
+
+// SuppressDeprecationWarnings disables warnings of deprecated commands and flags
+var SuppressDeprecationWarnings = false
+
+// warnDeprecated writes warnings to stderr if the command or flags set are deprecated
+func (ctx *Context) warnDeprecated() {
+	if SuppressDeprecationWarnings {
+		return
+	}
+	clr := ctx.color
+	if dep := ctx.command.Deprecated; dep != "" {
+		name := ctx.command.Path()
+		if name == "" {
+			name = ctx.command.Name
+		}
+		ctx.ErrString("%s command %s is deprecated: %s\n", clr.Yellow("WARN!"), clr.Bold(name), dep)
+	}
+	for _, fl := range ctx.flagSet.flagSlice {
+		if fl.isSet && fl.tag.deprecated != "" {
+			name := fl.actualFlagName
+			if name == "" {
+				name = fl.name()
+			}
+			ctx.ErrString("%s flag %s is deprecated: %s\n", clr.Yellow("WARN!"), clr.Bold(name), fl.tag.deprecated)
+		}
+	}
+}
+
 func (cmd *Command) checkNumArg(num int) bool {
 	return cmd.NumArg == nil || cmd.NumArg(num)
 }
 
+// checkArgRange checks whether MinArgs and MaxArgs are a valid range
+func (cmd *Command) checkArgRange() error {
+	if cmd.MinArgs < 0 || cmd.MaxArgs < -1 || cmd.MaxArgs > 0 && cmd.MinArgs > cmd.MaxArgs {
+		return fmt.Errorf("invalid args range of command `%s`: MinArgs %d, MaxArgs %d", cmd.Name, cmd.MinArgs, cmd.MaxArgs)
+	}
+	return nil
+}
+
+// checkArgCount checks num by MinArgs and MaxArgs, an error returned if
+// they're an invalid range
+func (cmd *Command) checkArgCount(num int) error {
+	if err := cmd.checkArgRange(); err != nil {
+		return err
+	}
+	if num < cmd.MinArgs || cmd.MaxArgs > 0 && num > cmd.MaxArgs {
+		return argCountError{min: cmd.MinArgs, max: cmd.MaxArgs, got: num}
+	}
+	return nil
+}
+
 func (cmd *Command) checkNumOption(num int) bool {
 	return cmd.NumOption == nil || cmd.NumOption(num)
 }
@@ -388,8 +1160,9 @@ func (cmd *Command) defaultUsageFn(ctx *Context) string {
 	cmd.locker.Lock()
 	tmpUsage := cmd.usage
 	usageStyle := cmd.usageStyle
+	width := cmd.usageWidth
 	cmd.locker.Unlock()
-	if tmpUsage != "" && usageStyle == style {
+	if tmpUsage != "" && usageStyle == style && width == GetUsageWidth() {
 		debug.Debugf("get usage of command %s from cache", clr.Bold(cmd.Name))
 		return tmpUsage
 	}
@@ -401,25 +1174,77 @@ func (cmd *Command) defaultUsageFn(ctx *Context) string {
 	if cmd.Text != "" {
 		fmt.Fprintf(buff, "%s\n\n", cmd.Text)
 	}
-	argvList := cmd.argvList()
-	isEmpty := isEmptyArgvList(argvList)
+	if cmd.positionalsUsage() != "" {
+		flags, _ := cmd.completionFlags()
+		fmt.Fprintf(buff, "%s:\n\n  %s\n\n", clr.Bold("Usage"), cmd.docSynopsis(len(flags) > 0))
+	}
+	argvList := withGlobalArgv(cmd.argvList(), cmd.globalArgv())
+	persistentList := cmd.persistentArgvList()
+	isEmpty := isEmptyArgvList(argvList) && len(persistentList) == 0
 	if !isEmpty {
-		fmt.Fprintf(buff, "%s:\n\n%s", clr.Bold("Options"), usage(argvList, clr, style))
+		buff.WriteString(usageWithGroups(argvList, clr, style, persistentList...))
 	}
-	if cmd.children != nil && len(cmd.children) > 0 {
+	if len(cmd.visibleChildren()) > 0 {
 		if !isEmpty {
 			buff.WriteByte('\n')
 		}
 		fmt.Fprintf(buff, "%s:\n\n%v", clr.Bold("Commands"), cmd.ChildrenDescriptions("  ", "   "))
 	}
+	if len(cmd.Examples) > 0 {
+		if !isEmpty || len(cmd.visibleChildren()) > 0 {
+			buff.WriteByte('\n')
+		}
+		fmt.Fprintf(buff, "%s:\n\n%v", clr.Bold("Examples"), cmd.examplesUsage("  ", clr))
+	}
 	tmpUsage = buff.String()
 	cmd.locker.Lock()
 	cmd.usage = tmpUsage
 	cmd.usageStyle = style
+	cmd.usageWidth = GetUsageWidth()
 	cmd.locker.Unlock()
 	return tmpUsage
 }
 
+// positionalsUsage returns synopsis of positionals like `<src> <dst>`
+func (cmd *Command) positionalsUsage() string {
+	clr := color.Color{}
+	clr.Disable()
+	return usageFlagSet(cmd.argvList(), clr).positionalsUsage()
+}
+
+// examplesUsage formats examples, each example is a command line led by
+// it's description as a comment
+func (cmd *Command) examplesUsage(prefix string, clr color.Color) string {
+	name := cmd.Root().Name
+	buff := bytes.NewBufferString("")
+	for i, example := range cmd.Examples {
+		if i > 0 {
+			buff.WriteByte('\n')
+		}
+		if example.Desc != "" {
+			fmt.Fprintf(buff, "%s%s\n", prefix, clr.Grey("# "+example.Desc))
+		}
+		line := example.Command
+		if name != "" {
+			line = replaceAppName(line, name)
+		}
+		fmt.Fprintf(buff, "%s%s\n", prefix, line)
+	}
+	return buff.String()
+}
+
+// replaceAppName replaces `./app` which is a whole word of line with name
+func replaceAppName(line, name string) string {
+	const app = "./app"
+	fields := strings.Split(line, " ")
+	for i, field := range fields {
+		if field == app {
+			fields[i] = name
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
 // Path returns space-separated command full name
 func (cmd *Command) Path() string {
 	return cmd.pathWithSep(" ")
@@ -452,6 +1277,44 @@ func (cmd *Command) Root() *Command {
 	return ancestor
 }
 
+// Find finds descendant by path of names or aliases without dispatching,
+// words of path could be separated by spaces too, e.g. Find("remote", "add")
+// is equivalent to Find("remote add"). cmd itself returned if path is empty.
+func (cmd *Command) Find(path ...string) (*Command, bool) {
+	router := make([]string, 0, len(path))
+	for _, p := range path {
+		router = append(router, strings.Fields(p)...)
+	}
+	child := cmd.Route(router)
+	return child, child != nil
+}
+
+// Walk walks the tree rooted at cmd in depth-first order, calling fn for
+// each command including cmd, hidden commands included. path is names from
+// cmd to c, so cmd.Route(path) returns c, and path of cmd is empty. The
+// walk stops at the first error returned by fn except SkipCommand, which
+// skips descendants of the command.
+func (cmd *Command) Walk(fn func(c *Command, path []string) error) error {
+	err := cmd.walk(fn, []string{})
+	if err == SkipCommand {
+		return nil
+	}
+	return err
+}
+
+func (cmd *Command) walk(fn func(c *Command, path []string) error, path []string) error {
+	if err := fn(cmd, path); err != nil {
+		return err
+	}
+	for _, child := range cmd.children {
+		childPath := append(append(make([]string, 0, len(path)+1), path...), child.Name)
+		if err := child.walk(fn, childPath); err != nil && err != SkipCommand {
+			return err
+		}
+	}
+	return nil
+}
+
 // Route finds command full matching router
 func (cmd *Command) Route(router []string) *Command {
 	child, end := cmd.SubRoute(router)
@@ -512,32 +1375,49 @@ func (cmd *Command) ChildrenDescriptions(prefix, indent string) string {
 	if cmd.nochild() {
 		return ""
 	}
-	buff := bytes.NewBufferString("")
-	length := 0
-	for _, child := range cmd.children {
+	var (
+		buff     = bytes.NewBufferString("")
+		length   = 0
+		children = cmd.visibleChildren()
+	)
+	for _, child := range children {
 		if len(child.Name) > length {
 			length = len(child.Name)
 		}
 	}
 	format := fmt.Sprintf("%s%%-%ds%s%%s%%s\n", prefix, length, indent)
-	for _, child := range cmd.children {
+	for _, child := range children {
 		aliases := ""
 		if child.Aliases != nil && len(child.Aliases) > 0 {
-			aliasesBuff := bytes.NewBufferString("(aliases ")
-			aliasesBuff.WriteString(strings.Join(child.Aliases, ","))
+			aliasesBuff := bytes.NewBufferString(" (aliases: ")
+			aliasesBuff.WriteString(strings.Join(child.Aliases, ", "))
 			aliasesBuff.WriteString(")")
 			aliases = aliasesBuff.String()
 		}
+		if child.Deprecated != "" {
+			aliases += " (deprecated: " + child.Deprecated + ")"
+		}
 		fmt.Fprintf(buff, format, child.Name, child.Desc, aliases)
 	}
 	return buff.String()
 }
 
+// visibleChildren returns children which are not hidden
+func (cmd *Command) visibleChildren() []*Command {
+	children := make([]*Command, 0, len(cmd.children))
+	for _, child := range cmd.children {
+		if !child.Hidden {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
 func (cmd *Command) nochild() bool {
 	return cmd.children == nil || len(cmd.children) == 0
 }
 
-// Suggestions returns all similar commands
+// Suggestions returns all similar commands, aliases of commands included
 func (cmd *Command) Suggestions(path string) []string {
 	if cmd.parent != nil {
 		return cmd.Root().Suggestions(path)
@@ -551,10 +1431,19 @@ func (cmd *Command) Suggestions(path string) []string {
 		if cmds[0].nochild() {
 			cmds = cmds[1:]
 		} else {
-			for _, child := range cmds[0].children {
+			prefix := cmds[0].Path()
+			if prefix != "" {
+				prefix += " "
+			}
+			// hidden commands and their children are never suggested
+			children := cmds[0].visibleChildren()
+			for _, child := range children {
 				targets = append(targets, child.Path())
+				for _, alias := range child.Aliases {
+					targets = append(targets, prefix+alias)
+				}
 			}
-			cmds = append(cmds[0].children, cmds[1:]...)
+			cmds = append(children, cmds[1:]...)
 		}
 	}
 
@@ -1,9 +1,18 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/labstack/gommon/color"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -104,6 +113,93 @@ func TestResgisterRepeatedCommand(t *testing.T) {
 	assert.Panics(t, func() { cmd.Register(&Command{Name: "hello", Aliases: []string{"sub"}, Fn: donothing}) })
 }
 
+func TestCommandAliases(t *testing.T) {
+	var ran string
+	root := &Command{Name: "root"}
+	root.Register(&Command{
+		Name:    "remove",
+		Aliases: []string{"rm", "del"},
+		Desc:    "remove files",
+		Fn:      func(ctx *Context) error { ran = ctx.Command().Name; return nil },
+	})
+	root.Register(&Command{Name: "list", Desc: "list files", Fn: donothing})
+	for _, name := range []string{"remove", "rm", "del"} {
+		ran = ""
+		assert.Nil(t, root.Run([]string{name}))
+		assert.Equal(t, "remove", ran)
+	}
+	assert.Equal(t, "  remove   remove files (aliases: rm, del)\n  list     list files\n", root.ChildrenDescriptions("  ", "   "))
+
+	// collisions
+	for i, child := range []*Command{
+		{Name: "rm"},
+		{Name: "new", Aliases: []string{"del"}},
+		{Name: "new", Aliases: []string{"list"}},
+		{Name: "new", Aliases: []string{"n", "n"}},
+		{Name: "new", Aliases: []string{"new"}},
+		{Name: "new", Aliases: []string{"-n"}},
+	} {
+		_, err := root.TryRegister(child)
+		assert.Error(t, err, "case %d", i)
+	}
+	_, err := root.TryRegister(&Command{Name: "new", Aliases: []string{"n"}})
+	assert.Nil(t, err)
+	_, err = root.TryRegister(&Command{Name: "n"})
+	assert.Error(t, err)
+
+	assert.Error(t, (&Command{Name: "root"}).TryRegisterTree(
+		Tree(&Command{Name: "a", Aliases: []string{"x"}}),
+		Tree(&Command{Name: "b", Aliases: []string{"x"}}),
+	))
+	assert.Nil(t, (&Command{Name: "root"}).TryRegisterTree(
+		Tree(&Command{Name: "a", Aliases: []string{"x"}}, Tree(&Command{Name: "b", Aliases: []string{"x"}})),
+	))
+}
+
+func TestHiddenCommandAndFlag(t *testing.T) {
+	type argT struct {
+		Name  string `cli:"name" usage:"your name"`
+		Debug bool   `cli:"debug" usage:"debug mode" hidden:"true"`
+	}
+	var (
+		ran   bool
+		debug bool
+		usage string
+	)
+	root := &Command{
+		Name: "root",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			debug = ctx.Argv().(*argT).Debug
+			usage = ctx.Usage()
+			return nil
+		},
+	}
+	root.Register(&Command{Name: "visible", Desc: "visible command", Fn: donothing})
+	root.Register(&Command{
+		Name:   "secret",
+		Desc:   "hidden command",
+		Hidden: true,
+		Fn:     func(ctx *Context) error { ran = true; return nil },
+	})
+
+	assert.Nil(t, root.Run([]string{"--debug"}))
+	assert.True(t, debug)
+	assert.Contains(t, usage, "--name")
+	assert.NotContains(t, usage, "--debug")
+	assert.Contains(t, usage, "visible")
+	assert.NotContains(t, usage, "secret")
+
+	assert.Nil(t, root.Run([]string{"secret"}))
+	assert.True(t, ran)
+
+	// no `Commands` section if all children hidden
+	parent := &Command{Name: "parent", Fn: donothing}
+	parent.Register(&Command{Name: "secret", Hidden: true, Fn: donothing})
+	ctx := &Context{command: parent}
+	assert.Equal(t, "", parent.Usage(ctx))
+}
+
 func TestRegisterTree(t *testing.T) {
 	cmd := &Command{Name: "root"}
 	tree := Tree(&Command{Name: "sub", Fn: donothing}, Tree(&Command{Name: "sub2", Fn: donothing}))
@@ -173,6 +269,125 @@ func TestValidator(t *testing.T) {
 	assert.Nil(t, getCmd().RunWith([]string{"-v=2"}, nil, nil))
 }
 
+type testMultiValidator struct {
+	Name string `cli:"name"`
+	Age  int    `cli:"age"`
+}
+
+func (argv *testMultiValidator) Validate(ctx *Context) error {
+	return fmt.Errorf("Validate shouldn't be called")
+}
+
+func (argv *testMultiValidator) ValidateAll(ctx *Context) []error {
+	var errs []error
+	if argv.Name == "" {
+		errs = append(errs, fmt.Errorf("name is required"))
+	}
+	if argv.Age <= 0 {
+		errs = append(errs, fmt.Errorf("age should be positive"))
+	}
+	return errs
+}
+
+func TestMultiValidator(t *testing.T) {
+	root := &Command{
+		Name: "root",
+		Argv: func() interface{} { return new(testMultiValidator) },
+		Fn:   donothing,
+	}
+	w := bytes.NewBufferString("")
+	for i, tt := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{}, "ERR! - name is required\nERR! - age should be positive"},
+		{[]string{"--age=-1"}, "ERR! - name is required\nERR! - age should be positive"},
+		{[]string{"--age=1"}, "ERR! name is required"},
+		{[]string{"--name=x", "--age=1"}, ""},
+	} {
+		err := root.RunWith(tt.args, w, nil)
+		if tt.err == "" {
+			assert.Nil(t, err, "case %d", i)
+		} else if assert.Error(t, err, "case %d", i) {
+			assert.Equal(t, tt.err, err.Error(), "case %d", i)
+		}
+	}
+
+	assert.Nil(t, CombineErrors(nil))
+	assert.Nil(t, CombineErrors([]error{nil}))
+	errA := fmt.Errorf("a")
+	assert.Equal(t, errA, CombineErrors([]error{nil, errA}))
+	assert.Equal(t, "- a\n- b", CombineErrors([]error{errA, nil, fmt.Errorf("b")}).Error())
+}
+
+func TestCommandPreRunPostRun(t *testing.T) {
+	var (
+		logs   []string
+		errFn  error
+		errPre error
+	)
+	hooks := func(name string) (func(*Context) error, func(*Context, error) error) {
+		pre := func(ctx *Context) error {
+			logs = append(logs, name+".PreRun")
+			if name == "child" {
+				return errPre
+			}
+			return nil
+		}
+		post := func(ctx *Context, err error) error {
+			logs = append(logs, fmt.Sprintf("%s.PostRun(%v)", name, err))
+			if name == "root" && err != nil && err.Error() == "suppress" {
+				return nil
+			}
+			return err
+		}
+		return pre, post
+	}
+	root := &Command{Name: "root"}
+	root.PreRun, root.PostRun = hooks("root")
+	child := &Command{
+		Name: "child",
+		Fn: func(ctx *Context) error {
+			logs = append(logs, "child.Fn")
+			return errFn
+		},
+	}
+	child.PreRun, child.PostRun = hooks("child")
+	root.Register(child)
+
+	// onion ordering
+	assert.Nil(t, root.Run([]string{"child"}))
+	assert.Equal(t, []string{
+		"root.PreRun",
+		"child.PreRun",
+		"child.Fn",
+		"child.PostRun(<nil>)",
+		"root.PostRun(<nil>)",
+	}, logs)
+
+	// PostRun receives error of Fn
+	logs = nil
+	errFn = fmt.Errorf("fn failed")
+	assert.Equal(t, errFn, root.Run([]string{"child"}))
+	assert.Equal(t, "root.PostRun(fn failed)", logs[len(logs)-1])
+
+	// PostRun suppresses error
+	logs = nil
+	errFn = fmt.Errorf("suppress")
+	assert.Nil(t, root.Run([]string{"child"}))
+
+	// PreRun error short-circuits
+	logs = nil
+	errFn = nil
+	errPre = fmt.Errorf("pre failed")
+	assert.Equal(t, errPre, root.Run([]string{"child"}))
+	assert.Equal(t, []string{
+		"root.PreRun",
+		"child.PreRun",
+		"root.PostRun(pre failed)",
+	}, logs)
+}
+
 //TODO: TestCommandHooks
 
 func TestCommandMisc(t *testing.T) {
@@ -212,3 +427,911 @@ func TestCommandMisc(t *testing.T) {
 
 	assert.Equal(t, root.Suggestions("su"), []string{"sub"})
 }
+
+func TestSuggestionsHidden(t *testing.T) {
+	root := &Command{Name: "root"}
+	root.Register(&Command{Name: "build"})
+	internal := root.Register(&Command{Name: "internal", Aliases: []string{"intern"}, Hidden: true})
+	internal.Register(&Command{Name: "dump"})
+	assert.Equal(t, []string{"build"}, root.Suggestions("buld"))
+	assert.Empty(t, root.Suggestions("internl"))
+	assert.Empty(t, root.Suggestions("inten"))
+	assert.Empty(t, root.Suggestions("internal dum"))
+}
+
+func TestDeprecated(t *testing.T) {
+	type argT struct {
+		Old string `cli:"o,old" usage:"old flag" deprecated:"use --new instead"`
+		New string `cli:"new" usage:"new flag"`
+	}
+	var (
+		w    = bytes.NewBufferString("")
+		ew   = bytes.NewBufferString("")
+		root = &Command{Name: "app", Stderr: ew}
+	)
+	root.Register(&Command{
+		Name:       "legacy",
+		Desc:       "legacy command",
+		Deprecated: "use `app modern` instead",
+		Argv:       func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			ctx.String("%s", ctx.Argv().(*argT).Old)
+			return nil
+		},
+	})
+	root.Register(&Command{
+		Name: "modern",
+		Desc: "modern command",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			ctx.String("%s", ctx.Argv().(*argT).Old)
+			return nil
+		},
+	})
+
+	for i, tt := range []struct {
+		args []string
+		out  string
+		warn string
+	}{
+		{[]string{"modern", "--new=x"}, "", ""},
+		{[]string{"modern", "-o", "a", "--old=b"}, "b", "WARN! flag --old is deprecated: use --new instead\n"},
+		{[]string{"legacy"}, "", "WARN! command legacy is deprecated: use `app modern` instead\n"},
+		{[]string{"legacy", "-o", "a"}, "a", "WARN! command legacy is deprecated: use `app modern` instead\nWARN! flag -o is deprecated: use --new instead\n"},
+	} {
+		w.Reset()
+		ew.Reset()
+		assert.Nil(t, root.RunWith(tt.args, w, nil), "case %d", i)
+		assert.Equal(t, tt.out, w.String(), "case %d", i)
+		assert.Equal(t, tt.warn, ew.String(), "case %d", i)
+	}
+
+	// suppressed
+	SuppressDeprecationWarnings = true
+	defer func() { SuppressDeprecationWarnings = false }()
+	ew.Reset()
+	assert.Nil(t, root.RunWith([]string{"legacy", "-o", "a"}, w, nil))
+	assert.Equal(t, "", ew.String())
+
+	// marked in usage
+	clr := color.Color{}
+	clr.Disable()
+	assert.Equal(t, "  -o, --old   old flag (deprecated: use --new instead)\n      --new   new flag\n", usage([]interface{}{new(argT)}, clr, NormalStyle))
+	assert.Equal(t, "  legacy   legacy command (deprecated: use `app modern` instead)\n  modern   modern command\n", root.ChildrenDescriptions("  ", "   "))
+}
+
+func TestPersistentFlag(t *testing.T) {
+	type rootT struct {
+		DryRun  bool `cli:"dry-run" usage:"print actions only" persistent:"true"`
+		Verbose bool `cli:"v" usage:"not inherited"`
+	}
+	type overrideT struct {
+		DryRun string `cli:"dry-run" dft:"none"`
+	}
+	var (
+		w    = bytes.NewBufferString("")
+		root = &Command{Name: "app", Argv: func() interface{} { return new(rootT) }}
+		fn   = func(ctx *Context) error {
+			value, ok := ctx.Persistent("dry-run")
+			ctx.String("%s %v", value, ok)
+			return nil
+		}
+	)
+	sub := root.Register(&Command{Name: "sub", Fn: fn})
+	sub.Register(&Command{Name: "leaf", Fn: fn})
+	root.Register(&Command{Name: "override", Argv: func() interface{} { return new(overrideT) }, Fn: fn})
+
+	for i, tt := range []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"sub"}, "false true"},
+		{[]string{"sub", "--dry-run"}, "true true"},
+		{[]string{"sub", "leaf"}, "false true"},
+		{[]string{"sub", "leaf", "--dry-run"}, "true true"},
+		{[]string{"override"}, "none true"},
+		{[]string{"override", "--dry-run=x"}, "x true"},
+	} {
+		w.Reset()
+		assert.Nil(t, root.RunWith(tt.args, w, nil), "case %d", i)
+		assert.Equal(t, tt.out, w.String(), "case %d", i)
+	}
+
+	// non-persistent flags aren't inherited
+	assert.NotNil(t, root.RunWith([]string{"sub", "leaf", "-v"}, w, nil))
+
+	// inherited flags shown in usage
+	clr := color.Color{}
+	clr.Disable()
+	ctx := &Context{color: clr}
+	assert.Equal(t, "Options:\n\n  --dry-run   print actions only\n\nCommands:\n\n  leaf   \n", sub.Usage(ctx))
+}
+
+func TestHelpFlag(t *testing.T) {
+	type rootT struct {
+		Verbose bool `cli:"v" usage:"verbose"`
+	}
+	type subT struct {
+		Host string `cli:"h,host" usage:"host name"`
+	}
+	var (
+		w   = bytes.NewBufferString("")
+		ran = false
+		fn  = func(ctx *Context) error { ran = true; return nil }
+	)
+	root := Root(&Command{Name: "app", Desc: "root command", Argv: func() interface{} { return new(rootT) }, Fn: fn},
+		Tree(&Command{Name: "sub", Desc: "sub command", Fn: fn},
+			Tree(&Command{Name: "leaf", Desc: "leaf command", Argv: func() interface{} { return new(subT) }, Fn: fn}),
+		),
+	)
+	assert.True(t, root.HelpFlag)
+
+	for i, tt := range []struct {
+		args  []string
+		usage string
+	}{
+		{[]string{"-h"}, "root command"},
+		{[]string{"--help"}, "root command"},
+		{[]string{"sub", "--help"}, "sub command"},
+		{[]string{"sub", "leaf", "--help"}, "leaf command"},
+	} {
+		w.Reset()
+		ran = false
+		assert.Nil(t, root.RunWith(tt.args, w, nil), "case %d", i)
+		assert.False(t, ran, "case %d", i)
+		assert.True(t, strings.HasPrefix(w.String(), tt.usage+"\n"), "case %d: %s", i, w.String())
+	}
+
+	// `-h` defined by argv
+	w.Reset()
+	assert.Nil(t, root.RunWith([]string{"sub", "leaf", "-h", "localhost"}, w, nil))
+	assert.True(t, ran)
+	assert.Equal(t, "", w.String())
+
+	// after `--`
+	ran = false
+	assert.Nil(t, root.RunWith([]string{"sub", "--", "--help"}, w, nil))
+	assert.True(t, ran)
+
+	// disabled
+	root.HelpFlag = false
+	assert.NotNil(t, root.RunWith([]string{"--help"}, w, nil))
+}
+
+func TestMiddleware(t *testing.T) {
+	var trace []string
+	mw := func(name string) Middleware {
+		return func(next CommandFunc) CommandFunc {
+			return func(ctx *Context) error {
+				trace = append(trace, name+" before")
+				err := next(ctx)
+				trace = append(trace, name+" after")
+				return err
+			}
+		}
+	}
+	deny := func(next CommandFunc) CommandFunc {
+		return func(ctx *Context) error {
+			trace = append(trace, "deny")
+			return fmt.Errorf("permission denied")
+		}
+	}
+	fn := func(ctx *Context) error {
+		trace = append(trace, ctx.Path())
+		return nil
+	}
+	root := &Command{Name: "app", Fn: fn}
+	root.Use(mw("a"), mw("b"))
+	sub := root.Register(&Command{Name: "sub", Fn: fn}).Use(mw("c"))
+	sub.Register(&Command{Name: "leaf", Fn: fn, NoHook: true})
+	root.Register(&Command{Name: "admin", Fn: fn}).Use(deny, mw("d"))
+
+	for i, tt := range []struct {
+		args  []string
+		err   string
+		trace []string
+	}{
+		{[]string{}, "", []string{"a before", "b before", "", "b after", "a after"}},
+		{[]string{"sub"}, "", []string{"a before", "b before", "c before", "sub", "c after", "b after", "a after"}},
+		{[]string{"sub", "leaf"}, "", []string{"a before", "b before", "c before", "sub leaf", "c after", "b after", "a after"}},
+		{[]string{"admin"}, "permission denied", []string{"a before", "b before", "deny", "b after", "a after"}},
+	} {
+		trace = nil
+		err := root.RunWith(tt.args, bytes.NewBufferString(""), nil)
+		if tt.err != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.err, err.Error(), "case %d", i)
+			}
+		} else {
+			assert.Nil(t, err, "case %d", i)
+		}
+		assert.Equal(t, tt.trace, trace, "case %d", i)
+	}
+}
+
+func TestGoContext(t *testing.T) {
+	ctx := &Context{}
+	assert.Equal(t, context.Background(), ctx.GoContext())
+	goCtx, cancel := context.WithCancel(context.Background())
+	assert.Equal(t, goCtx, ctx.WithGoContext(goCtx).GoContext())
+	cancel()
+
+	var (
+		started = make(chan struct{})
+		cmd     = &Command{
+			Name: "app",
+			Fn: func(ctx *Context) error {
+				close(started)
+				<-ctx.GoContext().Done()
+				return ctx.GoContext().Err()
+			},
+		}
+	)
+	goCtx, cancel = context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	assert.Equal(t, context.Canceled, cmd.RunWithGoContext(goCtx, []string{}, bytes.NewBufferString("")))
+
+	// canceled by signal
+	proc, err := os.FindProcess(os.Getpid())
+	require.Nil(t, err)
+	started = make(chan struct{})
+	cmd.CancelOnSignal = true
+	goCtx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-started
+		if err := proc.Signal(os.Interrupt); err != nil {
+			// e.g. not supported on windows
+			cancel()
+		}
+	}()
+	assert.Equal(t, context.Canceled, cmd.RunWithGoContext(goCtx, []string{}, bytes.NewBufferString("")))
+}
+
+func TestVersionFlag(t *testing.T) {
+	type subT struct {
+		Verbose bool `cli:"V" usage:"verbose"`
+	}
+	var (
+		w   = bytes.NewBufferString("")
+		ran = false
+		fn  = func(ctx *Context) error { ran = true; return nil }
+	)
+	root := &Command{Name: "app", Version: "v1.2.0", Fn: fn}
+	root.Register(&Command{Name: "sub", Fn: fn})
+	root.Register(&Command{Name: "verbose", Argv: func() interface{} { return new(subT) }, Fn: fn})
+
+	for i, args := range [][]string{{"--version"}, {"-V"}, {"sub", "--version"}} {
+		w.Reset()
+		ran = false
+		assert.Nil(t, root.RunWith(args, w, nil), "case %d", i)
+		assert.False(t, ran, "case %d", i)
+		assert.Equal(t, "app v1.2.0\n", w.String(), "case %d", i)
+	}
+
+	// `-V` defined by argv
+	w.Reset()
+	assert.Nil(t, root.RunWith([]string{"verbose", "-V"}, w, nil))
+	assert.True(t, ran)
+	assert.Equal(t, "", w.String())
+
+	// extended
+	w.Reset()
+	root.Commit = "1a2b3c4"
+	root.BuildDate = "2020-01-02"
+	assert.Nil(t, root.RunWith([]string{"--version"}, w, nil))
+	assert.Equal(t, "app v1.2.0\ncommit: 1a2b3c4\nbuilt: 2020-01-02\ngo: "+runtime.Version()+"\n", w.String())
+
+	// no version
+	root.Version = ""
+	assert.NotNil(t, root.RunWith([]string{"verbose", "--version"}, w, nil))
+}
+
+func TestRunWithOptions(t *testing.T) {
+	type argT struct {
+		Name  string `cli:"name" prompt:"name"`
+		Count int    `cli:"n" dft:"1"`
+	}
+	root := &Command{
+		Name:   "app",
+		Argv:   func() interface{} { return new(argT) },
+		NumArg: ExactN(0),
+		Fn: func(ctx *Context) error {
+			argv := ctx.Argv().(*argT)
+			ok, err := ctx.Confirm("greet " + argv.Name + "?")
+			if err != nil {
+				return err
+			}
+			if ok {
+				ctx.String("hello %s\n", argv.Name)
+			}
+			ctx.ErrString("done\n")
+			return nil
+		},
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := root.RunWithOptions([]string{}, WithStdin(strings.NewReader("bob\nyes\n")), WithStdout(&stdout), WithStderr(&stderr))
+	assert.Nil(t, err)
+	assert.Equal(t, "name: greet bob? [y/n]: hello bob\n", stdout.String())
+	assert.Equal(t, "done\n", stderr.String())
+
+	// usage written to stderr on failure
+	stdout.Reset()
+	stderr.Reset()
+	err = root.RunWithOptions([]string{"--name=a", "x"}, WithStdin(strings.NewReader("")), WithStdout(&stdout), WithStderr(&stderr))
+	assert.Nil(t, err)
+	assert.Equal(t, "", stdout.String())
+	assert.Contains(t, stderr.String(), "--name")
+
+	// answer missing
+	stdout.Reset()
+	err = root.RunWithOptions([]string{"--name=alice"}, WithStdin(strings.NewReader("")), WithStdout(&stdout), WithStderr(&stderr))
+	assert.Error(t, err)
+	assert.Equal(t, "greet alice? [y/n]: \n", stdout.String())
+}
+
+func TestCommandNotFoundSuggestion(t *testing.T) {
+	root := Root(&Command{Name: "app"},
+		Tree(&Command{Name: "deploy", Fn: donothing}),
+		Tree(&Command{Name: "remove", Aliases: []string{"rm"}, Fn: donothing}),
+	)
+	for i, tt := range []struct {
+		args []string
+		msg  string
+	}{
+		{[]string{"dpeloy"}, "ERR! command dpeloy not found\nDid you mean \"deploy\"?"},
+		{[]string{"remvoe"}, "ERR! command remvoe not found\nDid you mean \"remove\"?"},
+		// alias
+		{[]string{"rn"}, "ERR! command rn not found\nDid you mean \"rm\"?"},
+		{[]string{"xyz"}, "ERR! command xyz not found"},
+		{[]string{"status"}, "ERR! command status not found"},
+	} {
+		var buf bytes.Buffer
+		err := root.RunWith(tt.args, &buf, nil)
+		if assert.Error(t, err, "case %d", i) {
+			assert.Equal(t, tt.msg, err.Error(), "case %d", i)
+		}
+	}
+}
+
+func TestGlobalFlags(t *testing.T) {
+	type globalT struct {
+		Config  string `cli:"c,config" usage:"config file" dft:"app.json"`
+		Verbose bool   `cli:"v,verbose"`
+	}
+	type argT struct {
+		Name string `cli:"name"`
+	}
+	var (
+		global *globalT
+		name   string
+	)
+	fn := func(ctx *Context) error {
+		global = ctx.Global().(*globalT)
+		if argv, ok := ctx.Argv().(*argT); ok {
+			name = argv.Name
+		}
+		return nil
+	}
+	root := Root(&Command{
+		Name:        "app",
+		GlobalFlags: func() interface{} { return new(globalT) },
+		Fn:          fn,
+	},
+		Tree(&Command{Name: "sub", Argv: func() interface{} { return new(argT) }, Fn: fn},
+			Tree(&Command{Name: "leaf", Fn: fn}),
+		),
+	)
+
+	for i, tt := range []struct {
+		args   []string
+		global globalT
+		name   string
+	}{
+		{[]string{"-v"}, globalT{Config: "app.json", Verbose: true}, ""},
+		{[]string{"sub", "--name=x", "-c", "x.json"}, globalT{Config: "x.json"}, "x"},
+		{[]string{"sub", "leaf", "--config=y.json", "--verbose"}, globalT{Config: "y.json", Verbose: true}, ""},
+	} {
+		global, name = nil, ""
+		var buf bytes.Buffer
+		assert.Nil(t, root.RunWith(tt.args, &buf, nil), "case %d", i)
+		if assert.NotNil(t, global, "case %d", i) {
+			assert.Equal(t, tt.global, *global, "case %d", i)
+		}
+		assert.Equal(t, tt.name, name, "case %d", i)
+	}
+	clr := color.Color{}
+	clr.Disable()
+	assert.Contains(t, root.Route([]string{"sub", "leaf"}).Usage(&Context{color: clr}), "-c, --config[=app.json]")
+
+	// conflicts
+	_, err := root.TryRegister(&Command{Name: "bad", Argv: func() interface{} { return new(globalT) }})
+	if assert.Error(t, err) {
+		assert.Equal(t, "flag -c of command `bad` collides with global flag", err.Error())
+	}
+	type verboseT struct {
+		Verbose bool `cli:"verbose"`
+	}
+	err = root.TryRegisterTree(Tree(&Command{Name: "deep"}, Tree(&Command{Name: "x", Argv: func() interface{} { return new(verboseT) }})))
+	if assert.Error(t, err) {
+		assert.Equal(t, "flag --verbose of command `x` collides with global flag", err.Error())
+	}
+	badRoot := &Command{
+		Name:        "app",
+		Argv:        func() interface{} { return new(verboseT) },
+		GlobalFlags: func() interface{} { return new(globalT) },
+	}
+	_, err = badRoot.TryRegister(&Command{Name: "sub"})
+	assert.Error(t, err)
+}
+
+func TestDoubleDashTerminator(t *testing.T) {
+	type argT struct {
+		X bool   `cli:"x"`
+		N string `cli:"n"`
+	}
+	var (
+		args []string
+		argv *argT
+	)
+	root := &Command{
+		Name: "app",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			args, argv = ctx.Args(), ctx.Argv().(*argT)
+			return nil
+		},
+	}
+	root.Register(&Command{
+		Name: "noflags",
+		Fn: func(ctx *Context) error {
+			args = ctx.Args()
+			return nil
+		},
+	})
+	for i, tt := range []struct {
+		args []string
+		want []string
+		argv argT
+	}{
+		{[]string{"--", "--not-a-flag", "-x"}, []string{"--not-a-flag", "-x"}, argT{}},
+		{[]string{"-x", "--", "-n", "--"}, []string{"-n", "--"}, argT{X: true}},
+		{[]string{"-n", "a", "b", "--", "c"}, []string{"b", "c"}, argT{N: "a"}},
+		{[]string{"--"}, []string{}, argT{}},
+	} {
+		assert.Nil(t, root.RunWith(tt.args, bytes.NewBufferString(""), nil), "case %d", i)
+		assert.Equal(t, tt.want, args, "case %d", i)
+		assert.Equal(t, tt.argv, *argv, "case %d", i)
+	}
+
+	// commands without flags
+	assert.Nil(t, root.RunWith([]string{"noflags", "--", "--not-a-flag", "-x"}, bytes.NewBufferString(""), nil))
+	assert.Equal(t, []string{"--not-a-flag", "-x"}, args)
+}
+
+func TestExternalCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script required")
+	}
+	dir, err := ioutil.TempDir("", "cli-external")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	script := "#!/bin/sh\necho \"$0 $*\"\nread line\necho \"stdin: $line\"\necho oops >&2\nexit 3\n"
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "app-foo"), []byte(script), 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "app-sub-bar"), []byte("#!/bin/sh\necho bar $*\n"), 0755))
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+
+	root := Root(&Command{Name: "app", ExternalCommandPrefix: "app"},
+		Tree(&Command{Name: "sub", Fn: donothing}),
+	)
+	var stdout, stderr bytes.Buffer
+	err = root.RunWithOptions([]string{"foo", "x", "--y"}, WithStdin(strings.NewReader("hi\n")), WithStdout(&stdout), WithStderr(&stderr))
+	assert.Equal(t, 3, ExitCodeOf(err))
+	assert.Equal(t, filepath.Join(dir, "app-foo")+" x --y\nstdin: hi\n", stdout.String())
+	assert.Equal(t, "oops\n", stderr.String())
+
+	stdout.Reset()
+	assert.Nil(t, root.RunWithOptions([]string{"sub", "bar", "-z"}, WithStdout(&stdout)))
+	assert.Equal(t, "bar -z\n", stdout.String())
+
+	// fallback to unknown-command error
+	err = root.RunWithOptions([]string{"baz"}, WithStdout(&stdout))
+	if assert.Error(t, err) {
+		assert.Equal(t, "ERR! command baz not found", err.Error())
+	}
+	root.ExternalCommandPrefix = ""
+	err = root.RunWithOptions([]string{"foo"}, WithStdout(&stdout))
+	if assert.Error(t, err) {
+		assert.Equal(t, "ERR! command foo not found", err.Error())
+	}
+}
+
+func TestCommandExamples(t *testing.T) {
+	type argT struct {
+		Env string `cli:"env" usage:"target environment"`
+	}
+	deploy := &Command{
+		Name: "deploy",
+		Argv: func() interface{} { return new(argT) },
+		Fn:   donothing,
+		Examples: []Example{
+			{Command: "./app deploy --env prod", Desc: "deploy to production"},
+			{Command: "./app deploy --env=dev | tee ./app.log"},
+		},
+	}
+	root := Root(&Command{Name: "mycli", Examples: []Example{{Command: "./app help", Desc: "show help"}}},
+		Tree(deploy),
+	)
+	clr := color.Color{}
+	clr.Disable()
+	ctx := &Context{color: clr}
+	assert.Equal(t, `Options:
+
+  --env   target environment
+
+Examples:
+
+  # deploy to production
+  mycli deploy --env prod
+
+  mycli deploy --env=dev | tee ./app.log
+`, deploy.Usage(ctx))
+	assert.Equal(t, `Commands:
+
+  deploy   
+
+Examples:
+
+  # show help
+  mycli help
+`, root.Usage(ctx))
+
+	// name of root unknown
+	alone := &Command{Examples: []Example{{Command: "./app -h"}}}
+	assert.Equal(t, "Examples:\n\n  ./app -h\n", alone.Usage(ctx))
+}
+
+func TestLastContext(t *testing.T) {
+	type argT struct {
+		Name string `cli:"name"`
+	}
+	root := Root(&Command{Name: "app", Fn: donothing},
+		Tree(&Command{Name: "sub"},
+			Tree(&Command{
+				Name: "leaf",
+				Argv: func() interface{} { return new(argT) },
+				Fn:   func(ctx *Context) error { return fmt.Errorf("failed") },
+			}),
+		),
+	)
+	assert.Nil(t, root.LastContext())
+
+	w := bytes.NewBufferString("")
+	assert.Error(t, root.RunWith([]string{"sub", "leaf", "--name=x", "a", "b"}, w, nil))
+	ctx := root.LastContext()
+	if assert.NotNil(t, ctx) {
+		assert.Equal(t, "sub leaf", ctx.Path())
+		assert.Equal(t, &argT{Name: "x"}, ctx.Argv())
+		assert.Equal(t, []string{"a", "b"}, ctx.Args())
+		assert.True(t, ctx.IsSet("--name"))
+	}
+
+	assert.Nil(t, root.RunWith([]string{}, w, nil))
+	assert.Equal(t, "", root.LastContext().Path())
+
+	assert.Error(t, root.RunWith([]string{"not-found"}, w, nil))
+	assert.Nil(t, root.LastContext())
+}
+
+func TestArgvContext(t *testing.T) {
+	type parentT struct {
+		Dir string `cli:"dir" dft:"/src"`
+	}
+	type childT struct {
+		Out     string `cli:"out"`
+		Verbose bool   `cli:"v"`
+	}
+	var got *childT
+	newRoot := func() *Command {
+		root := &Command{
+			Name:   "app",
+			Global: true,
+			Argv:   func() interface{} { return new(parentT) },
+		}
+		root.Register(&Command{
+			Name: "build",
+			ArgvContext: func(ctx *Context) interface{} {
+				return &childT{Out: ctx.Argv().(*parentT).Dir + "/build"}
+			},
+			Fn: func(ctx *Context) error {
+				got = ctx.Argv().(*childT)
+				return nil
+			},
+		})
+		return root
+	}
+	for i, tt := range []struct {
+		args []string
+		want childT
+	}{
+		{args: []string{"build"}, want: childT{Out: "/src/build"}},
+		{args: []string{"build", "--dir", "/tmp", "-v"}, want: childT{Out: "/tmp/build", Verbose: true}},
+		{args: []string{"build", "-v", "--dir=/tmp", "--out", "/out"}, want: childT{Out: "/out", Verbose: true}},
+	} {
+		got = nil
+		if assert.Nil(t, newRoot().Run(tt.args), "case %d", i) && assert.NotNil(t, got, "case %d", i) {
+			assert.Equal(t, tt.want, *got, "case %d", i)
+		}
+	}
+
+	// usage rendered with context parsed from empty args
+	clr := color.Color{}
+	clr.Disable()
+	child := newRoot().Route([]string{"build"})
+	usage := child.Usage(&Context{color: clr})
+	assert.Contains(t, usage, "--out")
+	assert.Contains(t, usage, "--dir[=/src]")
+}
+
+func TestArgvContextParent(t *testing.T) {
+	type rootT struct {
+		Dir string `cli:"dir" dft:"/src"`
+	}
+	type buildT struct {
+		Out string `cli:"out"`
+	}
+	type runT struct {
+		Fast bool `cli:"fast"`
+	}
+	type deployT struct {
+		Env string `cli:"env" persistent:"true"`
+	}
+	var (
+		build  buildT
+		run    runT
+		envSet bool
+	)
+	newRoot := func() *Command {
+		root := &Command{
+			Name:   "app",
+			Global: true,
+			Argv:   func() interface{} { return new(rootT) },
+		}
+		// global parent declared by ArgvContext
+		buildCmd := &Command{
+			Name:   "build",
+			Global: true,
+			ArgvContext: func(ctx *Context) interface{} {
+				return &buildT{Out: ctx.Argv().(*rootT).Dir + "/out"}
+			},
+		}
+		buildCmd.Register(&Command{
+			Name: "run",
+			Argv: func() interface{} { return new(runT) },
+			Fn: func(ctx *Context) error {
+				run = *ctx.Argv().(*runT)
+				return ctx.GetArgvAt(&build, 1)
+			},
+		})
+		root.Register(buildCmd)
+		// persistent parent declared by ArgvContext
+		deployCmd := &Command{
+			Name: "deploy",
+			ArgvContext: func(ctx *Context) interface{} {
+				return &deployT{Env: "dev"}
+			},
+		}
+		deployCmd.Register(&Command{
+			Name: "now",
+			Fn: func(ctx *Context) error {
+				envSet = ctx.IsSet("--env")
+				return nil
+			},
+		})
+		root.Register(deployCmd)
+		return root
+	}
+	for i, tt := range []struct {
+		args  []string
+		build buildT
+		run   runT
+	}{
+		{[]string{"build", "run"}, buildT{Out: "/src/out"}, runT{}},
+		{[]string{"build", "run", "--dir=/tmp", "--fast"}, buildT{Out: "/tmp/out"}, runT{Fast: true}},
+		{[]string{"build", "run", "--out", "/o"}, buildT{Out: "/o"}, runT{}},
+	} {
+		build, run = buildT{}, runT{}
+		if assert.Nil(t, newRoot().RunWithOptions(tt.args, WithStdout(ioutil.Discard), WithStderr(ioutil.Discard)), "case %d", i) {
+			assert.Equal(t, tt.build, build, "case %d", i)
+			assert.Equal(t, tt.run, run, "case %d", i)
+		}
+	}
+	assert.Nil(t, newRoot().RunWithOptions([]string{"deploy", "now", "--env=prod"}, WithStdout(ioutil.Discard), WithStderr(ioutil.Discard)))
+	assert.True(t, envSet)
+}
+
+func TestArgCount(t *testing.T) {
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		min, max int
+		args     []string
+		err      string
+	}{
+		{min: 2, max: 2, args: []string{"a", "b"}},
+		{min: 2, max: 2, args: []string{"a"}, err: "expected 2 args, got 1"},
+		{min: 2, max: 2, args: []string{"a", "b", "c"}, err: "expected 2 args, got 3"},
+		{min: 1, max: -1, args: []string{"a", "b", "c"}},
+		{min: 1, max: -1, args: []string{}, err: "expected at least 1 arg, got 0"},
+		{min: 0, max: 1, args: []string{"a", "b"}, err: "expected at most 1 arg, got 2"},
+		{min: 1, max: 3, args: []string{"a", "b"}},
+		{min: 1, max: 3, args: []string{"a", "b", "c", "d"}, err: "expected 1 to 3 args, got 4"},
+		{args: []string{"a", "b"}},
+		// MaxArgs 0 isn't configured
+		{min: 1, max: 0, args: []string{"a", "b"}},
+		// invalid ranges
+		{min: 3, max: 1, args: []string{"a", "b"}, err: "invalid args range of command `app`: MinArgs 3, MaxArgs 1"},
+		{min: -1, args: []string{}, err: "invalid args range of command `app`: MinArgs -1, MaxArgs 0"},
+		{max: -2, args: []string{}, err: "invalid args range of command `app`: MinArgs 0, MaxArgs -2"},
+	} {
+		cmd := &Command{
+			Name:        "app",
+			Argv:        func() interface{} { return new(struct{ Helper }) },
+			CanSubRoute: true,
+			MinArgs:     tt.min,
+			MaxArgs:     tt.max,
+			Fn:          donothing,
+		}
+		err := cmd.RunWith(tt.args, bytes.NewBufferString(""), nil)
+		if tt.err == "" {
+			assert.Nil(t, err, "case %d", i)
+			continue
+		}
+		if assert.Error(t, err, "case %d", i) {
+			assert.Equal(t, "ERR! "+tt.err+"\n\n"+cmd.Usage(&Context{color: clr}), err.Error(), "case %d", i)
+		}
+	}
+
+	root := &Command{Name: "app"}
+	_, err := root.TryRegister(&Command{Name: "sub", MinArgs: 2, MaxArgs: 1})
+	if assert.Error(t, err) {
+		assert.Equal(t, "invalid args range of command `sub`: MinArgs 2, MaxArgs 1", err.Error())
+	}
+	_, err = root.TryRegister(&Command{Name: "sub", MinArgs: 2, MaxArgs: -1})
+	assert.Nil(t, err)
+}
+
+func TestCommandFind(t *testing.T) {
+	root := Root(&Command{Name: "app"},
+		Tree(&Command{Name: "remote", Aliases: []string{"r"}},
+			Tree(&Command{Name: "add", Desc: "add remote", Aliases: []string{"new"}}),
+		),
+	)
+	for i, tt := range []struct {
+		path []string
+		want string
+	}{
+		{path: nil, want: ""},
+		{path: []string{"remote"}, want: "remote"},
+		{path: []string{"remote", "add"}, want: "remote add"},
+		{path: []string{"remote add"}, want: "remote add"},
+		{path: []string{"r", "new"}, want: "remote add"},
+	} {
+		cmd, ok := root.Find(tt.path...)
+		if assert.True(t, ok, "case %d", i) {
+			assert.Equal(t, tt.want, cmd.Path(), "case %d", i)
+		}
+	}
+	for i, path := range [][]string{{"remote", "remove"}, {"add"}, {"remote add x"}} {
+		cmd, ok := root.Find(path...)
+		assert.False(t, ok, "case %d", i)
+		assert.Nil(t, cmd, "case %d", i)
+	}
+}
+
+func TestCommandWalk(t *testing.T) {
+	root := Root(&Command{Name: "app"},
+		Tree(&Command{Name: "remote"},
+			Tree(&Command{Name: "add"}),
+			Tree(&Command{Name: "remove"}),
+		),
+		Tree(&Command{Name: "debug", Hidden: true},
+			Tree(&Command{Name: "dump"}),
+		),
+		Tree(&Command{Name: "version"}),
+	)
+	var visited []string
+	assert.Nil(t, root.Walk(func(c *Command, path []string) error {
+		assert.Equal(t, c, root.Route(path))
+		visited = append(visited, strings.Join(path, "/"))
+		return nil
+	}))
+	assert.Equal(t, []string{"", "remote", "remote/add", "remote/remove", "debug", "debug/dump", "version"}, visited)
+
+	// skip descendants
+	visited = nil
+	assert.Nil(t, root.Walk(func(c *Command, path []string) error {
+		visited = append(visited, c.Name)
+		if c.Hidden || c.Name == "remote" {
+			return SkipCommand
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"app", "remote", "debug", "version"}, visited)
+
+	// stop on error
+	visited = nil
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, root.Walk(func(c *Command, path []string) error {
+		visited = append(visited, c.Name)
+		if c.Name == "add" {
+			return errStop
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"app", "remote", "add"}, visited)
+
+	// walk from sub command
+	remote, _ := root.Find("remote")
+	visited = nil
+	assert.Nil(t, remote.Walk(func(c *Command, path []string) error {
+		visited = append(visited, strings.Join(path, "/"))
+		return nil
+	}))
+	assert.Equal(t, []string{"", "add", "remove"}, visited)
+}
+
+func TestPanicRecovery(t *testing.T) {
+	type argT struct {
+		Debug bool `cli:"debug"`
+	}
+	root := &Command{
+		Name: "app",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			ctx.String("before\n")
+			panic("boom")
+		},
+	}
+	os.Unsetenv(DebugEnv)
+
+	// recovered, stack not printed
+	var stdout, stderr bytes.Buffer
+	err := root.RunWithOptions(nil, WithStdout(&stdout), WithStderr(&stderr))
+	var pe PanicError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, "boom", pe.Value)
+	assert.Equal(t, 2, ExitCodeOf(err))
+	assert.Equal(t, "before\n", stdout.String())
+	assert.Equal(t, "panic: boom\nset CLI_DEBUG=1 or --debug to print stack trace\n", stderr.String())
+	assert.NotContains(t, stderr.String(), "goroutine")
+
+	// stack printed by flag --debug
+	stderr.Reset()
+	err = root.RunWithOptions([]string{"--debug"}, WithStdout(&stdout), WithStderr(&stderr))
+	assert.Equal(t, 2, ExitCodeOf(err))
+	assert.True(t, strings.HasPrefix(stderr.String(), "panic: boom\n\ngoroutine "))
+	assert.Contains(t, stderr.String(), "TestPanicRecovery")
+
+	// stack printed by environment variable
+	os.Setenv(DebugEnv, "1")
+	defer os.Unsetenv(DebugEnv)
+	stderr.Reset()
+	assert.NotNil(t, root.RunWithOptions(nil, WithStdout(&stdout), WithStderr(&stderr)))
+	assert.True(t, strings.HasPrefix(stderr.String(), "panic: boom\n\ngoroutine "))
+
+	// panic of error unwrapped
+	cause := errors.New("cause")
+	assert.True(t, errors.Is((&Command{Fn: func(*Context) error { panic(cause) }}).RunWithOptions(nil, WithStderr(&stderr)), cause))
+
+	// recovery disabled
+	RecoverPanics = false
+	defer func() { RecoverPanics = true }()
+	assert.Panics(t, func() { root.RunWithOptions(nil, WithStdout(&stdout), WithStderr(&stderr)) })
+}
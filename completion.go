@@ -0,0 +1,452 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/gommon/color"
+)
+
+// GenBashCompletion writes bash completion script of the command tree to w,
+// e.g.
+//
+//	app.GenBashCompletion(os.Stdout) // source <(app completion)
+func (cmd *Command) GenBashCompletion(w io.Writer) error {
+	var (
+		root  = cmd.Root()
+		name  = root.completionName()
+		fn    = "_" + completionFuncName(name) + "_completion"
+		buff  = bytes.NewBufferString("")
+		cmds  = root.completionCommands()
+		flags = make([][]*flag, len(cmds))
+	)
+	for i, c := range cmds {
+		fls, err := c.completionFlags()
+		if err != nil {
+			return err
+		}
+		flags[i] = fls
+	}
+
+	fmt.Fprintf(buff, "# bash completion for %s\n\n", name)
+	fmt.Fprintf(buff, "%s() {\n", fn)
+	buff.WriteString("    local cur prev word cmd=\"\" commands=\"\" flags=\"\" i\n")
+	buff.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	buff.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+
+	// find current command
+	buff.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	buff.WriteString("        word=\"${COMP_WORDS[i]}\"\n")
+	buff.WriteString("        case \"${cmd:+${cmd} }${word}\" in\n")
+	for _, c := range cmds[1:] {
+		parent := c.parent.Path()
+		if parent != "" {
+			parent += " "
+		}
+		words := []string{}
+		for _, n := range append([]string{c.Name}, c.Aliases...) {
+			words = append(words, fmt.Sprintf("%q", parent+n))
+		}
+		fmt.Fprintf(buff, "        %s)\n", strings.Join(words, "|"))
+		fmt.Fprintf(buff, "            cmd=%q\n", c.Path())
+		buff.WriteString("            ;;\n")
+	}
+	buff.WriteString("        esac\n")
+	buff.WriteString("    done\n\n")
+
+	// commands, flags and choices of current command
+	buff.WriteString("    case \"${cmd}\" in\n")
+	for i, c := range cmds {
+		fmt.Fprintf(buff, "    %q)\n", c.Path())
+		children := []string{}
+		for _, child := range c.visibleChildren() {
+			children = append(children, child.Name)
+		}
+		fmt.Fprintf(buff, "        commands=%q\n", strings.Join(children, " "))
+		fmt.Fprintf(buff, "        flags=%q\n", strings.Join(flagNames(flags[i]), " "))
+		hasChoices := false
+		for _, fl := range flags[i] {
+			if len(fl.tag.choices) == 0 {
+				continue
+			}
+			if !hasChoices {
+				buff.WriteString("        case \"${prev}\" in\n")
+				hasChoices = true
+			}
+			fmt.Fprintf(buff, "        %s)\n", strings.Join(flagNames([]*flag{fl}), "|"))
+			fmt.Fprintf(buff, "            COMPREPLY=($(compgen -W %q -- \"${cur}\"))\n", strings.Join(fl.tag.choices, " "))
+			buff.WriteString("            return 0\n")
+			buff.WriteString("            ;;\n")
+		}
+		if hasChoices {
+			buff.WriteString("        esac\n")
+		}
+		buff.WriteString("        ;;\n")
+	}
+	buff.WriteString("    esac\n\n")
+
+	buff.WriteString("    if [[ \"${cur}\" == -* ]]; then\n")
+	buff.WriteString("        COMPREPLY=($(compgen -W \"${flags}\" -- \"${cur}\"))\n")
+	buff.WriteString("    else\n")
+	buff.WriteString("        COMPREPLY=($(compgen -W \"${commands}\" -- \"${cur}\"))\n")
+	buff.WriteString("    fi\n")
+	buff.WriteString("    return 0\n")
+	buff.WriteString("}\n\n")
+	fmt.Fprintf(buff, "complete -F %s %s\n", fn, name)
+
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+// completionName returns name of the executable file
+func (cmd *Command) completionName() string {
+	return filepath.Base(cmd.Name)
+}
+
+// completionCommands returns all commands of the tree in depth-first order
+func (cmd *Command) completionCommands() []*Command {
+	var cmds []*Command
+	cmd.Walk(func(c *Command, _ []string) error {
+		if c != cmd && c.Hidden {
+			return SkipCommand
+		}
+		cmds = append(cmds, c)
+		return nil
+	})
+	return cmds
+}
+
+// completionFlags returns all flags of the command, includes global flags of parents
+func (cmd *Command) completionFlags() ([]*flag, error) {
+	clr := color.Color{}
+	clr.Disable()
+	flagSet := usageFlagSet(withGlobalArgv(cmd.argvList(), cmd.globalArgv()), clr, cmd.persistentArgvList()...)
+	return flagSlice(flagSet.flagSlice).visible(), flagSet.err
+}
+
+func flagNames(flags []*flag) []string {
+	names := []string{}
+	for _, fl := range flags {
+		names = append(names, fl.tag.shortNames...)
+		names = append(names, fl.tag.longNames...)
+	}
+	return names
+}
+
+func completionFuncName(name string) string {
+	b := []byte(name)
+	for i := range b {
+		if !isWordByte(b[i]) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// GenZshCompletion writes zsh completion script of the command tree to w
+func (cmd *Command) GenZshCompletion(w io.Writer) error {
+	var (
+		root = cmd.Root()
+		name = root.completionName()
+		fn   = "_" + completionFuncName(name)
+		buff = bytes.NewBufferString("")
+	)
+	fmt.Fprintf(buff, "#compdef %s\n", name)
+	for _, c := range root.completionCommands() {
+		flags, err := c.completionFlags()
+		if err != nil {
+			return err
+		}
+		cfn := fn
+		if path := c.pathWithSep("_"); path != "" {
+			cfn += "_" + completionFuncName(path)
+		}
+		fmt.Fprintf(buff, "\n%s() {\n", cfn)
+		leaf := len(c.visibleChildren()) == 0
+		if !leaf {
+			buff.WriteString("    local context state state_descr line\n")
+			buff.WriteString("    typeset -A opt_args\n\n")
+			buff.WriteString("    _arguments -C \\\n")
+		} else {
+			buff.WriteString("    _arguments \\\n")
+		}
+		for _, fl := range flags {
+			fmt.Fprintf(buff, "        %s \\\n", zshFlagSpec(fl))
+		}
+		if leaf {
+			buff.WriteString("        '*: :_files'\n")
+			buff.WriteString("}\n")
+			continue
+		}
+		buff.WriteString("        '1: :->cmds' \\\n")
+		buff.WriteString("        '*:: :->args'\n\n")
+		buff.WriteString("    case $state in\n")
+		buff.WriteString("    cmds)\n")
+		buff.WriteString("        local -a commands\n")
+		buff.WriteString("        commands=(\n")
+		for _, child := range c.visibleChildren() {
+			for _, n := range append([]string{child.Name}, child.Aliases...) {
+				fmt.Fprintf(buff, "            %s\n", zshQuote(n+":"+zshEscape(child.Desc, ":")))
+			}
+		}
+		buff.WriteString("        )\n")
+		buff.WriteString("        _describe 'command' commands\n")
+		buff.WriteString("        ;;\n")
+		buff.WriteString("    args)\n")
+		buff.WriteString("        case $line[1] in\n")
+		for _, child := range c.visibleChildren() {
+			fmt.Fprintf(buff, "        %s)\n", strings.Join(append([]string{child.Name}, child.Aliases...), "|"))
+			fmt.Fprintf(buff, "            %s_%s\n", fn, completionFuncName(child.pathWithSep("_")))
+			buff.WriteString("            ;;\n")
+		}
+		buff.WriteString("        esac\n")
+		buff.WriteString("        ;;\n")
+		buff.WriteString("    esac\n")
+		buff.WriteString("}\n")
+	}
+	fmt.Fprintf(buff, "\nif [ \"$funcstack[1]\" = \"%s\" ]; then\n", fn)
+	fmt.Fprintf(buff, "    %s \"$@\"\n", fn)
+	buff.WriteString("else\n")
+	fmt.Fprintf(buff, "    compdef %s %s\n", fn, name)
+	buff.WriteString("fi\n")
+
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+// zshFlagSpec returns option specification of _arguments, e.g.
+//
+//	'(-f --format)'{-f,--format}'[output format]:format:(json yaml)'
+func zshFlagSpec(fl *flag) string {
+	var (
+		names = flagNames([]*flag{fl})
+		desc  = fl.tag.usage
+		spec  string
+	)
+	if fl.tag.isRequired {
+		desc = "(required) " + desc
+	}
+	desc = "[" + zshEscape(desc, "[]") + "]"
+	if !fl.isBoolean() && !fl.isCounter() {
+		message := fl.tag.name
+		if message == "" {
+			message = strings.TrimLeft(fl.name(), dashOne)
+		}
+		desc += ":" + zshEscape(message, ":") + ":"
+		if len(fl.tag.choices) > 0 {
+			desc += "(" + strings.Join(fl.tag.choices, " ") + ")"
+		}
+	}
+	repeatable := fl.isSlice() || fl.isMap() || fl.isCounter()
+	if len(names) == 1 {
+		if repeatable {
+			return zshQuote("*" + names[0] + desc)
+		}
+		return zshQuote(names[0] + desc)
+	}
+	if repeatable {
+		spec = "'*'"
+	} else {
+		spec = zshQuote("(" + strings.Join(names, " ") + ")")
+	}
+	return spec + "{" + strings.Join(names, ",") + "}" + zshQuote(desc)
+}
+
+// zshEscape escapes characters of chars by backslash
+func zshEscape(s, chars string) string {
+	buff := bytes.NewBufferString("")
+	for _, c := range s {
+		if c == '\\' || strings.ContainsRune(chars, c) {
+			buff.WriteByte('\\')
+		}
+		buff.WriteRune(c)
+	}
+	return buff.String()
+}
+
+// zshQuote quotes s by single quote
+func zshQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// GenFishCompletion writes fish completion script of the command tree to w
+func (cmd *Command) GenFishCompletion(w io.Writer) error {
+	var (
+		root = cmd.Root()
+		name = root.completionName()
+		buff = bytes.NewBufferString("")
+	)
+	fmt.Fprintf(buff, "# fish completion for %s\n", name)
+	for _, c := range root.completionCommands() {
+		flags, err := c.completionFlags()
+		if err != nil {
+			return err
+		}
+		var (
+			children = c.visibleChildren()
+			cond     = fishCondition(c)
+		)
+		buff.WriteByte('\n')
+		for _, child := range children {
+			fmt.Fprintf(buff, "complete -c %s%s -f -a %s -d %s\n",
+				name, cond, fishQuote(child.Name), fishQuote(child.Desc))
+			for _, alias := range child.Aliases {
+				fmt.Fprintf(buff, "complete -c %s%s -f -a %s -d %s\n",
+					name, cond, fishQuote(alias), fishQuote(child.Desc))
+			}
+		}
+		for _, fl := range flags {
+			fmt.Fprintf(buff, "complete -c %s%s", name, cond)
+			for _, n := range fl.tag.shortNames {
+				fmt.Fprintf(buff, " -s %s", strings.TrimPrefix(n, dashOne))
+			}
+			for _, n := range fl.tag.longNames {
+				fmt.Fprintf(buff, " -l %s", strings.TrimPrefix(n, dashTwo))
+			}
+			if !fl.isBoolean() && !fl.isCounter() {
+				if len(fl.tag.choices) > 0 {
+					fmt.Fprintf(buff, " -x -a %s", fishQuote(strings.Join(fl.tag.choices, " ")))
+				} else {
+					buff.WriteString(" -r")
+				}
+			}
+			if fl.tag.usage != "" {
+				fmt.Fprintf(buff, " -d %s", fishQuote(fl.tag.usage))
+			}
+			buff.WriteByte('\n')
+		}
+	}
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+// fishCondition returns option `-n` of complete which is true if cmd is
+// the current command, i.e. cmd and all parents seen but none of children
+func fishCondition(cmd *Command) string {
+	var conds []string
+	for c := cmd; c.parent != nil; c = c.parent {
+		names := append([]string{c.Name}, c.Aliases...)
+		conds = append([]string{"__fish_seen_subcommand_from " + strings.Join(names, " ")}, conds...)
+	}
+	var children []string
+	for _, child := range cmd.visibleChildren() {
+		children = append(children, child.Name)
+		children = append(children, child.Aliases...)
+	}
+	if len(children) > 0 {
+		conds = append(conds, "not __fish_seen_subcommand_from "+strings.Join(children, " "))
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+	return " -n " + fishQuote(strings.Join(conds, "; and "))
+}
+
+// fishQuote quotes s by single quote
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// GenPowerShellCompletion writes PowerShell completion script of the command tree to w
+func (cmd *Command) GenPowerShellCompletion(w io.Writer) error {
+	var (
+		root   = cmd.Root()
+		name   = root.completionName()
+		buff   = bytes.NewBufferString("")
+		cmds   = root.completionCommands()
+		result = func(indent, text, typ, tip string) {
+			if tip == "" {
+				tip = text
+			}
+			fmt.Fprintf(buff, "%s$results += [System.Management.Automation.CompletionResult]::new(%s, %s, '%s', %s)\n",
+				indent, psQuote(text), psQuote(text), typ, psQuote(tip))
+		}
+	)
+	fmt.Fprintf(buff, "# powershell completion for %s\n\n", name)
+	fmt.Fprintf(buff, "Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {\n", psQuote(name))
+	buff.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n\n")
+
+	// find current command
+	buff.WriteString("    $routes = @{\n")
+	for _, c := range cmds[1:] {
+		parent := c.parent.Path()
+		if parent != "" {
+			parent += " "
+		}
+		for _, n := range append([]string{c.Name}, c.Aliases...) {
+			fmt.Fprintf(buff, "        %s = %s\n", psQuote(parent+n), psQuote(c.Path()))
+		}
+	}
+	buff.WriteString("    }\n")
+	buff.WriteString("    $elements = @($commandAst.CommandElements | Where-Object { $_.Extent.EndOffset -lt $cursorPosition })\n")
+	buff.WriteString("    $path = ''\n")
+	buff.WriteString("    foreach ($element in $elements | Select-Object -Skip 1) {\n")
+	buff.WriteString("        $key = (\"$path \" + $element.ToString()).Trim()\n")
+	buff.WriteString("        if ($routes.ContainsKey($key)) {\n")
+	buff.WriteString("            $path = $routes[$key]\n")
+	buff.WriteString("        }\n")
+	buff.WriteString("    }\n")
+	buff.WriteString("    $prev = ''\n")
+	buff.WriteString("    if ($elements.Count -gt 1) {\n")
+	buff.WriteString("        $prev = $elements[-1].ToString()\n")
+	buff.WriteString("    }\n\n")
+
+	// commands, flags and choices of current command
+	buff.WriteString("    $results = @()\n")
+	buff.WriteString("    switch ($path) {\n")
+	for _, c := range cmds {
+		flags, err := c.completionFlags()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buff, "        %s {\n", psQuote(c.Path()))
+		hasChoices := false
+		for _, fl := range flags {
+			if len(fl.tag.choices) == 0 {
+				continue
+			}
+			if !hasChoices {
+				buff.WriteString("            switch ($prev) {\n")
+				hasChoices = true
+			}
+			names := []string{}
+			for _, n := range flagNames([]*flag{fl}) {
+				names = append(names, psQuote(n))
+			}
+			fmt.Fprintf(buff, "                { $_ -in %s } {\n", strings.Join(names, ", "))
+			for _, choice := range fl.tag.choices {
+				result("                    ", choice, "ParameterValue", fl.tag.usage)
+			}
+			buff.WriteString("                    return $results | Where-Object { $_.CompletionText -like \"$wordToComplete*\" }\n")
+			buff.WriteString("                }\n")
+		}
+		if hasChoices {
+			buff.WriteString("            }\n")
+		}
+		for _, child := range c.visibleChildren() {
+			for _, n := range append([]string{child.Name}, child.Aliases...) {
+				result("            ", n, "ParameterValue", child.Desc)
+			}
+		}
+		for _, fl := range flags {
+			for _, n := range flagNames([]*flag{fl}) {
+				result("            ", n, "ParameterName", fl.tag.usage)
+			}
+		}
+		buff.WriteString("        }\n")
+	}
+	buff.WriteString("    }\n")
+	buff.WriteString("    $results | Where-Object { $_.CompletionText -like \"$wordToComplete*\" }\n")
+	buff.WriteString("}\n")
+
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+// psQuote quotes s by single quote of PowerShell
+func psQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
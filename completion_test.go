@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompletionTestApp() *Command {
+	type rootT struct {
+		Helper
+		Format string `cli:"f,format" usage:"output format" choices:"json|yaml"`
+		Debug  bool   `cli:"debug" usage:"debug mode" hidden:"true"`
+	}
+	type sub1T struct {
+		Name    string `cli:"*n,name" usage:"your name"`
+		Verbose bool   `cli:"v,verbose" usage:"verbose mode"`
+	}
+	type sub11T struct {
+		Level string `cli:"level" usage:"log level" choices:"debug|info"`
+	}
+	return Root(&Command{
+		Name:   "app",
+		Desc:   "completion test app",
+		Argv:   func() interface{} { return new(rootT) },
+		Global: true,
+		Fn:     donothing,
+	},
+		Tree(&Command{
+			Name:    "sub1",
+			Aliases: []string{"s1"},
+			Desc:    "first sub command",
+			Argv:    func() interface{} { return new(sub1T) },
+			Fn:      donothing,
+		},
+			Tree(&Command{
+				Name: "sub11",
+				Desc: "nested sub command",
+				Argv: func() interface{} { return new(sub11T) },
+				Fn:   donothing,
+			}),
+		),
+		Tree(&Command{
+			Name: "sub2",
+			Desc: "second sub command",
+			Fn:   donothing,
+		}),
+		Tree(&Command{
+			Name:   "internal",
+			Desc:   "hidden command",
+			Hidden: true,
+			Fn:     donothing,
+		}),
+	)
+}
+
+func testCompletion(t *testing.T, golden string, gen func(*Command, *bytes.Buffer) error) {
+	app := newCompletionTestApp()
+	buf := new(bytes.Buffer)
+	require.Nil(t, gen(app, buf))
+	want, err := ioutil.ReadFile(golden)
+	require.Nil(t, err)
+	assert.Equal(t, string(want), buf.String())
+}
+
+func TestGenBashCompletion(t *testing.T) {
+	testCompletion(t, "testdata/bash_completion.golden", func(app *Command, buf *bytes.Buffer) error {
+		return app.GenBashCompletion(buf)
+	})
+}
+
+func TestGenZshCompletion(t *testing.T) {
+	testCompletion(t, "testdata/zsh_completion.golden", func(app *Command, buf *bytes.Buffer) error {
+		return app.GenZshCompletion(buf)
+	})
+}
+
+func TestGenFishCompletion(t *testing.T) {
+	testCompletion(t, "testdata/fish_completion.golden", func(app *Command, buf *bytes.Buffer) error {
+		return app.GenFishCompletion(buf)
+	})
+}
+
+func TestGenPowerShellCompletion(t *testing.T) {
+	testCompletion(t, "testdata/powershell_completion.golden", func(app *Command, buf *bytes.Buffer) error {
+		return app.GenPowerShellCompletion(buf)
+	})
+}
@@ -1,13 +1,23 @@
 package cli
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/labstack/gommon/color"
 	"github.com/mattn/go-colorable"
@@ -24,7 +34,24 @@ type (
 		flagSet    *flagSet
 		command    *Command
 		writer     io.Writer
-		color      color.Color
+		bufWriter  *bufio.Writer
+		errWriter  io.Writer
+		reader     io.Reader
+		stdinMu    sync.Mutex
+		stdin      *bufio.Reader // buffers reader, shared by prompts and Stdin
+		global     interface{}
+		usePager   *bool
+		spinner    *Spinner
+		outputMu   sync.Mutex // protects writer while spinner running
+		logLevel   Level
+
+		timerMu     sync.Mutex
+		timerStarts map[string]time.Time
+		timerLabels []string // in order of started
+		timings     map[string]time.Duration
+
+		color color.Color
+		goCtx context.Context
 
 		HTTPRequest  *http.Request
 		HTTPResponse http.ResponseWriter
@@ -35,13 +62,19 @@ type (
 		Validate(*Context) error
 	}
 
+	// MultiValidator validates flag before running command and reports all
+	// problems, it's preferred to Validator if both implemented
+	MultiValidator interface {
+		ValidateAll(*Context) []error
+	}
+
 	// AutoHelper represents interface for showing help information automatically
 	AutoHelper interface {
 		AutoHelp() bool
 	}
 )
 
-func newContext(path string, router, args []string, argvList []interface{}, clr color.Color) (*Context, error) {
+func newContext(path string, router, args []string, argvList []interface{}, clr color.Color, persistentList ...interface{}) (*Context, error) {
 	ctx := &Context{
 		path:       path,
 		router:     router,
@@ -50,13 +83,36 @@ func newContext(path string, router, args []string, argvList []interface{}, clr
 		color:      clr,
 		flagSet:    newFlagSet(),
 	}
-	if !isEmptyArgvList(argvList) {
-		ctx.flagSet = parseArgvList(args, argvList, ctx.color)
-		if ctx.flagSet.err != nil {
-			return ctx, ctx.flagSet.err
-		}
+	return ctx, ctx.parse(nil, persistentList...)
+}
+
+// parse parses native args to argvList, global argv and persistent flags of persistentList,
+// missing flags which have tag `prompt` read from reader of ctx, and prompts
+// written to promptWriter. PromptReader and PromptWriter used if nil.
+func (ctx *Context) parse(promptWriter io.Writer, persistentList ...interface{}) error {
+	return ctx.parseWith(promptWriter, nil, persistentList...)
+}
+
+// parseWith is like parse, but values read from files, prompts and editor
+// taken from readValues instead of reading again if readValues isn't nil
+func (ctx *Context) parseWith(promptWriter io.Writer, readValues map[string]map[string]string, persistentList ...interface{}) error {
+	argvList := withGlobalArgv(ctx.argvList, ctx.global)
+	if isEmptyArgvList(argvList) && len(persistentList) == 0 {
+		ctx.flagSet.args = freeArgs(ctx.nativeArgs)
+		return nil
+	}
+	flagSet := newFlagSet()
+	flagSet.promptReader, flagSet.promptWriter = ctx.reader, promptWriter
+	flagSet.bufferedPrompt = ctx.stdinReader
+	flagSet.readValues = readValues
+	flagSet.allowAbbrev = ctx.command != nil && ctx.command.abbrevFlags()
+	flagSet.keepUnknown = ctx.command != nil && ctx.command.IgnoreUnknownFlags
+	if fn := ctx.command.loadDefaultsFunc(); fn != nil {
+		flagSet.loadDefaults = func() error { return fn(ctx) }
 	}
-	return ctx, nil
+	ctx.flagSet = flagSet
+	ctx.flagSet = parseArgvListTo(flagSet, ctx.nativeArgs, argvList, ctx.color, persistentList...)
+	return ctx.flagSet.err
 }
 
 // Path returns full command name
@@ -77,8 +133,18 @@ func (ctx *Context) NativeArgs() []string {
 	return ctx.nativeArgs
 }
 
+// UnknownFlags returns unknown flags collected if IgnoreUnknownFlags of
+// command enabled, e.g. ["--foo=1", "-x"] of `./app --foo=1 -x --name=a`
+func (ctx *Context) UnknownFlags() []string {
+	if ctx.flagSet == nil {
+		return nil
+	}
+	return ctx.flagSet.unknownFlags
+}
+
 // Args returns free args
 // `./app hello world -a=1 abc xyz` will return ["abc" "xyz"]
+// `./app -a=1 -- -b xyz` will return ["-b" "xyz"], args after `--` aren't flags
 func (ctx *Context) Args() []string {
 	return ctx.flagSet.args
 }
@@ -88,6 +154,90 @@ func (ctx *Context) NArg() int {
 	return len(ctx.flagSet.args)
 }
 
+// ArgAt returns the i-th free arg, or empty string if i out of range
+func (ctx *Context) ArgAt(i int) string {
+	s, _ := ctx.StringArgAt(i)
+	return s
+}
+
+// StringArgAt returns the i-th free arg, error returned if i out of range
+func (ctx *Context) StringArgAt(i int) (string, error) {
+	if i < 0 || i >= ctx.NArg() {
+		return "", argIndexError{index: i, size: ctx.NArg()}
+	}
+	return ctx.flagSet.args[i], nil
+}
+
+// IntArgAt converts the i-th free arg to int
+func (ctx *Context) IntArgAt(i int) (int, error) {
+	s, err := ctx.StringArgAt(i)
+	if err != nil {
+		return 0, err
+	}
+	v, err := getInt(s, ctx.color)
+	if err != nil {
+		return 0, argError{index: i, err: err}
+	}
+	if !minmaxIntCheck(reflect.Int, v) {
+		return 0, argError{index: i, err: errors.New("value overflow")}
+	}
+	return int(v), nil
+}
+
+// UintArgAt converts the i-th free arg to uint
+func (ctx *Context) UintArgAt(i int) (uint, error) {
+	s, err := ctx.StringArgAt(i)
+	if err != nil {
+		return 0, err
+	}
+	v, err := getUint(s, ctx.color)
+	if err != nil {
+		return 0, argError{index: i, err: err}
+	}
+	if !minmaxUintCheck(reflect.Uint, v) {
+		return 0, argError{index: i, err: errors.New("value overflow")}
+	}
+	return uint(v), nil
+}
+
+// FloatArgAt converts the i-th free arg to float64
+func (ctx *Context) FloatArgAt(i int) (float64, error) {
+	s, err := ctx.StringArgAt(i)
+	if err != nil {
+		return 0, err
+	}
+	v, err := getFloat(s, ctx.color)
+	if err != nil {
+		return 0, argError{index: i, err: err}
+	}
+	return v, nil
+}
+
+// BoolArgAt converts the i-th free arg to bool
+func (ctx *Context) BoolArgAt(i int) (bool, error) {
+	s, err := ctx.StringArgAt(i)
+	if err != nil {
+		return false, err
+	}
+	v, err := getBool(s, ctx.color)
+	if err != nil {
+		return false, argError{index: i, err: err}
+	}
+	return v, nil
+}
+
+// DecodeArgAt decodes the i-th free arg by decoder
+func (ctx *Context) DecodeArgAt(i int, decoder Decoder) error {
+	s, err := ctx.StringArgAt(i)
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(s); err != nil {
+		return argError{index: i, err: err}
+	}
+	return nil
+}
+
 // NOpt returns num of options
 func (ctx *Context) NOpt() int {
 	if ctx.flagSet == nil || ctx.flagSet.flagSlice == nil {
@@ -110,6 +260,46 @@ func (ctx *Context) Argv() interface{} {
 	return ctx.argvList[0]
 }
 
+// SetArgv replaces argv by v and parses native args to v again, e.g. after
+// loading a profile which changes flags of command. Flags of ancestors,
+// global and persistent flags are parsed again too, so the flag set keeps
+// consistent with v. Values read from files, prompts and editor are reused
+// rather than read again, and flags which weren't prompted before aren't
+// prompted. MinArgs, MaxArgs and Validator of v are checked as running
+// the command. Argv and flags are unchanged if native args couldn't be
+// parsed to v or checked.
+func (ctx *Context) SetArgv(v interface{}) error {
+	if typ := reflect.TypeOf(v); typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return errNotAPointerToStruct
+	}
+	var (
+		argvList       = ctx.argvList
+		flagSet        = ctx.flagSet
+		persistentList []interface{}
+	)
+	if ctx.command != nil {
+		persistentList = ctx.command.persistentArgvList()
+	}
+	ctx.argvList = []interface{}{v}
+	if len(argvList) > 1 {
+		ctx.argvList = append(ctx.argvList, argvList[1:]...)
+	}
+	err := ctx.parseWith(nil, flagSet.readValueMap(), persistentList...)
+	if err == nil && !ctx.flagSet.hasForce {
+		if ctx.command != nil {
+			err = ctx.command.checkArgCount(ctx.NArg())
+		}
+		if err == nil {
+			err = validateArgv(ctx, v)
+		}
+	}
+	if err != nil {
+		ctx.argvList, ctx.flagSet = argvList, flagSet
+		return err
+	}
+	return nil
+}
+
 // RootArgv returns parsed root args object
 func (ctx *Context) RootArgv() interface{} {
 	if isEmptyArgvList(ctx.argvList) {
@@ -119,6 +309,12 @@ func (ctx *Context) RootArgv() interface{} {
 	return ctx.argvList[index]
 }
 
+// Global returns parsed object created by GlobalFlags of root command, nil
+// returned if root has no GlobalFlags
+func (ctx *Context) Global() interface{} {
+	return ctx.global
+}
+
 // GetArgvList gets argv objects
 func (ctx *Context) GetArgvList(curr interface{}, parents ...interface{}) error {
 	if isEmptyArgvList(ctx.argvList) {
@@ -168,6 +364,16 @@ func (ctx *Context) GetArgvAt(argv interface{}, i int) error {
 	return json.NewDecoder(buf).Decode(argv)
 }
 
+// ParseInto parses args into target like parsing argv of command, target
+// should be a pointer to struct. It's useful for two-phase parsing, e.g.
+// parsing pass-through args of command by another struct.
+func (ctx *Context) ParseInto(args []string, target interface{}) error {
+	flagSet := newFlagSet()
+	flagSet.promptReader = ctx.reader
+	flagSet.bufferedPrompt = ctx.stdinReader
+	return parseArgvListTo(flagSet, args, []interface{}{target}, ctx.color).err
+}
+
 // IsSet determins whether `flag` is set
 func (ctx *Context) IsSet(flag string, aliasFlags ...string) bool {
 	fl, ok := ctx.flagSet.flagMap[flag]
@@ -182,6 +388,107 @@ func (ctx *Context) IsSet(flag string, aliasFlags ...string) bool {
 	return false
 }
 
+// Persistent returns value of flag `name` with or without leading dashes,
+// e.g. "dry-run" or "--dry-run". The flag is either a flag of current command
+// or a persistent flag inherited from ancestors, flag of current command
+// preferred if names collide.
+func (ctx *Context) Persistent(name string) (string, bool) {
+	fl, ok := ctx.lookupFlag(name)
+	if !ok {
+		return "", false
+	}
+	return fl.valueString(), true
+}
+
+// lookupFlag finds parsed flag by name with or without leading dashes
+func (ctx *Context) lookupFlag(name string) (*flag, bool) {
+	if ctx.flagSet == nil {
+		return nil, false
+	}
+	fl, ok := ctx.flagSet.flagMap[name]
+	if !ok {
+		name = strings.TrimLeft(name, dashOne)
+		if len(name) == 1 {
+			fl, ok = ctx.flagSet.flagMap[dashOne+name]
+		} else {
+			fl, ok = ctx.flagSet.flagMap[dashTwo+name]
+		}
+	}
+	return fl, ok
+}
+
+// DumpFlags returns flags and their values line by line, e.g.
+//
+//	--host=localhost
+//	--token=****
+//
+// Values of flags which have tag `secret` or `pw` are masked, while values
+// of argv are kept.
+func (ctx *Context) DumpFlags() string {
+	if ctx.flagSet == nil {
+		return ""
+	}
+	buf := bytes.NewBufferString("")
+	for _, fl := range ctx.flagSet.flagSlice {
+		names := append(append([]string{}, fl.tag.longNames...), fl.tag.shortNames...)
+		if len(names) == 0 {
+			continue
+		}
+		fmt.Fprintf(buf, "%s=%s\n", names[0], fl.displayValue())
+	}
+	return buf.String()
+}
+
+// LoadConfig reads JSON config file into argv. Values of config file override
+// default values, but flags set from command line keep their values, i.e.
+// command line > config file > env and `dft`. It should be called in
+// Command.LoadDefaults, so that values of config file satisfy required flags,
+// and are normalized and checked the same way as command line, e.g.
+//
+//	LoadDefaults: func(ctx *cli.Context) error {
+//		return ctx.LoadConfigOptional(ctx.Argv().(*argT).Config)
+//	},
+//
+// Values are checked but couldn't satisfy required flags if it's called
+// after flags parsed, e.g. in Fn.
+func (ctx *Context) LoadConfig(filename string) error {
+	argv := ctx.Argv()
+	if argv == nil {
+		return argvError{isEmpty: true}
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := ctx.unmarshalJSON(data, argv, newBindOptions(nil)); err != nil {
+		return configFileError{filename: filename, err: err}
+	}
+	return ctx.checkValues()
+}
+
+// checkValues checks choices, range and validators of flags changed after
+// flags parsed. It's skipped while loading defaults, since all flags are
+// checked after that.
+func (ctx *Context) checkValues() error {
+	fs := ctx.flagSet
+	if fs == nil || fs.loadingDefaults || fs.hasForce {
+		return nil
+	}
+	fs.checkValues(ctx.color)
+	err := fs.err
+	fs.err = nil
+	return err
+}
+
+// LoadConfigOptional likes LoadConfig, but does nothing if file not found
+func (ctx *Context) LoadConfigOptional(filename string) error {
+	err := ctx.LoadConfig(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
 // FormValues returns parsed args as url.Values
 func (ctx *Context) FormValues() url.Values {
 	if ctx.flagSet == nil {
@@ -205,16 +512,288 @@ func (ctx *Context) WriteUsage() {
 	ctx.String(ctx.Usage())
 }
 
-// Writer returns writer
+// Writer returns writer, it's buffered if EnableBuffer called
 func (ctx *Context) Writer() io.Writer {
+	if ctx.bufWriter != nil {
+		return ctx.bufWriter
+	}
+	return ctx.baseWriter()
+}
+
+// baseWriter returns writer under buffer
+func (ctx *Context) baseWriter() io.Writer {
 	if ctx.writer == nil {
-		ctx.writer = colorable.NewColorableStdout()
+		ctx.writer = newStdoutWriter()
 	}
 	return ctx.writer
 }
 
+// EnableBuffer makes output written to writer buffered until Flush called
+// or buffer is full, which reduces writes for output-heavy commands.
+// Buffered output is flushed before reading prompts.
+func (ctx *Context) EnableBuffer() *Context {
+	if ctx.bufWriter == nil {
+		ctx.bufWriter = bufio.NewWriter(ctx.Writer())
+	}
+	return ctx
+}
+
+// Flush writes buffered output to writer, it does nothing if output isn't
+// buffered. It's called after command completed if BufferedOutput of command
+// enabled.
+func (ctx *Context) Flush() error {
+	if ctx.bufWriter == nil {
+		return nil
+	}
+	return ctx.bufWriter.Flush()
+}
+
+// unbufferedWriter flushes buffered output and returns writer under buffer
+func (ctx *Context) unbufferedWriter() io.Writer {
+	ctx.Flush()
+	return ctx.baseWriter()
+}
+
+// Stdin returns buffered reader of prompts, default is PromptReader. It's
+// shared by Prompt, Confirm and flags which have tag `prompt`, so input
+// buffered by them isn't lost.
+func (ctx *Context) Stdin() io.Reader {
+	if r := ctx.stdinReader(); r != nil {
+		return r
+	}
+	return nil
+}
+
+// rawStdin returns reader of prompts under buffer
+func (ctx *Context) rawStdin() io.Reader {
+	if ctx.reader == nil {
+		return PromptReader
+	}
+	return ctx.reader
+}
+
+// stdinReader returns buffered rawStdin, it's created once for ctx, and
+// nil returned if rawStdin is nil
+func (ctx *Context) stdinReader() *bufio.Reader {
+	ctx.stdinMu.Lock()
+	defer ctx.stdinMu.Unlock()
+	if ctx.stdin == nil {
+		r := ctx.rawStdin()
+		if r == nil {
+			return nil
+		}
+		ctx.stdin = bufio.NewReader(r)
+	}
+	return ctx.stdin
+}
+
+// Stderr returns writer for errors, default is stderr
+func (ctx *Context) Stderr() io.Writer {
+	if ctx.errWriter == nil {
+		ctx.errWriter = colorable.NewColorableStderr()
+	}
+	return ctx.errWriter
+}
+
+// SetStderr sets writer for errors
+func (ctx *Context) SetStderr(w io.Writer) *Context {
+	ctx.errWriter = w
+	return ctx
+}
+
+// GoContext returns context.Context of running command, it's canceled while
+// SIGINT or SIGTERM received if CancelOnSignal of command enabled, e.g.
+//
+//	select {
+//	case <-ctx.GoContext().Done():
+//		return ctx.GoContext().Err()
+//	case result := <-results:
+//		...
+//	}
+//
+// context.Background() returned if not set.
+func (ctx *Context) GoContext() context.Context {
+	if ctx.goCtx == nil {
+		return context.Background()
+	}
+	return ctx.goCtx
+}
+
+// WithGoContext sets context.Context of ctx
+func (ctx *Context) WithGoContext(goCtx context.Context) *Context {
+	ctx.goCtx = goCtx
+	return ctx
+}
+
+// cancelOnSignal replaces context.Context of ctx by a child which is
+// canceled while any of sigs received, stop should be called to release it
+func (ctx *Context) cancelOnSignal(sigs ...os.Signal) (stop func()) {
+	goCtx, cancel := context.WithCancel(ctx.GoContext())
+	ctx.goCtx = goCtx
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-goCtx.Done():
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		cancel()
+	}
+}
+
+// ErrString writes formatted string to Stderr
+func (ctx *Context) ErrString(format string, args ...interface{}) *Context {
+	fmt.Fprintf(ctx.Stderr(), format, args...)
+	return ctx
+}
+
+// Abort writes formatted message in red to stderr and returns an ExitCoder
+// with code, e.g.
+//
+//	if !exists {
+//		return ctx.Abort(2, "file %s not found", name)
+//	}
+//
+// The error has empty message since the message has been written, so it
+// isn't printed again by RunWithArgs.
+func (ctx *Context) Abort(code int, format string, args ...interface{}) error {
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	if msg != "" {
+		fmt.Fprintln(ctx.Stderr(), ctx.color.Red(msg))
+	}
+	return NewExitError(code, "")
+}
+
+// maxConfirmAttempts is max number of questions asked by Confirm if
+// answer unrecognized
+const maxConfirmAttempts = 3
+
+// Prompt writes question to writer and reads a line from Stdin, the rest of
+// input is kept in Stdin of ctx
+func (ctx *Context) Prompt(question string) (string, error) {
+	fmt.Fprint(ctx, question+": ")
+	return ctx.readLine()
+}
+
+// Confirm writes question to writer and reads answer from Stdin,
+// y/yes/n/no accepted case-insensitively. The question is asked again if
+// answer unrecognized and Stdin is a terminal, up to 3 times.
+func (ctx *Context) Confirm(question string) (bool, error) {
+	attempts := 1
+	if isTerminal, _ := isTerminalReader(ctx.rawStdin()); isTerminal {
+		attempts = maxConfirmAttempts
+	}
+	return ctx.confirm(question, attempts)
+}
+
+func (ctx *Context) confirm(question string, attempts int) (bool, error) {
+	var answer string
+	for i := 0; i < attempts; i++ {
+		fmt.Fprint(ctx, question+" [y/n]: ")
+		line, err := ctx.readLine()
+		if err != nil {
+			return false, err
+		}
+		switch answer = strings.TrimSpace(line); strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+	return false, confirmAnswerError{answer: answer}
+}
+
+// defaultConfirmPhrase is the phrase typed to confirm by
+// Context.ConfirmDestructive if ConfirmPhrase not specified
+const defaultConfirmPhrase = "yes"
+
+// confirmFlags skip confirmation of Context.ConfirmDestructive if set
+var confirmFlags = []string{"--yes", "--force"}
+
+type (
+	// ConfirmOption customizes Context.ConfirmDestructive
+	ConfirmOption func(*confirmOptions)
+
+	confirmOptions struct {
+		phrase string
+	}
+)
+
+// ConfirmPhrase specifies the phrase typed exactly to confirm, e.g. name of
+// the database to delete, it's "yes" by default
+func ConfirmPhrase(phrase string) ConfirmOption {
+	return func(opts *confirmOptions) {
+		opts.phrase = phrase
+	}
+}
+
+// ConfirmDestructive guards a destructive action, e.g.
+//
+//	ok, err := ctx.ConfirmDestructive("Drop database?", cli.ConfirmPhrase(name))
+//	if err != nil || !ok {
+//		return err
+//	}
+//
+// It returns true without asking if boolean flag `--yes` or `--force` set.
+// Otherwise prompt is written to writer, and confirmed only if answer is
+// exactly the phrase, which is "yes" unless ConfirmPhrase specified. It
+// refuses with an error if Stdin isn't a terminal, e.g. piped or a request
+// of HTTPHandler.
+func (ctx *Context) ConfirmDestructive(prompt string, opts ...ConfirmOption) (bool, error) {
+	for _, name := range confirmFlags {
+		if fl, ok := ctx.lookupFlag(name); ok && fl.isBoolean() && fl.getBool() {
+			return true, nil
+		}
+	}
+	if isTerminal, _ := isTerminalReader(ctx.rawStdin()); !isTerminal {
+		return false, errNotInteractive
+	}
+	options := confirmOptions{phrase: defaultConfirmPhrase}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return ctx.confirmPhrase(prompt, options.phrase)
+}
+
+// confirmPhrase asks to type phrase, and reports whether it's typed exactly
+func (ctx *Context) confirmPhrase(prompt, phrase string) (bool, error) {
+	fmt.Fprintf(ctx, "%s Type %q to confirm: ", prompt, phrase)
+	line, err := ctx.readLine()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(line) == phrase, nil
+}
+
+// readLine reads a line from buffered Stdin of ctx without trailing newline,
+// a newline written to writer if input ends without newline.
+func (ctx *Context) readLine() (string, error) {
+	ctx.Flush()
+	r := ctx.stdinReader()
+	if r == nil {
+		fmt.Fprintln(ctx)
+		return "", io.EOF
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(ctx)
+		if err != io.EOF || line == "" {
+			return "", err
+		}
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
 // Write implements io.Writer
 func (ctx *Context) Write(data []byte) (n int, err error) {
+	ctx.outputMu.Lock()
+	defer ctx.outputMu.Unlock()
+	ctx.clearSpinner()
 	return ctx.Writer().Write(data)
 }
 
@@ -225,34 +804,235 @@ func (ctx *Context) Color() *color.Color {
 
 // String writes formatted string to writer
 func (ctx *Context) String(format string, args ...interface{}) *Context {
-	fmt.Fprintf(ctx.Writer(), format, args...)
+	fmt.Fprintf(ctx, format, args...)
 	return ctx
 }
 
-// JSON writes json string of obj to writer
+// JSON writes json string of obj to writer, error is logged at LevelDebug,
+// use JSONE if error wanted
 func (ctx *Context) JSON(obj interface{}) *Context {
-	data, err := json.Marshal(obj)
-	if err == nil {
-		fmt.Fprint(ctx.Writer(), string(data))
+	if err := ctx.JSONE(obj); err != nil {
+		ctx.Logf(LevelDebug, "JSON: %v", err)
 	}
 	return ctx
 }
 
+// JSONE writes json string of obj to writer, error of marshaling or writing
+// returned
+func (ctx *Context) JSONE(obj interface{}) error {
+	return ctx.JSONTo(ctx, obj)
+}
+
+// JSONTo writes json string of obj to w, error of marshaling or writing
+// returned
+func (ctx *Context) JSONTo(w io.Writer, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
 // JSONln writes json string of obj end with "\n" to writer
 func (ctx *Context) JSONln(obj interface{}) *Context {
 	return ctx.JSON(obj).String("\n")
 }
 
-// JSONIndent writes pretty json string of obj to writer
+// JSONlnE writes json string of obj end with "\n" to writer, nothing
+// written if marshaling failed
+func (ctx *Context) JSONlnE(obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Write(append(data, '\n'))
+	return err
+}
+
+// JSONIndent writes pretty json string of obj to writer, error is logged
+// at LevelDebug, use JSONIndentE if error wanted
 func (ctx *Context) JSONIndent(obj interface{}, prefix, indent string) *Context {
-	data, err := json.MarshalIndent(obj, prefix, indent)
-	if err == nil {
-		fmt.Fprint(ctx.Writer(), string(data))
+	if err := ctx.JSONIndentE(obj, prefix, indent); err != nil {
+		ctx.Logf(LevelDebug, "JSONIndent: %v", err)
 	}
 	return ctx
 }
 
+// JSONIndentE writes pretty json string of obj to writer, error of
+// marshaling or writing returned
+func (ctx *Context) JSONIndentE(obj interface{}, prefix, indent string) error {
+	return ctx.JSONIndentTo(ctx, obj, prefix, indent)
+}
+
+// JSONIndentTo writes pretty json string of obj to w, error of marshaling
+// or writing returned
+func (ctx *Context) JSONIndentTo(w io.Writer, obj interface{}, prefix, indent string) error {
+	data, err := json.MarshalIndent(obj, prefix, indent)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
 // JSONIndentln writes pretty json string of obj end with "\n" to writer
 func (ctx *Context) JSONIndentln(obj interface{}, prefix, indent string) *Context {
 	return ctx.JSONIndent(obj, prefix, indent).String("\n")
 }
+
+// JSONIndentlnE writes pretty json string of obj end with "\n" to writer,
+// nothing written if marshaling failed
+func (ctx *Context) JSONIndentlnE(obj interface{}, prefix, indent string) error {
+	data, err := json.MarshalIndent(obj, prefix, indent)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Write(append(data, '\n'))
+	return err
+}
+
+// JSONLinesEncoder writes objects as JSON lines (http://jsonlines.org), it's
+// safe for concurrent use and each line written by a single Write.
+type JSONLinesEncoder struct {
+	mu sync.Mutex
+	w  io.Writer
+	f  io.Writer // flushed after each line, it's w or writer under w
+}
+
+// NewJSONLinesEncoder creates a JSONLinesEncoder which writes to writer
+func (ctx *Context) NewJSONLinesEncoder() *JSONLinesEncoder {
+	return &JSONLinesEncoder{w: ctx, f: ctx.Writer()}
+}
+
+// Encode writes compact json string of obj end with "\n", and flushes
+// writer if it's a http.Flusher or has method `Flush() error`
+func (enc *JSONLinesEncoder) Encode(obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	if _, err := enc.w.Write(data); err != nil {
+		return err
+	}
+	switch f := enc.f.(type) {
+	case http.Flusher:
+		f.Flush()
+	case interface{ Flush() error }:
+		return f.Flush()
+	}
+	return nil
+}
+
+// XML writes xml string of obj to writer
+func (ctx *Context) XML(obj interface{}) *Context {
+	data, err := xml.Marshal(obj)
+	if err == nil {
+		fmt.Fprint(ctx, string(data))
+	}
+	return ctx
+}
+
+// XMLIndent writes pretty xml string of obj to writer
+func (ctx *Context) XMLIndent(obj interface{}, prefix, indent string) *Context {
+	data, err := xml.MarshalIndent(obj, prefix, indent)
+	if err == nil {
+		fmt.Fprint(ctx, string(data))
+	}
+	return ctx
+}
+
+// XMLWithHeader writes xml declaration `<?xml version="1.0" encoding="UTF-8"?>`
+// followed by pretty xml string of obj to writer, xml is compact if both
+// prefix and indent are empty
+func (ctx *Context) XMLWithHeader(obj interface{}, prefix, indent string) *Context {
+	data, err := xml.MarshalIndent(obj, prefix, indent)
+	if err == nil {
+		fmt.Fprint(ctx, xml.Header+string(data))
+	}
+	return ctx
+}
+
+// YAMLMarshaler marshals obj to yaml, it's used by YAML/YAMLE.
+// cli doesn't depend on any yaml package, so set it before using YAML, e.g.
+//
+//	cli.YAMLMarshaler = yaml.Marshal // gopkg.in/yaml.v2
+var YAMLMarshaler func(obj interface{}) ([]byte, error)
+
+// YAMLE writes yaml string of obj to writer and returns error if marshal failed
+func (ctx *Context) YAMLE(obj interface{}) error {
+	return ctx.writeYAML(obj, false)
+}
+
+// writeYAML writes yaml string of obj to writer, "\n" appended if ln and
+// yaml string doesn't end with it
+func (ctx *Context) writeYAML(obj interface{}, ln bool) error {
+	if YAMLMarshaler == nil {
+		return errYAMLMarshalerNotSet
+	}
+	data, err := YAMLMarshaler(obj)
+	if err != nil {
+		return err
+	}
+	if ln && (len(data) == 0 || data[len(data)-1] != '\n') {
+		data = append(data[:len(data):len(data)], '\n')
+	}
+	_, err = ctx.Write(data)
+	return err
+}
+
+// YAML writes yaml string of obj to writer
+func (ctx *Context) YAML(obj interface{}) *Context {
+	ctx.YAMLE(obj)
+	return ctx
+}
+
+// YAMLln writes yaml string of obj end with "\n" to writer, "\n" isn't
+// appended if yaml string ends with it, e.g. output of gopkg.in/yaml.v2
+func (ctx *Context) YAMLln(obj interface{}) *Context {
+	ctx.writeYAML(obj, true)
+	return ctx
+}
+
+// TOMLEncoder represents an encoder which encodes obj as toml
+type TOMLEncoder interface {
+	Encode(obj interface{}) error
+}
+
+// NewTOMLEncoder creates a TOMLEncoder which writes to w, it's used by TOML/TOMLE.
+// cli doesn't depend on any toml package, so set it before using TOML, e.g.
+//
+//	cli.NewTOMLEncoder = func(w io.Writer) cli.TOMLEncoder {
+//		return toml.NewEncoder(w) // github.com/BurntSushi/toml
+//	}
+var NewTOMLEncoder func(w io.Writer) TOMLEncoder
+
+// TOMLE writes toml string of obj to writer and returns error if encode failed
+func (ctx *Context) TOMLE(obj interface{}) error {
+	if NewTOMLEncoder == nil {
+		return errTOMLEncoderNotSet
+	}
+	buf := new(bytes.Buffer)
+	if err := NewTOMLEncoder(buf).Encode(obj); err != nil {
+		return err
+	}
+	_, err := ctx.Write(buf.Bytes())
+	return err
+}
+
+// TOML writes toml string of obj to writer
+func (ctx *Context) TOML(obj interface{}) *Context {
+	ctx.TOMLE(obj)
+	return ctx
+}
+
+// TOMLln writes toml string of obj end with "\n" to writer
+func (ctx *Context) TOMLln(obj interface{}) *Context {
+	if ctx.TOMLE(obj) == nil {
+		ctx.String("\n")
+	}
+	return ctx
+}
@@ -1,9 +1,21 @@
 package cli
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/labstack/gommon/color"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -130,3 +142,887 @@ func TestContextWriter(t *testing.T) {
 }
 end`)
 }
+
+func TestContextStderr(t *testing.T) {
+	type argT struct {
+		Name string `cli:"name" usage:"your name"`
+	}
+	var (
+		w    = bytes.NewBufferString("")
+		ew   = bytes.NewBufferString("")
+		root = &Command{
+			Name:        "root",
+			Argv:        func() interface{} { return new(argT) },
+			CanSubRoute: true,
+			NumArg:      AtMost(1),
+			Stderr:      ew,
+			Fn: func(ctx *Context) error {
+				ctx.JSONln(ctx.Args())
+				ctx.String("data\n")
+				ctx.ErrString("error: %s\n", "oops")
+				assert.Equal(t, ew, ctx.Stderr())
+				return nil
+			},
+		}
+	)
+	assert.Nil(t, root.RunWith([]string{"a"}, w, nil))
+	assert.Equal(t, "[\"a\"]\ndata\n", w.String())
+	assert.Equal(t, "error: oops\n", ew.String())
+
+	// usage on failure written to stderr
+	w.Reset()
+	ew.Reset()
+	assert.Nil(t, root.RunWith([]string{"a", "b"}, w, nil))
+	assert.Equal(t, "", w.String())
+	assert.Equal(t, "Options:\n\n  --name   your name\n", ew.String())
+
+	ctx := &Context{writer: w}
+	ew2 := bytes.NewBufferString("")
+	ctx.SetStderr(ew2).ErrString("%d", 1)
+	assert.Equal(t, "1", ew2.String())
+}
+func TestContextAbort(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	root := &Command{
+		Name: "app",
+		Fn: func(ctx *Context) error {
+			ctx.String("before\n")
+			return ctx.Abort(4, "file %s not found", "a.txt")
+		},
+	}
+	err := root.RunWithOptions(nil, WithStdout(&stdout), WithStderr(&stderr))
+	assert.Equal(t, 4, ExitCodeOf(err))
+	assert.Equal(t, "before\n", stdout.String())
+	assert.Equal(t, "file a.txt not found\n", stderr.String())
+	assert.Equal(t, 4, RunWithArgs(new(struct{}), []string{"app"}, func(ctx *Context) error {
+		return ctx.Abort(4, "")
+	}))
+
+	// colorized
+	w := bytes.NewBufferString("")
+	ctx := &Context{errWriter: w}
+	err = ctx.Abort(2, "oops\n")
+	assert.Equal(t, 2, ExitCodeOf(err))
+	assert.Equal(t, ctx.color.Red("oops")+"\n", w.String())
+	assert.NotEqual(t, "oops\n", w.String())
+}
+
+func TestContextYAML(t *testing.T) {
+	type objT struct {
+		Name  string
+		Tags  []string
+		Attrs map[string]map[string]int
+	}
+	obj := objT{
+		Name:  "cli",
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]map[string]int{"x": {"y": 1, "z": 2}},
+	}
+
+	defer func(m func(interface{}) ([]byte, error)) { YAMLMarshaler = m }(YAMLMarshaler)
+
+	// marshaler not set
+	YAMLMarshaler = nil
+	ctx := &Context{writer: bytes.NewBufferString("")}
+	assert.Equal(t, errYAMLMarshalerNotSet, ctx.YAMLE(obj))
+
+	// json is a subset of yaml
+	YAMLMarshaler = func(obj interface{}) ([]byte, error) {
+		return json.MarshalIndent(obj, "", "  ")
+	}
+	w := bytes.NewBufferString("")
+	ctx = &Context{writer: w}
+	assert.Nil(t, ctx.YAMLE(obj))
+	var got objT
+	assert.Nil(t, json.Unmarshal(w.Bytes(), &got))
+	assert.Equal(t, obj, got)
+
+	w.Reset()
+	ctx.YAMLln(obj)
+	assert.Equal(t, byte('\n'), w.Bytes()[w.Len()-1])
+	got = objT{}
+	assert.Nil(t, json.Unmarshal(w.Bytes(), &got))
+	assert.Equal(t, obj, got)
+
+	// no more "\n" appended if yaml ends with it, like gopkg.in/yaml.v2
+	YAMLMarshaler = func(obj interface{}) ([]byte, error) {
+		return []byte("name: cli\n"), nil
+	}
+	w.Reset()
+	ctx.YAMLln(obj).YAMLln(obj)
+	assert.Equal(t, "name: cli\nname: cli\n", w.String())
+}
+
+func TestContextArgAt(t *testing.T) {
+	clr := color.Color{}
+	clr.Disable()
+	ctx := &Context{color: clr, flagSet: newFlagSet()}
+
+	// empty args
+	assert.Equal(t, "", ctx.ArgAt(0))
+	_, err := ctx.StringArgAt(0)
+	assert.Equal(t, "arg index 0 out of range [0,0)", err.Error())
+
+	ctx.flagSet.args = []string{"abc", "12", "-3", "1.5", "yes"}
+	assert.Equal(t, "abc", ctx.ArgAt(0))
+	assert.Equal(t, "", ctx.ArgAt(-1))
+	assert.Equal(t, "", ctx.ArgAt(5))
+
+	n, err := ctx.IntArgAt(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, n)
+	n, err = ctx.IntArgAt(2)
+	assert.Nil(t, err)
+	assert.Equal(t, -3, n)
+	n, err = ctx.IntArgAt(0)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, "0th arg invalid: `abc' couldn't converted to an int", err.Error())
+	n, err = ctx.IntArgAt(-1)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, "arg index -1 out of range [0,5)", err.Error())
+
+	u, err := ctx.UintArgAt(1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(12), u)
+	_, err = ctx.UintArgAt(2)
+	assert.Error(t, err)
+
+	f, err := ctx.FloatArgAt(3)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, f)
+	_, err = ctx.FloatArgAt(9)
+	assert.Error(t, err)
+
+	b, err := ctx.BoolArgAt(4)
+	assert.Nil(t, err)
+	assert.True(t, b)
+	_, err = ctx.BoolArgAt(0)
+	assert.Error(t, err)
+
+	c := new(customT)
+	assert.Error(t, ctx.DecodeArgAt(0, c))
+	ctx.flagSet.args = []string{`{"K1":"v","K2":2}`}
+	assert.Nil(t, ctx.DecodeArgAt(0, c))
+	assert.Equal(t, customT{K1: "v", K2: 2}, *c)
+}
+
+// testTOMLEncoder encodes map[string]string as key/value pairs sorted
+// by keys, other types unsupported
+type testTOMLEncoder struct {
+	w io.Writer
+}
+
+func (e testTOMLEncoder) Encode(obj interface{}) error {
+	m, ok := obj.(map[string]string)
+	if !ok {
+		return fmt.Errorf("unsupported type %T", obj)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(e.w, "%s = %q\n", k, m[k])
+	}
+	return nil
+}
+
+func TestContextTOML(t *testing.T) {
+	cfg := map[string]string{"name": "cli", "host": "localhost"}
+
+	defer func(fn func(io.Writer) TOMLEncoder) { NewTOMLEncoder = fn }(NewTOMLEncoder)
+
+	// encoder not set
+	NewTOMLEncoder = nil
+	ctx := &Context{writer: bytes.NewBufferString("")}
+	assert.Equal(t, errTOMLEncoderNotSet, ctx.TOMLE(cfg))
+
+	NewTOMLEncoder = func(w io.Writer) TOMLEncoder { return testTOMLEncoder{w} }
+	w := bytes.NewBufferString("")
+	ctx = &Context{writer: w}
+	ctx.TOML(cfg)
+	assert.Equal(t, "host = \"localhost\"\nname = \"cli\"\n", w.String())
+
+	w.Reset()
+	ctx.TOMLln(cfg)
+	assert.Equal(t, "host = \"localhost\"\nname = \"cli\"\n\n", w.String())
+
+	// silent on error
+	w.Reset()
+	ctx.TOMLln(1)
+	assert.Equal(t, "", w.String())
+	assert.Error(t, ctx.TOMLE(1))
+}
+
+func TestContextXML(t *testing.T) {
+	type addrT struct {
+		City string `xml:"city"`
+		Zip  string `xml:"zip,attr"`
+	}
+	type objT struct {
+		XMLName struct{} `xml:"user"`
+		ID      int      `xml:"id,attr"`
+		Name    string   `xml:"name"`
+		Addr    addrT    `xml:"addr"`
+	}
+	obj := objT{ID: 1, Name: "cli", Addr: addrT{City: "SZ", Zip: "518000"}}
+
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w}
+	ctx.XML(obj)
+	assert.Equal(t, `<user id="1"><name>cli</name><addr zip="518000"><city>SZ</city></addr></user>`, w.String())
+
+	w.Reset()
+	ctx.XMLIndent(obj, "", "  ")
+	assert.Equal(t, `<user id="1">
+  <name>cli</name>
+  <addr zip="518000">
+    <city>SZ</city>
+  </addr>
+</user>`, w.String())
+
+	w.Reset()
+	ctx.XMLWithHeader(obj, "", "")
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<user id="1"><name>cli</name><addr zip="518000"><city>SZ</city></addr></user>`, w.String())
+
+	// unsupported type writes nothing
+	w.Reset()
+	ctx.XML(map[string]int{"a": 1})
+	assert.Equal(t, "", w.String())
+}
+
+func TestContextJSONTo(t *testing.T) {
+	ctxW := bytes.NewBufferString("")
+	ctx := &Context{writer: ctxW}
+	var buf bytes.Buffer
+	obj := map[string]interface{}{"a": 1, "b": []string{"x"}}
+	assert.Nil(t, ctx.JSONTo(&buf, obj))
+	assert.Equal(t, `{"a":1,"b":["x"]}`, buf.String())
+	buf.Reset()
+	assert.Nil(t, ctx.JSONIndentTo(&buf, obj, "", "  "))
+	assert.Equal(t, "{\n  \"a\": 1,\n  \"b\": [\n    \"x\"\n  ]\n}", buf.String())
+	assert.Equal(t, "", ctxW.String())
+
+	// marshal error surfaced, and nothing written
+	buf.Reset()
+	assert.Error(t, ctx.JSONTo(&buf, func() {}))
+	assert.Error(t, ctx.JSONIndentTo(&buf, map[string]interface{}{"ch": make(chan int)}, "", "  "))
+	assert.Equal(t, "", buf.String())
+
+	// JSON and JSONIndent write to writer of ctx
+	ctx.JSON(obj).JSONIndent([]int{1}, "", "")
+	assert.Equal(t, `{"a":1,"b":["x"]}[`+"\n1\n]", ctxW.String())
+}
+
+func TestContextJSONE(t *testing.T) {
+	type objT struct {
+		Name string
+		Ch   chan int
+	}
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w}
+	obj := map[string]int{"a": 1}
+	assert.Nil(t, ctx.JSONE(obj))
+	assert.Nil(t, ctx.JSONlnE(obj))
+	assert.Nil(t, ctx.JSONIndentE(obj, "", "  "))
+	assert.Nil(t, ctx.JSONIndentlnE(obj, "", "  "))
+	assert.Equal(t, "{\"a\":1}{\"a\":1}\n{\n  \"a\": 1\n}{\n  \"a\": 1\n}\n", w.String())
+
+	// marshal error returned, and nothing written
+	w.Reset()
+	bad := objT{Name: "x", Ch: make(chan int)}
+	for i, err := range []error{
+		ctx.JSONE(bad),
+		ctx.JSONlnE(bad),
+		ctx.JSONIndentE(bad, "", "  "),
+		ctx.JSONIndentlnE(bad, "", "  "),
+	} {
+		var typeErr *json.UnsupportedTypeError
+		assert.True(t, errors.As(err, &typeErr), "case %d", i)
+	}
+	assert.Equal(t, "", w.String())
+
+	// swallowed error logged at LevelDebug
+	clr := color.Color{}
+	clr.Disable()
+	ew := bytes.NewBufferString("")
+	ctx = &Context{writer: w, errWriter: ew, color: clr}
+	ctx.JSON(bad)
+	assert.Equal(t, "", ew.String())
+	ctx.SetLogLevel(LevelDebug)
+	ctx.JSON(bad).JSONIndent(bad, "", "  ")
+	assert.Equal(t, "[DEBUG] JSON: json: unsupported type: chan int\n[DEBUG] JSONIndent: json: unsupported type: chan int\n", ew.String())
+	assert.Equal(t, "", w.String())
+}
+
+func TestContextJSONLines(t *testing.T) {
+	type recordT struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w}
+	enc := ctx.NewJSONLinesEncoder()
+	for i, name := range []string{"a", "b", "c"} {
+		assert.Nil(t, enc.Encode(recordT{ID: i, Name: name}))
+	}
+	assert.Equal(t, "{\"id\":0,\"name\":\"a\"}\n{\"id\":1,\"name\":\"b\"}\n{\"id\":2,\"name\":\"c\"}\n", w.String())
+	assert.NotNil(t, enc.Encode(func() {}))
+
+	// flushed after each record
+	w.Reset()
+	bw := bufio.NewWriter(w)
+	enc = (&Context{writer: bw}).NewJSONLinesEncoder()
+	assert.Nil(t, enc.Encode(recordT{ID: 1}))
+	assert.Equal(t, "{\"id\":1,\"name\":\"\"}\n", w.String())
+
+	// lines never interleaved
+	w.Reset()
+	enc = ctx.NewJSONLinesEncoder()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			enc.Encode(recordT{ID: i, Name: strings.Repeat("x", i)})
+		}(i)
+	}
+	wg.Wait()
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	assert.Equal(t, 50, len(lines))
+	for _, line := range lines {
+		var r recordT
+		assert.Nil(t, json.Unmarshal([]byte(line), &r))
+		assert.Equal(t, strings.Repeat("x", r.ID), r.Name)
+	}
+}
+
+func TestContextLoadConfig(t *testing.T) {
+	type argT struct {
+		Host string `cli:"host" dft:"localhost" json:"host"`
+		Port int    `cli:"port" dft:"80" json:"port"`
+		User string `cli:"user" json:"user"`
+	}
+	dir, err := ioutil.TempDir("", "cli")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "config.json")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(`{"host":"example.com","port":8080}`), 0644))
+
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want argT
+	}{
+		{[]string{}, argT{Host: "example.com", Port: 8080}},
+		{[]string{"--port=9090"}, argT{Host: "example.com", Port: 9090}},
+		// set to default value explicitly
+		{[]string{"--host=localhost", "--user=root"}, argT{Host: "localhost", Port: 8080, User: "root"}},
+	} {
+		argv := new(argT)
+		ctx, err := newContext("", nil, tt.args, []interface{}{argv}, clr)
+		assert.Nil(t, err, "case %d", i)
+		assert.Nil(t, ctx.LoadConfig(filename), "case %d", i)
+		assert.Equal(t, tt.want, *argv, "case %d", i)
+	}
+
+	argv := new(argT)
+	ctx, _ := newContext("", nil, []string{"--port=1"}, []interface{}{argv}, clr)
+
+	// missing file
+	err = ctx.LoadConfig(filepath.Join(dir, "not-found.json"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, ctx.LoadConfigOptional(filepath.Join(dir, "not-found.json")))
+
+	// malformed file keeps values
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(`{"host":"example.com","port":"x"}`), 0644))
+	err = ctx.LoadConfigOptional(filename)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "malformed config file "+filename+": "))
+	}
+	assert.Equal(t, argT{Host: "localhost", Port: 1}, *argv)
+}
+
+func TestCommandLoadDefaults(t *testing.T) {
+	type argT struct {
+		Config string   `cli:"config" dft:"config.json" json:"-"`
+		Host   string   `cli:"*host" json:"host"`
+		Port   int      `cli:"port" dft:"80" min:"1" json:"port"`
+		Mode   string   `cli:"mode" normalize:"lower" choices:"prod|dev" json:"mode"`
+		Tags   []string `cli:"tag" json:"tags"`
+		JSON   bool     `cli:"json" mutex:"output" json:"json"`
+		Table  bool     `cli:"table" mutex:"output" json:"table"`
+	}
+	dir, err := ioutil.TempDir("", "cli")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		filename := filepath.Join(dir, name)
+		assert.Nil(t, ioutil.WriteFile(filename, []byte(content), 0644))
+		return filename
+	}
+	good := write("good.json", `{"host":"example.com","port":8080,"mode":"Prod","tags":["x"]}`)
+
+	var got argT
+	app := &Command{
+		Name: "app",
+		Argv: func() interface{} { return new(argT) },
+		LoadDefaults: func(ctx *Context) error {
+			return ctx.LoadConfigOptional(ctx.Argv().(*argT).Config)
+		},
+		Fn: func(ctx *Context) error {
+			got = *ctx.Argv().(*argT)
+			return nil
+		},
+	}
+	for i, tt := range []struct {
+		args   []string
+		want   argT
+		errMsg string
+	}{
+		// required flag satisfied by config file, values normalized
+		{[]string{"--config", good}, argT{Config: good, Host: "example.com", Port: 8080, Mode: "prod", Tags: []string{"x"}}, ""},
+		// command line wins
+		{[]string{"--config", good, "--host=h", "--tag=a", "--mode=DEV"}, argT{Config: good, Host: "h", Port: 8080, Mode: "dev", Tags: []string{"a"}}, ""},
+		// values of config file checked
+		{[]string{"--config", write("mode.json", `{"host":"h","mode":"test"}`)}, argT{}, "parameter --mode invalid: `test' is not one of prod|dev"},
+		{[]string{"--config", write("port.json", `{"host":"h","port":0}`)}, argT{}, "parameter --port invalid: `0' should be at least 1"},
+		{[]string{"--config", write("mutex.json", `{"host":"h","table":true}`), "--json"}, argT{}, "parameters --json, --table are mutually exclusive"},
+		{[]string{"--config", filepath.Join(dir, "none.json")}, argT{}, "required parameter --host missing"},
+	} {
+		got = argT{}
+		err := app.RunWithOptions(tt.args, WithStdout(ioutil.Discard), WithStderr(ioutil.Discard))
+		if tt.errMsg != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Contains(t, err.Error(), tt.errMsg, "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, got, "case %d", i)
+		}
+	}
+}
+
+func TestContextPrompt(t *testing.T) {
+	defer func(r io.Reader) { PromptReader = r }(PromptReader)
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w}
+
+	PromptReader = strings.NewReader("Alice\r\nBob")
+	name, err := ctx.Prompt("name")
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice", name)
+	name, err = ctx.Prompt("name")
+	assert.Nil(t, err)
+	assert.Equal(t, "Bob", name)
+	assert.Equal(t, "name: name: \n", w.String())
+	_, err = ctx.Prompt("name")
+	assert.Equal(t, io.EOF, err)
+
+	// rest of input kept for Confirm and Stdin
+	ctx = &Context{writer: w}
+	PromptReader = strings.NewReader("Alice\nyes\nrest")
+	name, err = ctx.Prompt("name")
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice", name)
+	yes, err := ctx.Confirm("ok?")
+	assert.Nil(t, err)
+	assert.True(t, yes)
+	rest, _ := ioutil.ReadAll(ctx.Stdin())
+	assert.Equal(t, "rest", string(rest))
+
+	for i, tt := range []struct {
+		input    string
+		attempts int
+		yes      bool
+		err      string
+		out      string
+	}{
+		{"y\n", 1, true, "", "ok? [y/n]: "},
+		{"YES\n", 1, true, "", "ok? [y/n]: "},
+		{"n\n", 1, false, "", "ok? [y/n]: "},
+		{" No \n", 1, false, "", "ok? [y/n]: "},
+		{"maybe\ny\n", 1, false, "`maybe' isn't an answer of yes or no", "ok? [y/n]: "},
+		{"maybe\ny\n", 3, true, "", "ok? [y/n]: ok? [y/n]: "},
+		{"a\nb\nc\ny\n", 3, false, "`c' isn't an answer of yes or no", "ok? [y/n]: ok? [y/n]: ok? [y/n]: "},
+		{"", 3, false, "EOF", "ok? [y/n]: \n"},
+	} {
+		w.Reset()
+		PromptReader = strings.NewReader(tt.input)
+		ctx := &Context{writer: w}
+		yes, err := ctx.confirm("ok?", tt.attempts)
+		if tt.err != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.err, err.Error(), "case %d", i)
+			}
+		} else {
+			assert.Nil(t, err, "case %d", i)
+		}
+		assert.Equal(t, tt.yes, yes, "case %d", i)
+		assert.Equal(t, tt.out, w.String(), "case %d", i)
+	}
+
+	// non-terminal input asks once
+	w.Reset()
+	PromptReader = strings.NewReader("maybe\ny\n")
+	ctx = &Context{writer: w}
+	_, err = ctx.Confirm("ok?")
+	assert.Error(t, err)
+	assert.Equal(t, "ok? [y/n]: ", w.String())
+}
+
+// multiReader isn't comparable
+type multiReader struct {
+	readers []io.Reader
+}
+
+func (r multiReader) Read(p []byte) (int, error) {
+	return io.MultiReader(r.readers...).Read(p)
+}
+
+func TestContextStdinShared(t *testing.T) {
+	type argT struct {
+		Name string `cli:"name" prompt:"name"`
+	}
+	var (
+		age  string
+		rest []byte
+	)
+	app := &Command{
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			var err error
+			if age, err = ctx.Prompt("age"); err != nil {
+				return err
+			}
+			rest, err = ioutil.ReadAll(ctx.Stdin())
+			return err
+		},
+	}
+	stdin := multiReader{readers: []io.Reader{strings.NewReader("tom\n18\nleft\nover")}}
+	w := bytes.NewBufferString("")
+	assert.Nil(t, app.RunWithOptions(nil, WithStdin(stdin), WithStdout(w)))
+	assert.Equal(t, "18", age)
+	assert.Equal(t, "left\nover", string(rest))
+	assert.Equal(t, "name: age: ", w.String())
+}
+
+func TestContextConfirmDestructive(t *testing.T) {
+	type argT struct {
+		Yes bool `cli:"y,yes"`
+	}
+	type forceT struct {
+		Force bool `cli:"!f,force"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		argv interface{}
+		want bool
+	}{
+		{[]string{"-y"}, new(argT), true},
+		{[]string{"--yes"}, new(argT), true},
+		{[]string{"--force"}, new(forceT), true},
+	} {
+		ctx, err := newContext("", nil, tt.args, []interface{}{tt.argv}, clr)
+		assert.Nil(t, err, "case %d", i)
+		w := bytes.NewBufferString("")
+		ctx.writer = w
+		ok, err := ctx.ConfirmDestructive("Drop db?")
+		assert.Nil(t, err, "case %d", i)
+		assert.Equal(t, tt.want, ok, "case %d", i)
+		assert.Equal(t, "", w.String(), "case %d", i)
+	}
+
+	// refused if stdin isn't a terminal
+	devNull, err := os.Open(os.DevNull)
+	assert.Nil(t, err)
+	defer devNull.Close()
+	for i, r := range []io.Reader{devNull, strings.NewReader("yes\n")} {
+		w := bytes.NewBufferString("")
+		ctx := &Context{writer: w, reader: r}
+		ok, err := ctx.ConfirmDestructive("Drop db?", ConfirmPhrase("db"))
+		assert.Equal(t, errNotInteractive, err, "case %d", i)
+		assert.False(t, ok, "case %d", i)
+		assert.Equal(t, "", w.String(), "case %d", i)
+	}
+
+	// phrase typed exactly
+	for i, tt := range []struct {
+		phrase string
+		input  string
+		want   bool
+		out    string
+	}{
+		{"yes", "yes\n", true, `Drop db? Type "yes" to confirm: `},
+		{"yes", " yes \n", true, `Drop db? Type "yes" to confirm: `},
+		{"yes", "y\n", false, `Drop db? Type "yes" to confirm: `},
+		{"yes", "YES\n", false, `Drop db? Type "yes" to confirm: `},
+		{"delete my-db", "delete my-db\n", true, `Drop db? Type "delete my-db" to confirm: `},
+	} {
+		w := bytes.NewBufferString("")
+		ctx := &Context{writer: w, reader: strings.NewReader(tt.input)}
+		ok, err := ctx.confirmPhrase("Drop db?", tt.phrase)
+		assert.Nil(t, err, "case %d", i)
+		assert.Equal(t, tt.want, ok, "case %d", i)
+		assert.Equal(t, tt.out, w.String(), "case %d", i)
+	}
+}
+
+func TestContextDumpFlags(t *testing.T) {
+	type argT struct {
+		Host     string `cli:"host" dft:"localhost"`
+		Token    string `cli:"t,token" secret:"true" dft:"abc"`
+		Password string `pw:"p,password"`
+		Verbose  bool   `cli:"v"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	argv := new(argT)
+	ctx, err := newContext("", nil, []string{"--token=xyz", "-p", "123", "-v"}, []interface{}{argv}, clr)
+	assert.Nil(t, err)
+	assert.Equal(t, "--host=localhost\n--token=****\n--password=****\n-v=true\n", ctx.DumpFlags())
+	assert.Equal(t, argT{Host: "localhost", Token: "xyz", Password: "123", Verbose: true}, *argv)
+
+	for _, style := range []UsageStyle{NormalStyle, ManualStyle} {
+		got := usage([]interface{}{new(argT)}, clr, style)
+		assert.Contains(t, got, "[=****]")
+		assert.NotContains(t, got, "abc")
+	}
+
+	type invalidT struct {
+		Token string `cli:"token" secret:"yes"`
+	}
+	_, err = newContext("", nil, []string{}, []interface{}{new(invalidT)}, clr)
+	assert.Error(t, err)
+}
+
+func TestContextParseInto(t *testing.T) {
+	type argT struct {
+		Verbose bool `cli:"v"`
+	}
+	type passT struct {
+		Host  string `cli:"*host"`
+		Port  int    `cli:"p,port" dft:"80"`
+		Debug bool   `cli:"v"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	argv := new(argT)
+	ctx, err := newContext("", nil, []string{"-v", "--", "--host=x", "-p", "8080"}, []interface{}{argv}, clr)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"--host=x", "-p", "8080"}, ctx.Args())
+
+	pass := new(passT)
+	assert.Nil(t, ctx.ParseInto(ctx.Args(), pass))
+	assert.Equal(t, passT{Host: "x", Port: 8080}, *pass)
+	assert.Equal(t, argT{Verbose: true}, *argv)
+	assert.True(t, ctx.IsSet("-v"))
+
+	// structured errors
+	err = ctx.ParseInto([]string{"-p", "x", "--host=y"}, new(passT))
+	assert.IsType(t, TypeConversionError{}, err)
+	err = ctx.ParseInto([]string{}, new(passT))
+	if assert.IsType(t, MissingRequiredError{}, err) {
+		assert.Equal(t, "required parameter --host missing", err.Error())
+	}
+	assert.Equal(t, errNotAPointer, ctx.ParseInto(nil, passT{}))
+}
+
+func TestContextSetArgv(t *testing.T) {
+	type argT struct {
+		Profile string `cli:"profile"`
+		Port    int    `cli:"p,port"`
+	}
+	type prodT struct {
+		Profile string `cli:"profile"`
+		Port    int    `cli:"p,port" dft:"443"`
+		Host    string `cli:"host" dft:"example.com"`
+	}
+	type strictT struct {
+		Profile string `cli:"profile"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	argv := new(argT)
+	ctx, err := newContext("", nil, []string{"--profile=prod", "a", "-p", "8080", "b"}, []interface{}{argv}, clr)
+	assert.Nil(t, err)
+	assert.Equal(t, argT{Profile: "prod", Port: 8080}, *argv)
+	args := ctx.Args()
+	assert.Equal(t, []string{"a", "b"}, args)
+
+	prod := new(prodT)
+	assert.Nil(t, ctx.SetArgv(prod))
+	assert.Equal(t, prodT{Profile: "prod", Port: 8080, Host: "example.com"}, *prod)
+	assert.Equal(t, prod, ctx.Argv())
+	assert.Equal(t, args, ctx.Args())
+	assert.True(t, ctx.IsSet("--port"))
+	assert.False(t, ctx.IsSet("--host"))
+
+	// conflict keeps the current argv
+	err = ctx.SetArgv(new(strictT))
+	if assert.Error(t, err) {
+		assert.Equal(t, "undefined option -p", err.Error())
+	}
+	assert.Equal(t, prod, ctx.Argv())
+	assert.True(t, ctx.IsSet("--port"))
+	assert.Equal(t, errNotAPointerToStruct, ctx.SetArgv(prodT{}))
+	assert.Equal(t, errNotAPointerToStruct, ctx.SetArgv(nil))
+}
+
+type setArgvT struct {
+	Name  string `cli:"name" prompt:"name"`
+	Email string `cli:"email" prompt:"email"`
+	Data  string `cli:"data" fromfile:"true"`
+}
+
+func (argv *setArgvT) Validate(ctx *Context) error {
+	if argv.Name == "" {
+		return fmt.Errorf("name required")
+	}
+	return nil
+}
+
+func TestContextSetArgvReadValues(t *testing.T) {
+	type argT struct {
+		Name string `cli:"name" prompt:"name"`
+		Data string `cli:"data" fromfile:"true"`
+	}
+	for i, tt := range []struct {
+		args  []string
+		stdin string
+		want  setArgvT
+		err   string
+	}{
+		// value prompted is reused, and new prompt flag isn't prompted
+		{stdin: "Alice\nalice@example.com\n", want: setArgvT{Name: "Alice"}},
+		// stdin of `@-` isn't read again
+		{args: []string{"--name=Bob", "--data=@-"}, stdin: "from stdin", want: setArgvT{Name: "Bob", Data: "from stdin"}},
+		// validator of new argv is checked
+		{args: []string{"--data=x"}, err: "name required"},
+	} {
+		var (
+			got    setArgvT
+			argv   interface{}
+			setErr error
+		)
+		app := &Command{
+			Argv: func() interface{} { return new(argT) },
+			Fn: func(ctx *Context) error {
+				v := new(setArgvT)
+				if setErr = ctx.SetArgv(v); setErr == nil {
+					got = *v
+				}
+				argv = ctx.Argv()
+				return nil
+			},
+		}
+		err := app.RunWithOptions(tt.args, WithStdin(strings.NewReader(tt.stdin)), WithStdout(ioutil.Discard))
+		assert.Nil(t, err, "case %d", i)
+		if tt.err != "" {
+			if assert.Error(t, setErr, "case %d", i) {
+				assert.Equal(t, tt.err, setErr.Error(), "case %d", i)
+			}
+			assert.IsType(t, new(argT), argv, "case %d", i)
+			continue
+		}
+		if assert.Nil(t, setErr, "case %d", i) {
+			assert.Equal(t, tt.want, got, "case %d", i)
+		}
+	}
+}
+
+// countingWriter counts calls of Write
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(data)
+}
+
+// checkingReader calls check before reading
+type checkingReader struct {
+	io.Reader
+	check func()
+}
+
+func (r checkingReader) Read(p []byte) (int, error) {
+	r.check()
+	return r.Reader.Read(p)
+}
+
+func TestContextFlush(t *testing.T) {
+	w := &countingWriter{}
+	ctx := &Context{writer: w}
+	assert.Nil(t, ctx.Flush())
+	ctx.EnableBuffer()
+	for i := 0; i < 1000; i++ {
+		ctx.String("line %d\n", i)
+	}
+	assert.Nil(t, ctx.Flush())
+	var want bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&want, "line %d\n", i)
+	}
+	assert.Equal(t, want.String(), w.String())
+	assert.True(t, w.writes < 10)
+
+	// prompt flushed before reading
+	w = &countingWriter{}
+	ctx = &Context{writer: w}
+	ctx.EnableBuffer()
+	ctx.reader = checkingReader{Reader: strings.NewReader("bob\n"), check: func() {
+		assert.Equal(t, "name: ", w.String())
+	}}
+	name, err := ctx.Prompt("name")
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", name)
+
+	// flushed after command completed, even if panicked
+	for i, fn := range []CommandFunc{
+		func(ctx *Context) error {
+			ctx.String("a").String("b")
+			return nil
+		},
+		func(ctx *Context) error {
+			ctx.String("a").String("b")
+			panic("boom")
+		},
+	} {
+		var out bytes.Buffer
+		cmd := &Command{BufferedOutput: true, Fn: fn}
+		func() {
+			defer func() { recover() }()
+			cmd.RunWithOptions(nil, WithStdout(&out))
+		}()
+		assert.Equal(t, "ab", out.String(), "case %d", i)
+	}
+}
+
+func BenchmarkContextString(b *testing.B) {
+	for _, buffered := range []bool{false, true} {
+		b.Run(fmt.Sprintf("buffered=%v", buffered), func(b *testing.B) {
+			w := &countingWriter{}
+			ctx := &Context{writer: w}
+			if buffered {
+				ctx.EnableBuffer()
+			}
+			for i := 0; i < b.N; i++ {
+				ctx.String("line %d\n", i)
+			}
+			ctx.Flush()
+			b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+		})
+	}
+}
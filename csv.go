@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CSVE writes rows as CSV to writer, rows should be a slice of structs or
+// maps, or pointers to them. Header row consists of names of fields, or
+// keys of maps. Name of field could be specified by tag `csv`, e.g.
+//
+//	type T struct {
+//		Name string `csv:"name"`
+//		Age  int    `csv:"age"`
+//		Note string `csv:"-"` // ignored
+//	}
+//
+// Fields of embedded structs are flattened like encoding/json.
+func (ctx *Context) CSVE(rows interface{}) error {
+	records, err := csvRecords(rows)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(ctx)
+	w.WriteAll(records)
+	return w.Error()
+}
+
+// CSV writes rows as CSV to writer
+func (ctx *Context) CSV(rows interface{}) *Context {
+	ctx.CSVE(rows)
+	return ctx
+}
+
+// TSVE writes rows as tab-separated values to writer, rows are converted
+// like CSVE. Backslashes, tabs, carriage returns and newlines in values are
+// escaped as `\\`, `\t`, `\r` and `\n`, so each row is exactly one line.
+func (ctx *Context) TSVE(rows interface{}) error {
+	records, err := csvRecords(rows)
+	if err != nil {
+		return err
+	}
+	w := io.Writer(ctx)
+	for _, record := range records {
+		for i := range record {
+			record[i] = tsvEscaper.Replace(record[i])
+		}
+		if _, err := io.WriteString(w, strings.Join(record, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TSV writes rows as tab-separated values to writer
+func (ctx *Context) TSV(rows interface{}) *Context {
+	ctx.TSVE(rows)
+	return ctx
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// csvRecords converts rows to records, the first record is header
+func csvRecords(rows interface{}) ([][]string, error) {
+	val := reflect.ValueOf(rows)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, fmt.Errorf("unsupported type %T for CSV, want a slice of structs or maps", rows)
+	}
+	typ := val.Type().Elem()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Struct:
+		fields := structFields(typ, "csv", nil)
+		header := make([]string, 0, len(fields))
+		for _, f := range fields {
+			header = append(header, f.name)
+		}
+		records := [][]string{header}
+		for i := 0; i < val.Len(); i++ {
+			elem := indirectValue(val.Index(i))
+			record := make([]string, len(fields))
+			if elem.IsValid() {
+				for j, f := range fields {
+					record[j] = csvFieldValue(elem, f.index)
+				}
+			}
+			records = append(records, record)
+		}
+		return records, nil
+
+	case reflect.Map:
+		keys := map[string]reflect.Value{}
+		for i := 0; i < val.Len(); i++ {
+			elem := indirectValue(val.Index(i))
+			if !elem.IsValid() {
+				continue
+			}
+			for _, key := range elem.MapKeys() {
+				keys[fmt.Sprint(key.Interface())] = key
+			}
+		}
+		if len(keys) == 0 {
+			return nil, nil
+		}
+		header := make([]string, 0, len(keys))
+		for name := range keys {
+			header = append(header, name)
+		}
+		sort.Strings(header)
+		records := [][]string{header}
+		for i := 0; i < val.Len(); i++ {
+			elem := indirectValue(val.Index(i))
+			record := make([]string, len(header))
+			if elem.IsValid() {
+				for j, name := range header {
+					record[j] = csvFormat(elem.MapIndex(keys[name]))
+				}
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+	return nil, fmt.Errorf("unsupported element type %s for CSV, want struct or map", typ)
+}
+
+// structFields returns exported fields of struct typ, fields of embedded
+// structs are flattened. Names of fields could be specified by tag key,
+// and fields tagged by "-" ignored.
+func structFields(typ reflect.Type, key string, index []int) []structField {
+	var fields []structField
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get(key)
+		if tag == "-" || field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && tag == "" && fieldType.Kind() == reflect.Struct {
+			fields = append(fields, structFields(fieldType, key, fieldIndex)...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, structField{name: name, index: fieldIndex})
+	}
+	return fields
+}
+
+// csvFieldValue formats field by index, empty string returned if
+// any embedded pointer is nil
+func csvFieldValue(val reflect.Value, index []int) string {
+	for _, i := range index {
+		val = indirectValue(val)
+		if !val.IsValid() {
+			return ""
+		}
+		val = val.Field(i)
+	}
+	return csvFormat(val)
+}
+
+// csvFormat formats val by fmt, nil pointer formatted as empty string
+func csvFormat(val reflect.Value) string {
+	val = indirectValue(val)
+	if !val.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(val.Interface())
+}
+
+// indirectValue dereferences pointers and interfaces, invalid value
+// returned if nil encountered
+func indirectValue(val reflect.Value) reflect.Value {
+	for val.IsValid() && (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) {
+		if val.IsNil() {
+			return reflect.Value{}
+		}
+		val = val.Elem()
+	}
+	return val
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type csvBaseT struct {
+	ID int `csv:"id"`
+}
+
+type csvUserT struct {
+	csvBaseT
+	Name    string `csv:"name"`
+	Age     *int
+	Note    string `csv:"-"`
+	private string
+}
+
+func TestCSV(t *testing.T) {
+	age := 10
+	for i, tt := range []struct {
+		rows interface{}
+		want string
+	}{
+		{
+			rows: []csvUserT{
+				{csvBaseT{1}, "Tom", &age, "x", "y"},
+				{csvBaseT{2}, "Jerry, Jr.", nil, "", ""},
+			},
+			want: "id,name,Age\n1,Tom,10\n2,\"Jerry, Jr.\",\n",
+		},
+		{
+			rows: []*csvUserT{{Name: `say "hi"`}, nil, {Name: "a\nb"}},
+			want: "id,name,Age\n0,\"say \"\"hi\"\"\",\n,,\n0,\"a\nb\",\n",
+		},
+		{
+			rows: []csvUserT{},
+			want: "id,name,Age\n",
+		},
+		{
+			rows: []map[string]interface{}{
+				{"b": 1.5, "a": "x"},
+				{"c": true},
+			},
+			want: "a,b,c\nx,1.5,\n,,true\n",
+		},
+		{
+			rows: []map[string]int{},
+			want: "",
+		},
+	} {
+		w := bytes.NewBufferString("")
+		ctx := &Context{writer: w}
+		assert.Nil(t, ctx.CSVE(tt.rows), "case %d", i)
+		assert.Equal(t, tt.want, w.String(), "case %d", i)
+	}
+
+	ctx := &Context{writer: bytes.NewBufferString("")}
+	err := ctx.CSVE(csvUserT{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "unsupported type cli.csvUserT for CSV, want a slice of structs or maps", err.Error())
+	}
+	err = ctx.CSVE([]int{1})
+	if assert.Error(t, err) {
+		assert.Equal(t, "unsupported element type int for CSV, want struct or map", err.Error())
+	}
+}
+
+func TestTSV(t *testing.T) {
+	age := 10
+	for i, tt := range []struct {
+		rows interface{}
+		want string
+	}{
+		{
+			rows: []csvUserT{
+				{csvBaseT{1}, "Tom", &age, "x", "y"},
+				{csvBaseT{2}, "Jerry, Jr.", nil, "", ""},
+			},
+			want: "id\tname\tAge\n1\tTom\t10\n2\tJerry, Jr.\t\n",
+		},
+		{
+			rows: []*csvUserT{{Name: "a\tb"}, nil, {Name: "a\nb\\c\r"}},
+			want: "id\tname\tAge\n0\ta\\tb\t\n\t\t\n0\ta\\nb\\\\c\\r\t\n",
+		},
+		{
+			rows: []map[string]interface{}{{"b": `say "hi"`, "a": 1}},
+			want: "a\tb\n1\tsay \"hi\"\n",
+		},
+	} {
+		w := bytes.NewBufferString("")
+		ctx := &Context{writer: w}
+		assert.Nil(t, ctx.TSVE(tt.rows), "case %d", i)
+		assert.Equal(t, tt.want, w.String(), "case %d", i)
+	}
+	assert.Error(t, (&Context{writer: bytes.NewBufferString("")}).TSVE(1))
+}
@@ -0,0 +1,273 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// GenMarkdownDoc writes Markdown documentation of the command and all it's
+// visible descendants to w. Each command has a section containing it's
+// description, synopsis, flags table, examples and links to subcommands.
+func (cmd *Command) GenMarkdownDoc(w io.Writer) error {
+	buff := bytes.NewBufferString("")
+	for i, c := range cmd.completionCommands() {
+		if i > 0 {
+			buff.WriteByte('\n')
+		}
+		if err := c.writeMarkdownSection(buff); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+func (cmd *Command) writeMarkdownSection(buff *bytes.Buffer) error {
+	flags, err := cmd.completionFlags()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buff, "## %s\n\n", cmd.docName(" "))
+	if cmd.Desc != "" {
+		fmt.Fprintf(buff, "%s\n\n", cmd.Desc)
+	}
+	if cmd.Text != "" {
+		fmt.Fprintf(buff, "%s\n\n", strings.TrimRight(cmd.Text, "\n"))
+	}
+	fmt.Fprintf(buff, "```\n%s\n```\n", cmd.docSynopsis(len(flags) > 0))
+	if len(flags) > 0 {
+		buff.WriteString("\n### Flags\n\n")
+		buff.WriteString("| Flag | Type | Default | Description |\n")
+		buff.WriteString("| --- | --- | --- | --- |\n")
+		for _, fl := range flags {
+			names := make([]string, 0, len(fl.tag.shortNames)+len(fl.tag.longNames))
+			for _, name := range append(append([]string{}, fl.tag.shortNames...), fl.tag.longNames...) {
+				names = append(names, "`"+name+"`")
+			}
+			dft := fl.usageDefault()
+			if dft != "" {
+				dft = "`" + dft + "`"
+			}
+			// default value has its own column
+			desc := fl.usageWith(false)
+			if fl.tag.isRequired {
+				desc = strings.TrimSpace(desc + " (required)")
+			}
+			fmt.Fprintf(buff, "| %s | %s | %s | %s |\n",
+				strings.Join(names, ", "),
+				markdownCell(docTypeName(fl.field.Type)),
+				markdownCell(dft),
+				markdownCell(desc),
+			)
+		}
+	}
+	if len(cmd.Examples) > 0 {
+		name := cmd.Root().Name
+		buff.WriteString("\n### Examples\n\n```\n")
+		for i, example := range cmd.Examples {
+			if i > 0 {
+				buff.WriteByte('\n')
+			}
+			if example.Desc != "" {
+				fmt.Fprintf(buff, "# %s\n", example.Desc)
+			}
+			line := example.Command
+			if name != "" {
+				line = replaceAppName(line, name)
+			}
+			fmt.Fprintf(buff, "%s\n", line)
+		}
+		buff.WriteString("```\n")
+	}
+	if children := cmd.visibleChildren(); len(children) > 0 {
+		buff.WriteString("\n### Commands\n\n")
+		for _, child := range children {
+			title := child.docName(" ")
+			fmt.Fprintf(buff, "* [%s](#%s)", title, markdownAnchor(title))
+			if child.Desc != "" {
+				fmt.Fprintf(buff, " - %s", child.Desc)
+			}
+			buff.WriteByte('\n')
+		}
+	}
+	return nil
+}
+
+// docName returns full name of the command led by name of root,
+// names joined by sep
+func (cmd *Command) docName(sep string) string {
+	var names []string
+	if name := cmd.Root().completionName(); name != "" && name != "." {
+		names = append(names, name)
+	}
+	if path := cmd.pathWithSep(sep); path != "" {
+		names = append(names, path)
+	}
+	return strings.Join(names, sep)
+}
+
+// docSynopsis returns command line synopsis of the command
+func (cmd *Command) docSynopsis(hasFlags bool) string {
+	line := cmd.docName(" ")
+	if len(cmd.visibleChildren()) > 0 {
+		line += " <command>"
+	}
+	if hasFlags {
+		line += " [flags]"
+	}
+	if positionals := cmd.positionalsUsage(); positionals != "" {
+		line += " " + positionals
+	}
+	if cmd.CanSubRoute {
+		line += " [args...]"
+	}
+	return strings.TrimSpace(line)
+}
+
+// docTypeName returns short name of type of flag values
+func docTypeName(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Name() != "" {
+		return typ.Name()
+	}
+	return strings.Replace(typ.String(), "cli.", "", -1)
+}
+
+// markdownCell escapes s as content of a table cell
+func markdownCell(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.Replace(s, "|", "\\|", -1)
+	return strings.Replace(s, "\n", "<br>", -1)
+}
+
+// markdownAnchor returns anchor of heading title like GitHub
+func markdownAnchor(title string) string {
+	var buff bytes.Buffer
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r == ' ':
+			buff.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			buff.WriteRune(r)
+		}
+	}
+	return buff.String()
+}
+
+// GenManPage writes roff formatted man page of the command to w, in
+// sections NAME, SYNOPSIS, DESCRIPTION, OPTIONS, EXAMPLES and SEE ALSO.
+// Parent and visible children are referred in SEE ALSO by names joined
+// by `-`, e.g. app-serve(1).
+func (cmd *Command) GenManPage(section int, w io.Writer) error {
+	flags, err := cmd.completionFlags()
+	if err != nil {
+		return err
+	}
+	var (
+		buff  = bytes.NewBufferString("")
+		title = cmd.docName("-")
+	)
+	fmt.Fprintf(buff, ".TH %q %d\n", strings.ToUpper(title), section)
+	buff.WriteString(".SH NAME\n")
+	if cmd.Desc != "" {
+		fmt.Fprintf(buff, "%s \\- %s\n", roffEscape(title), roffEscape(cmd.Desc))
+	} else {
+		fmt.Fprintf(buff, "%s\n", roffEscape(title))
+	}
+	buff.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(buff, ".B %s\n", roffEscape(cmd.docSynopsis(len(flags) > 0)))
+	if cmd.Desc != "" || cmd.Text != "" {
+		buff.WriteString(".SH DESCRIPTION\n")
+		if cmd.Desc != "" {
+			fmt.Fprintf(buff, "%s\n", roffText(cmd.Desc))
+		}
+		if cmd.Text != "" {
+			if cmd.Desc != "" {
+				buff.WriteString(".PP\n")
+			}
+			fmt.Fprintf(buff, "%s\n", roffText(strings.TrimRight(cmd.Text, "\n")))
+		}
+	}
+	if len(flags) > 0 {
+		buff.WriteString(".SH OPTIONS\n")
+		for _, fl := range flags {
+			names := make([]string, 0, len(fl.tag.shortNames)+len(fl.tag.longNames))
+			for _, name := range append(append([]string{}, fl.tag.shortNames...), fl.tag.longNames...) {
+				names = append(names, "\\fB"+roffEscape(name)+"\\fR")
+			}
+			head := strings.Join(names, ", ")
+			if fl.field.Type.Kind() != reflect.Bool {
+				head += " \\fI" + docTypeName(fl.field.Type) + "\\fR"
+			}
+			fmt.Fprintf(buff, ".TP\n%s\n", head)
+			desc := fl.usage()
+			if fl.tag.isRequired {
+				desc = strings.TrimSpace(desc + " (required)")
+			}
+			if dft := fl.usageDefault(); dft != "" && !UsageDefaultInDesc {
+				desc = strings.TrimSpace(desc + " (default: " + dft + ")")
+			}
+			if desc != "" {
+				fmt.Fprintf(buff, "%s\n", roffText(strings.TrimSpace(desc)))
+			}
+		}
+	}
+	if len(cmd.Examples) > 0 {
+		name := cmd.Root().Name
+		buff.WriteString(".SH EXAMPLES\n")
+		for i, example := range cmd.Examples {
+			if i > 0 {
+				buff.WriteString(".PP\n")
+			}
+			if example.Desc != "" {
+				fmt.Fprintf(buff, "%s\n", roffText(example.Desc))
+			}
+			line := example.Command
+			if name != "" {
+				line = replaceAppName(line, name)
+			}
+			fmt.Fprintf(buff, ".PP\n.RS\n.nf\n%s\n.fi\n.RE\n", roffEscape(line))
+		}
+	}
+	var refs []string
+	if cmd.parent != nil {
+		refs = append(refs, cmd.parent.docName("-"))
+	}
+	for _, child := range cmd.visibleChildren() {
+		refs = append(refs, child.docName("-"))
+	}
+	if len(refs) > 0 {
+		buff.WriteString(".SH SEE ALSO\n")
+		for i, ref := range refs {
+			refs[i] = fmt.Sprintf("\\fB%s\\fR(%d)", roffEscape(ref), section)
+		}
+		fmt.Fprintf(buff, "%s\n", strings.Join(refs, ", "))
+	}
+	_, err = w.Write(buff.Bytes())
+	return err
+}
+
+var roffEscaper = strings.NewReplacer("\\", "\\e", "-", "\\-")
+
+// roffEscape escapes backslashes and dashes of s
+func roffEscape(s string) string {
+	return roffEscaper.Replace(s)
+}
+
+// roffText escapes s as text lines, lines led by a control character are
+// protected by `\&`
+func roffText(s string) string {
+	lines := strings.Split(roffEscape(s), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = "\\&" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
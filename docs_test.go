@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDocsTestApp() *Command {
+	type rootT struct {
+		Helper
+		Format string `cli:"f,format" usage:"output format | json or yaml" dft:"json"`
+		Debug  bool   `cli:"debug" usage:"debug mode" hidden:"true"`
+	}
+	type serveT struct {
+		Port  uint16   `cli:"p,port" usage:"listening port" dft:"8080"`
+		Token string   `cli:"token" usage:"access token" dft:"abc" secret:"true"`
+		Tags  []string `cli:"tag" usage:"tags of service"`
+	}
+	type statusT struct {
+		Name string `cli:"*n,name" usage:"name of service"`
+	}
+	return Root(&Command{
+		Name:   "app",
+		Desc:   "markdown doc test app",
+		Argv:   func() interface{} { return new(rootT) },
+		Global: true,
+		Fn:     donothing,
+	},
+		Tree(&Command{
+			Name: "serve",
+			Desc: "start service",
+			Text: "Start service in foreground.",
+			Argv: func() interface{} { return new(serveT) },
+			Examples: []Example{
+				{Desc: "listen on 80", Command: "./app serve -p 80"},
+			},
+			Fn: donothing,
+		},
+			Tree(&Command{
+				Name: "status",
+				Desc: "show status of service",
+				Argv: func() interface{} { return new(statusT) },
+				Fn:   donothing,
+			}),
+		),
+		Tree(&Command{
+			Name:   "internal",
+			Hidden: true,
+			Fn:     donothing,
+		}),
+	)
+}
+
+func TestGenMarkdownDoc(t *testing.T) {
+	var w bytes.Buffer
+	require.Nil(t, newDocsTestApp().GenMarkdownDoc(&w))
+	want, err := ioutil.ReadFile("testdata/markdown_doc.golden")
+	require.Nil(t, err)
+	assert.Equal(t, string(want), w.String())
+
+	// defaults shown in column Default only
+	UsageDefaultInDesc = true
+	defer func() { UsageDefaultInDesc = false }()
+	w.Reset()
+	require.Nil(t, newDocsTestApp().GenMarkdownDoc(&w))
+	assert.Equal(t, string(want), w.String())
+}
+
+func TestGenManPage(t *testing.T) {
+	serve, ok := newDocsTestApp().Find("serve")
+	require.True(t, ok)
+	var w bytes.Buffer
+	require.Nil(t, serve.GenManPage(1, &w))
+	want, err := ioutil.ReadFile("testdata/man_page.golden")
+	require.Nil(t, err)
+	assert.Equal(t, string(want), w.String())
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// LoadEnvFile reads `KEY=VALUE` lines of file as environment variables of
+// flags which have tag `env`. Blank lines and lines starting with `#` are
+// ignored, values could be quoted, e.g.
+//
+//	# comment
+//	export APP_HOST=example.com
+//	APP_NAME="hello\tworld" # escaped characters allowed in double quotes
+//	APP_PASSWORD='p@ss#word'
+//
+// Values of env file override default values, but flags set from command line
+// or environment variables keep their values, i.e.
+// command line > environment variables > env file > `dft`.
+//
+// Values are set like environment variables and checked like values from
+// command line. Call it in Command.LoadDefaults so that values are loaded
+// before flags checked, and required flags could be satisfied by env file.
+func (ctx *Context) LoadEnvFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	entries, line, err := parseEnvFile(file)
+	if err != nil {
+		return envFileError{filename: filename, line: line, err: err}
+	}
+	if ctx.flagSet == nil {
+		return nil
+	}
+	for _, fl := range ctx.flagSet.flagSlice {
+		if fl.isSet || fl.envName != "" {
+			continue
+		}
+		for _, name := range fl.tag.envs {
+			entry, ok := entries[name]
+			if !ok {
+				continue
+			}
+			if fl.isSlice() || fl.isMap() {
+				// value replaces default value
+				fl.value.Set(reflect.Zero(fl.value.Type()))
+			}
+			err := fl.setEnv(name, entry.value, ctx.color)
+			if err == nil && fl.isNeedDelaySet {
+				// delayed values have been set after parsing
+				if err = setWithProperType(fl, fl.field.Type, fl.value, fl.lastValue, ctx.color, false); err != nil {
+					err = fl.envError(err, ctx.color)
+				}
+			}
+			if err != nil {
+				return envFileError{filename: filename, line: entry.line, err: err}
+			}
+			fl.isLoaded = ctx.flagSet.loadingDefaults
+			break
+		}
+	}
+	return ctx.checkValues()
+}
+
+type envFileEntry struct {
+	value string
+	line  int
+}
+
+// parseEnvFile parses `KEY=VALUE` lines of r, the last value wins for
+// duplicate keys. Line number returned with error.
+func parseEnvFile(r io.Reader) (map[string]envFileEntry, int, error) {
+	entries := map[string]envFileEntry{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		index := strings.Index(line, "=")
+		if index < 0 {
+			return nil, n, fmt.Errorf("missing `=' in `%s'", line)
+		}
+		key := strings.TrimSpace(line[:index])
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, n, fmt.Errorf("invalid key `%s'", key)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(line[index+1:]))
+		if err != nil {
+			return nil, n, err
+		}
+		entries[key] = envFileEntry{value: value, line: n}
+	}
+	return entries, 0, scanner.Err()
+}
+
+var errUnterminatedQuote = errors.New("unterminated quoted value")
+
+// parseEnvValue unquotes s. Escaped characters are allowed in double quotes,
+// and unquoted value ends before inline comment ` #`
+func parseEnvValue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	var (
+		value string
+		rest  string
+	)
+	switch quote := s[0]; quote {
+	case '\'':
+		end := strings.IndexByte(s[1:], quote)
+		if end < 0 {
+			return "", errUnterminatedQuote
+		}
+		value, rest = s[1:end+1], s[end+2:]
+	case '"':
+		buf := make([]byte, 0, len(s))
+		i := 1
+		for ; i < len(s) && s[i] != quote; i++ {
+			if s[i] != '\\' || i+1 == len(s) {
+				buf = append(buf, s[i])
+				continue
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				buf = append(buf, '\n')
+			case 't':
+				buf = append(buf, '\t')
+			case 'r':
+				buf = append(buf, '\r')
+			case '"', '\\', '$', '`':
+				buf = append(buf, s[i])
+			default:
+				buf = append(buf, '\\', s[i])
+			}
+		}
+		if i == len(s) {
+			return "", errUnterminatedQuote
+		}
+		value, rest = string(buf), s[i+1:]
+	default:
+		if index := strings.Index(s, " #"); index >= 0 {
+			s = s[:index]
+		}
+		return strings.TrimSpace(s), nil
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected `%s' after quoted value", rest)
+	}
+	return value, nil
+}
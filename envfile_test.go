@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	entries, _, err := parseEnvFile(strings.NewReader(`
+# comment
+A=1
+  export B = two words  # inline comment
+C="x\ty\n\"z\" \\ \q" # double quoted
+D='p@ss#word \n'
+E=
+F=a#b
+A=3
+`))
+	assert.Nil(t, err)
+	want := map[string]string{
+		"A": "3",
+		"B": "two words",
+		"C": "x\ty\n\"z\" \\ \\q",
+		"D": `p@ss#word \n`,
+		"E": "",
+		"F": "a#b",
+	}
+	got := map[string]string{}
+	for key, entry := range entries {
+		got[key] = entry.value
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, 9, entries["A"].line)
+
+	for i, tt := range []struct {
+		content string
+		line    int
+		err     string
+	}{
+		{"A=1\nB", 2, "missing `=' in `B'"},
+		{"=1", 1, "invalid key `'"},
+		{"A B=1", 1, "invalid key `A B'"},
+		{`A="x`, 1, "unterminated quoted value"},
+		{`A='x`, 1, "unterminated quoted value"},
+		{`A="x" y`, 1, "unexpected `y' after quoted value"},
+	} {
+		_, line, err := parseEnvFile(strings.NewReader(tt.content))
+		if assert.Error(t, err, "case %d", i) {
+			assert.Equal(t, tt.err, err.Error(), "case %d", i)
+			assert.Equal(t, tt.line, line, "case %d", i)
+		}
+	}
+}
+
+func TestContextLoadEnvFile(t *testing.T) {
+	type argT struct {
+		Host string   `cli:"host" env:"APP_HOST" dft:"localhost"`
+		Port int      `cli:"port" env:"APP_PORT,PORT" dft:"80"`
+		Name string   `cli:"name" env:"APP_NAME"`
+		Tags []string `cli:"tag" env:"APP_TAGS" sep:"," dft:"x"`
+		User string   `cli:"user"`
+	}
+	dir, err := ioutil.TempDir("", "cli")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, ".env")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("# defaults\nAPP_HOST=example.com\nPORT=8080\nAPP_NAME=\"hello world\"\nAPP_TAGS=a,b\nUSER=root\n"), 0644))
+
+	os.Setenv("APP_NAME", "from-env")
+	defer os.Unsetenv("APP_NAME")
+
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want argT
+	}{
+		{[]string{}, argT{Host: "example.com", Port: 8080, Name: "from-env", Tags: []string{"a", "b"}}},
+		{[]string{"--port=9090", "--tag=c"}, argT{Host: "example.com", Port: 9090, Name: "from-env", Tags: []string{"c"}}},
+		{[]string{"--host=localhost", "--name=x"}, argT{Host: "localhost", Port: 8080, Name: "x", Tags: []string{"a", "b"}}},
+	} {
+		argv := new(argT)
+		ctx, err := newContext("", nil, tt.args, []interface{}{argv}, clr)
+		assert.Nil(t, err, "case %d", i)
+		assert.Nil(t, ctx.LoadEnvFile(filename), "case %d", i)
+		assert.Equal(t, tt.want, *argv, "case %d", i)
+	}
+
+	ctx, _ := newContext("", nil, []string{}, []interface{}{new(argT)}, clr)
+	assert.True(t, os.IsNotExist(ctx.LoadEnvFile(filepath.Join(dir, "not-found"))))
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("APP_HOST=x\nAPP_PORT=abc\n"), 0644))
+	err = ctx.LoadEnvFile(filename)
+	if assert.Error(t, err) {
+		assert.Equal(t, "malformed env file "+filename+":2: environment variable APP_PORT invalid: `abc' couldn't converted to an int", err.Error())
+	}
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("APP_HOST='x\n"), 0644))
+	err = ctx.LoadEnvFile(filename)
+	if assert.Error(t, err) {
+		assert.Equal(t, "malformed env file "+filename+":1: unterminated quoted value", err.Error())
+	}
+}
+
+func TestCommandLoadDefaultsEnvFile(t *testing.T) {
+	type argT struct {
+		Host string `cli:"*host" env:"ZZ_ENVFILE_HOST"`
+		Port int    `cli:"port" env:"ZZ_ENVFILE_PORT" dft:"80" min:"1"`
+		Mode string `cli:"mode" env:"ZZ_ENVFILE_MODE" choices:"prod|dev"`
+	}
+	dir, err := ioutil.TempDir("", "cli")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, ".env")
+
+	var got argT
+	app := &Command{
+		Argv: func() interface{} { return new(argT) },
+		LoadDefaults: func(ctx *Context) error {
+			return ctx.LoadEnvFile(filename)
+		},
+		Fn: func(ctx *Context) error {
+			got = *ctx.Argv().(*argT)
+			return nil
+		},
+	}
+	for i, tt := range []struct {
+		content string
+		args    []string
+		want    argT
+		errMsg  string
+	}{
+		// required flag satisfied by env file
+		{"ZZ_ENVFILE_HOST=h\nZZ_ENVFILE_PORT=8080\n", nil, argT{Host: "h", Port: 8080}, ""},
+		{"ZZ_ENVFILE_HOST=h\n", []string{"--host=x", "--port=1"}, argT{Host: "x", Port: 1}, ""},
+		// values of env file checked
+		{"ZZ_ENVFILE_HOST=h\nZZ_ENVFILE_MODE=test\n", nil, argT{}, "parameter --mode invalid: `test' is not one of prod|dev"},
+		{"ZZ_ENVFILE_HOST=h\nZZ_ENVFILE_PORT=0\n", nil, argT{}, "parameter --port invalid: `0' should be at least 1"},
+		{"ZZ_ENVFILE_MODE=dev\n", nil, argT{}, "required parameter --host missing"},
+	} {
+		assert.Nil(t, ioutil.WriteFile(filename, []byte(tt.content), 0644))
+		got = argT{}
+		err := app.RunWithOptions(tt.args, WithStdout(ioutil.Discard), WithStderr(ioutil.Discard))
+		if tt.errMsg != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Contains(t, err.Error(), tt.errMsg, "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, got, "case %d", i)
+		}
+	}
+}
@@ -13,11 +13,75 @@ var (
 	errNotAPointerToStruct = errors.New("not a pointer to struct")
 	errNotAPointer         = errors.New("argv is not a pointer")
 	errCliTagTooMany       = errors.New("cli tag too many")
+	errYAMLMarshalerNotSet = errors.New("YAMLMarshaler not set")
+	errTOMLEncoderNotSet   = errors.New("NewTOMLEncoder not set")
+	errRequiredWithDefault = errors.New("required flag should not have a default value")
+	errHTTPRequestNotSet   = errors.New("HTTPRequest not set")
+	errNotInteractive      = errors.New("refuse to confirm a destructive action from non-interactive input, use --yes to skip confirmation")
+	errStdinNotAvailable   = errors.New("stdin not available")
 )
 
 type (
+	// MissingRequiredError represents an error which occurs while required flags missing
+	MissingRequiredError struct {
+		Flags []string // names of missing flags
+
+		clr color.Color
+	}
+
+	// MutexFlagsError represents an error which occurs while more than one
+	// flags of a mutually exclusive group set, or none of them set if required
+	MutexFlagsError struct {
+		Group   string   // name of group
+		Flags   []string // names of flags set, or all flags of group if missing
+		Missing bool     // whether none of flags set
+
+		clr color.Color
+	}
+
+	// UnknownFlagError represents an error which occurs while flag undefined
+	UnknownFlagError struct {
+		Flag string // the unknown flag, e.g. `--abc`
+
+		clr color.Color
+	}
+
+	// MissingValueError represents an error which occurs while value of flag missing
+	MissingValueError struct {
+		Flag string // the flag missing value
+
+		clr color.Color
+	}
+
+	// TypeConversionError represents an error which occurs while raw value
+	// couldn't converted to type of flag
+	TypeConversionError struct {
+		Flag  string // the flag
+		Value string // raw value of flag
+		Err   error  // underlying error
+
+		clr color.Color
+	}
+
+	// PanicError represents a panic recovered while running command, the
+	// message has been written to stderr, so it's message is empty
+	PanicError struct {
+		Value interface{} // value passed to panic
+		Stack []byte      // stack trace of the panicking goroutine
+	}
+
+	// ExitCoder is an error which carries exit code of program
+	ExitCoder interface {
+		ExitCode() int
+	}
+
 	exitError struct{}
 
+	exitCodeError struct {
+		code int
+		msg  string
+	}
+
 	commandNotFoundError struct {
 		command string
 	}
@@ -42,13 +106,136 @@ type (
 		ith int
 		msg string
 	}
+
+	argIndexError struct {
+		index int
+		size  int
+	}
+
+	argError struct {
+		index int
+		err   error
+	}
+
+	argCountError struct {
+		min, max int
+		got      int
+	}
+
+	bodyTooLargeError struct {
+		limit int64
+	}
+
+	jsonBodyError struct {
+		err error
+	}
+
+	stdinJSONError struct {
+		err error
+	}
+
+	configFileError struct {
+		filename string
+		err      error
+	}
+
+	envFileError struct {
+		filename string
+		line     int
+		err      error
+	}
+
+	multiError struct {
+		errs []error
+	}
+
+	confirmAnswerError struct {
+		answer string
+	}
+
+	outputFormatError struct {
+		format string
+	}
 )
 
+func (e MissingRequiredError) Error() string {
+	buff := bytes.NewBufferString("")
+	for i, name := range e.Flags {
+		if i != 0 {
+			buff.WriteByte('\n')
+		}
+		fmt.Fprintf(buff, "required parameter %s missing", e.clr.Bold(name))
+	}
+	return buff.String()
+}
+
+func (e MutexFlagsError) Error() string {
+	names := make([]string, 0, len(e.Flags))
+	for _, name := range e.Flags {
+		names = append(names, e.clr.Bold(name))
+	}
+	if e.Missing {
+		return fmt.Sprintf("one of %s required", strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("parameters %s are mutually exclusive", strings.Join(names, ", "))
+}
+
+func (e UnknownFlagError) Error() string {
+	return fmt.Sprintf("undefined option %s", e.clr.Bold(e.Flag))
+}
+
+func (e MissingValueError) Error() string {
+	return fmt.Sprintf("parameter %s invalid: missing value", e.clr.Bold(e.Flag))
+}
+
+func (e TypeConversionError) Error() string {
+	return fmt.Sprintf("parameter %s invalid: %v", e.clr.Bold(e.Flag), e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e TypeConversionError) Unwrap() error { return e.Err }
+
+func (e PanicError) Error() string { return "" }
+
+// ExitCode returns 2 like go runtime exits on unrecovered panics
+func (e PanicError) ExitCode() int { return 2 }
+
+// Unwrap returns the value passed to panic if it's an error
+func (e PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
 func (e exitError) Error() string { return "exit" }
 
 // ExitError is a special error, should be ignored but return
 var ExitError = exitError{}
 
+// SkipCommand is returned by function of Command.Walk to skip descendants
+// of the command
+var SkipCommand = errors.New("skip this command")
+
+func (e exitCodeError) Error() string { return e.msg }
+func (e exitCodeError) ExitCode() int { return e.code }
+
+// NewExitError returns an error with exit code
+func NewExitError(code int, msg string) error {
+	return exitCodeError{code: code, msg: msg}
+}
+
+// ExitCodeOf returns exit code of err: 0 if err is nil,
+// code of ExitCoder if err(or any wrapped error) is an ExitCoder, 1 otherwise
+func ExitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return 1
+}
+
 func throwCommandNotFound(command string) commandNotFoundError {
 	return commandNotFoundError{command: command}
 }
@@ -73,10 +260,33 @@ func (e routerRepeatError) Error() string {
 	return fmt.Sprintf("router %s repeat", e.router)
 }
 
+func (e argCountError) Error() string {
+	switch {
+	case e.min == e.max:
+		return fmt.Sprintf("expected %s, got %d", pluralArgs(e.min), e.got)
+	case e.max <= 0:
+		return fmt.Sprintf("expected at least %s, got %d", pluralArgs(e.min), e.got)
+	case e.min <= 0:
+		return fmt.Sprintf("expected at most %s, got %d", pluralArgs(e.max), e.got)
+	}
+	return fmt.Sprintf("expected %d to %d args, got %d", e.min, e.max, e.got)
+}
+
+// pluralArgs formats n args, e.g. "1 arg", "2 args"
+func pluralArgs(n int) string {
+	if n == 1 {
+		return "1 arg"
+	}
+	return fmt.Sprintf("%d args", n)
+}
+
 func (e wrapError) Error() string {
 	return e.msg
 }
 
+// Unwrap returns the native error
+func (e wrapError) Unwrap() error { return e.err }
+
 func wrapErr(err error, appendString string, clr color.Color) error {
 	if err == nil {
 		return err
@@ -104,3 +314,77 @@ func (e argvError) Error() string {
 	}
 	return fmt.Sprintf("%dth argv: %s", e.ith, e.msg)
 }
+
+func (e argIndexError) Error() string {
+	return fmt.Sprintf("arg index %d out of range [0,%d)", e.index, e.size)
+}
+
+func (e argError) Error() string {
+	return fmt.Sprintf("%dth arg invalid: %v", e.index, e.err)
+}
+
+func (e bodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body too large: more than %d bytes", e.limit)
+}
+
+func (e jsonBodyError) Error() string {
+	return fmt.Sprintf("malformed JSON body: %v", e.err)
+}
+
+func (e jsonBodyError) Unwrap() error { return e.err }
+
+func (e stdinJSONError) Error() string {
+	return fmt.Sprintf("malformed JSON from stdin: %v", e.err)
+}
+
+func (e stdinJSONError) Unwrap() error { return e.err }
+
+func (e configFileError) Error() string {
+	return fmt.Sprintf("malformed config file %s: %v", e.filename, e.err)
+}
+
+func (e configFileError) Unwrap() error { return e.err }
+
+func (e envFileError) Error() string {
+	if e.line > 0 {
+		return fmt.Sprintf("malformed env file %s:%d: %v", e.filename, e.line, e.err)
+	}
+	return fmt.Sprintf("malformed env file %s: %v", e.filename, e.err)
+}
+
+func (e envFileError) Unwrap() error { return e.err }
+
+func (e multiError) Error() string {
+	lines := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		lines = append(lines, "- "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CombineErrors combines errs as an error formatted as a bullet list, each
+// error is a line like "- name is required". nil errors are ignored, nil
+// returned if no error, and the error returned as it is if only one error.
+func CombineErrors(errs []error) error {
+	var nonNil []error
+	for _, err := range errs {
+		if err != nil {
+			nonNil = append(nonNil, err)
+		}
+	}
+	switch len(nonNil) {
+	case 0:
+		return nil
+	case 1:
+		return nonNil[0]
+	}
+	return multiError{errs: nonNil}
+}
+
+func (e confirmAnswerError) Error() string {
+	return fmt.Sprintf("`%s' isn't an answer of yes or no", e.answer)
+}
+
+func (e outputFormatError) Error() string {
+	return fmt.Sprintf("unknown output format `%s', supported: %s", e.format, strings.Join(outputFormats, "|"))
+}
@@ -282,6 +282,7 @@ func ExampleNumArgFunc_exactN() {
 			Argv:    func() interface{} { return new(argT) },
 			NumArg:  cli.ExactN(1),
 			UsageFn: func() string { return "usage function" },
+			Stderr:  os.Stdout,
 			Fn: func(ctx *cli.Context) error {
 				return nil
 			},
@@ -309,6 +310,7 @@ func ExampleNumArgFunc_atLeast() {
 			Argv:    func() interface{} { return new(argT) },
 			NumArg:  cli.AtLeast(1),
 			UsageFn: func() string { return "usage function" },
+			Stderr:  os.Stdout,
 			Fn: func(ctx *cli.Context) error {
 				return nil
 			},
@@ -336,6 +338,7 @@ func ExampleNumArgFunc_atMost() {
 			Argv:    func() interface{} { return new(argT) },
 			NumArg:  cli.AtMost(2),
 			UsageFn: func() string { return "usage function" },
+			Stderr:  os.Stdout,
 			Fn: func(ctx *cli.Context) error {
 				return nil
 			},
@@ -365,6 +368,7 @@ func ExampleNumOptionFunc_exactN() {
 			Argv:      func() interface{} { return new(argT) },
 			NumOption: cli.ExactN(1),
 			UsageFn:   func() string { return "usage function" },
+			Stderr:    os.Stdout,
 			Fn: func(ctx *cli.Context) error {
 				return nil
 			},
@@ -390,6 +394,7 @@ func ExampleNumOptionFunc_atLeast() {
 			Argv:      func() interface{} { return new(argT) },
 			NumOption: cli.AtLeast(1),
 			UsageFn:   func() string { return "usage function" },
+			Stderr:    os.Stdout,
 			Fn: func(ctx *cli.Context) error {
 				return nil
 			},
@@ -417,6 +422,7 @@ func ExampleNumOptionFunc_atMost() {
 			Argv:      func() interface{} { return new(argT) },
 			NumOption: cli.AtMost(2),
 			UsageFn:   func() string { return "usage function" },
+			Stderr:    os.Stdout,
 			Fn: func(ctx *cli.Context) error {
 				return nil
 			},
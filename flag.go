@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -26,6 +29,10 @@ type flag struct {
 	// isSet indicates whether the flag is set
 	isSet bool
 
+	// isLoaded indicates whether the flag is set by Command.LoadDefaults,
+	// such values are exclusive like values set in mutex groups
+	isLoaded bool
+
 	// tag properties
 	tag tagProperty
 
@@ -39,6 +46,17 @@ type flag struct {
 	//	-f xx -f yy -f zz
 	// `zz` is the last value
 	lastValue string
+
+	// envName is the environment variable which the value read from
+	envName string
+
+	// readValues are raw values read from files of `@`, keyed by filename,
+	// and from prompt or editor, keyed by empty string. They're reused when
+	// parsed again, see Context.SetArgv
+	readValues map[string]string
+
+	// stdin returns reader of `@-`, see flagSet.stdin
+	stdin func() io.Reader
 }
 
 func newFlag(field reflect.StructField, value reflect.Value, tag *tagProperty, clr color.Color, dontSetValue bool) (fl *flag, err error) {
@@ -55,7 +73,17 @@ func newFlag(field reflect.StructField, value reflect.Value, tag *tagProperty, c
 		isSliceDecoder = fl.value.Addr().Type().Implements(reflect.TypeOf((*SliceDecoder)(nil)).Elem())
 	}
 	fl.isNeedDelaySet = fl.tag.parserCreator != nil ||
-		(fl.field.Type.Kind() != reflect.Slice && fl.field.Type.Kind() != reflect.Map && !isSliceDecoder)
+		(fl.field.Type.Kind() != reflect.Slice && fl.field.Type.Kind() != reflect.Map && !isSliceDecoder && !fl.tag.isCount)
+	if fl.tag.isFromFile && fl.field.Type.Kind() != reflect.String &&
+		!(fl.isSlice() && fl.field.Type.Elem().Kind() == reflect.String) {
+		return nil, fmt.Errorf("field %s: fromfile flag should be a string or string slice", clr.Bold(fl.field.Name))
+	}
+	if fl.tag.isCount && !fl.isInteger() {
+		return nil, fmt.Errorf("field %s: count flag should be an integer", clr.Bold(fl.field.Name))
+	}
+	if (fl.tag.min != nil || fl.tag.max != nil) && !isNumberKind(fl.elemKind()) {
+		return nil, fmt.Errorf("field %s: min/max only used for number flag", clr.Bold(fl.field.Name))
+	}
 	err = fl.init(clr, dontSetValue)
 	return
 }
@@ -84,7 +112,14 @@ func (fl *flag) init(clr color.Color, dontSetValue bool) error {
 			}
 		}
 	}
-	if !dontSetValue && fl.tag.dft != "" && dft != "" {
+	if dontSetValue {
+		return nil
+	}
+	// environment variable takes precedence over default value
+	if name, value, ok := fl.lookupEnv(); ok {
+		return fl.setEnv(name, value, clr)
+	}
+	if fl.tag.dft != "" && dft != "" {
 		if fl.isPtr() || isDecoder || isEmpty(fl.value) {
 			return fl.setDefault(dft, clr)
 		}
@@ -92,6 +127,29 @@ func (fl *flag) init(clr color.Color, dontSetValue bool) error {
 	return nil
 }
 
+// setEnv sets value of environment variable name as default value
+func (fl *flag) setEnv(name, value string, clr color.Color) error {
+	fl.envName = name
+	if err := fl.setDefault(value, clr); err != nil {
+		return fl.envError(err, clr)
+	}
+	return nil
+}
+
+func (fl *flag) envError(err error, clr color.Color) error {
+	return fmt.Errorf("environment variable %s invalid: %v", clr.Bold(fl.envName), err)
+}
+
+// lookupEnv returns the first non-empty environment variable of `env` tag
+func (fl *flag) lookupEnv() (name, value string, ok bool) {
+	for _, name = range fl.tag.envs {
+		if value = os.Getenv(name); value != "" {
+			return name, value, true
+		}
+	}
+	return "", "", false
+}
+
 func isEmpty(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -208,9 +266,156 @@ func (fl *flag) name() string {
 	if len(fl.tag.shortNames) > 0 {
 		return fl.tag.shortNames[0]
 	}
+	if fl.tag.position != nil {
+		return fl.positionalName()
+	}
 	return ""
 }
 
+// positionalName returns name of positional like `<src-file>`, which is
+// tag `name`, or name of field in kebab case
+func (fl *flag) positionalName() string {
+	name := fl.tag.name
+	if name == "" {
+		name = splitWords(fl.field.Name, '-')
+	}
+	return "<" + name + ">"
+}
+
+// isSecret reports whether value of flag should be masked while rendered
+func (fl *flag) isSecret() bool {
+	return fl.tag.isSecret || fl.tag.isPassword
+}
+
+// displayDefault returns default value shown after names of flag in usage,
+// masked if secret, and empty if UsageDefaultInDesc
+func (fl *flag) displayDefault() string {
+	if UsageDefaultInDesc {
+		return ""
+	}
+	if fl.tag.dft != "" && fl.isSecret() {
+		return secretMask
+	}
+	return fl.tag.dft
+}
+
+// displayValue returns value of flag for rendering, masked if secret
+func (fl *flag) displayValue() string {
+	if fl.isSecret() {
+		return secretMask
+	}
+	return fl.valueString()
+}
+
+// valueString formats value of flag by Encoder or fmt
+func (fl *flag) valueString() string {
+	intf := fl.value.Interface()
+	if encoder, ok := intf.(Encoder); ok {
+		return encoder.Encode()
+	}
+	return fmt.Sprintf("%v", intf)
+}
+
+// UsageDefaultInDesc makes usage show default values of flags at the end of
+// descriptions like `(default: 8080)` instead of `[=8080]` after names of
+// flags. Default values are evaluated, e.g. `dft:"$HOME"` shows value of HOME.
+// It's false by default, so usage of existing commands is unchanged.
+var UsageDefaultInDesc = false
+
+// usage returns usage of flag, deprecated flag marked, and default value
+// appended if UsageDefaultInDesc
+func (fl *flag) usage() string {
+	return fl.usageWith(UsageDefaultInDesc)
+}
+
+// usageWith returns usage of flag, deprecated flag marked, and default value
+// appended if withDefault
+func (fl *flag) usageWith(withDefault bool) string {
+	var notes []string
+	if fl.tag.deprecated != "" {
+		notes = append(notes, "deprecated: "+fl.tag.deprecated)
+	}
+	if withDefault {
+		if dft := fl.usageDefault(); dft != "" {
+			notes = append(notes, "default: "+dft)
+		}
+	}
+	if len(notes) == 0 {
+		return fl.tag.usage
+	}
+	var (
+		body  = strings.TrimRight(fl.tag.usage, "\n")
+		trail = fl.tag.usage[len(body):]
+	)
+	for _, note := range notes {
+		if body != "" {
+			body += " "
+		}
+		body += "(" + note + ")"
+	}
+	return body + trail
+}
+
+// usageDefault returns evaluated default value of flag for usage, raw `dft`
+// returned if evaluation failed
+func (fl *flag) usageDefault() string {
+	if fl.tag.dft == "" {
+		return ""
+	}
+	if fl.isSecret() {
+		return secretMask
+	}
+	val, err := defaultValueOf(fl.field, &fl.tag)
+	if err != nil {
+		return fl.tag.dft
+	}
+	return formatDefault(val)
+}
+
+// defaultValueOf evaluates default value of field by tag `dft`, environment
+// variables of tag `env` ignored
+func defaultValueOf(field reflect.StructField, tag *tagProperty) (reflect.Value, error) {
+	t := *tag
+	t.envs = nil
+	val := reflect.New(field.Type).Elem()
+	fl, err := newFlag(field, val, &t, color.Color{}, false)
+	if err != nil {
+		return val, err
+	}
+	if fl != nil && fl.isNeedDelaySet && fl.lastValue != "" {
+		err = setWithProperType(fl, field.Type, val, fl.lastValue, color.Color{}, false)
+	}
+	return val, err
+}
+
+// formatDefault formats val compactly, elements of slice and pairs of map
+// are comma-separated
+func formatDefault(val reflect.Value) string {
+	val = indirectValue(val)
+	if !val.IsValid() {
+		return ""
+	}
+	if encoder, ok := val.Interface().(Encoder); ok {
+		return encoder.Encode()
+	}
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		elems := make([]string, 0, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			elems = append(elems, formatDefault(val.Index(i)))
+		}
+		return strings.Join(elems, pairSep)
+	case reflect.Map:
+		pairs := make([]string, 0, val.Len())
+		for _, key := range val.MapKeys() {
+			pairs = append(pairs, formatDefault(key)+defaultSepForKeyValueOfMap+formatDefault(val.MapIndex(key)))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, pairSep)
+	}
+	return fmt.Sprint(val.Interface())
+}
+
 func (fl *flag) isBoolean() bool {
 	return fl.field.Type.Kind() == reflect.Bool
 }
@@ -253,6 +458,136 @@ func (fl *flag) isPtr() bool {
 	return fl.field.Type.Kind() == reflect.Ptr
 }
 
+// isSignedNumber indicates whether the flag(or element of slice flag) is a signed number
+func (fl *flag) isSignedNumber() bool {
+	typ := fl.field.Type
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64:
+		return tryGetDecoder(fl.value.Type().Kind(), fl.value) == nil
+	}
+	return false
+}
+
+// isValueRequired indicates whether the flag must be followed by a value.
+// Boolean, counter, parser and decoder flags accept an empty value,
+// e.g. a decoder may read from stdin if value is empty.
+func (fl *flag) isValueRequired() bool {
+	if fl.isBoolean() || fl.isCounter() || fl.tag.parserCreator != nil {
+		return false
+	}
+	return tryGetDecoder(fl.value.Type().Kind(), fl.value) == nil
+}
+
+// checkChoices checks whether value(or each element of slice) of flag is one of choices
+func (fl *flag) checkChoices(clr color.Color) error {
+	check := func(v reflect.Value) error {
+		s := fmt.Sprintf("%v", v.Interface())
+		for _, choice := range fl.tag.choices {
+			if s == choice || (fl.tag.isChoicesCI && strings.EqualFold(s, choice)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("parameter %s invalid: `%s' is not one of %s",
+			clr.Bold(fl.name()), s, strings.Join(fl.tag.choices, "|"))
+	}
+	val := reflect.Indirect(fl.value)
+	if val.Kind() == reflect.Slice {
+		for i := 0; i < val.Len(); i++ {
+			if err := check(val.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(val)
+}
+
+// checkValidator validates value(or each element of slice) of flag by validator
+func (fl *flag) checkValidator(clr color.Color) error {
+	check := func(v reflect.Value) error {
+		if err := fl.tag.validator(fl.name(), fmt.Sprintf("%v", v.Interface())); err != nil {
+			return fmt.Errorf("parameter %s invalid: %v", clr.Bold(fl.name()), err)
+		}
+		return nil
+	}
+	val := reflect.Indirect(fl.value)
+	if val.Kind() == reflect.Slice {
+		for i := 0; i < val.Len(); i++ {
+			if err := check(val.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(val)
+}
+
+// checkRange checks whether value(or each element of slice) of flag in range [min, max]
+func (fl *flag) checkRange(clr color.Color) error {
+	check := func(v reflect.Value) error {
+		var f float64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(v.Uint())
+		default:
+			f = v.Float()
+		}
+		min, max := fl.tag.min, fl.tag.max
+		if (min == nil || f >= *min) && (max == nil || f <= *max) {
+			return nil
+		}
+		var want string
+		switch {
+		case min == nil:
+			want = fmt.Sprintf("at most %v", *max)
+		case max == nil:
+			want = fmt.Sprintf("at least %v", *min)
+		default:
+			want = fmt.Sprintf("in range [%v, %v]", *min, *max)
+		}
+		return fmt.Errorf("parameter %s invalid: `%v' should be %s", clr.Bold(fl.name()), v.Interface(), want)
+	}
+	val := reflect.Indirect(fl.value)
+	if val.Kind() == reflect.Slice {
+		for i := 0; i < val.Len(); i++ {
+			if err := check(val.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(val)
+}
+
+// elemKind returns kind of flag, or kind of element if flag is a slice
+func (fl *flag) elemKind() reflect.Kind {
+	if fl.isSlice() {
+		return fl.field.Type.Elem().Kind()
+	}
+	return fl.field.Type.Kind()
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
 func (fl *flag) getBool() bool {
 	if !fl.isBoolean() {
 		return false
@@ -262,29 +597,135 @@ func (fl *flag) getBool() bool {
 
 func (fl *flag) setDefault(s string, clr color.Color) error {
 	fl.isAssigned = true
-	if fl.isNeedDelaySet {
-		fl.lastValue = s
-		return nil
-	}
-	return setWithProperType(fl, fl.field.Type, fl.value, s, clr, false)
+	return fl.setValue(s, true, clr)
 }
 
+// set sets value from command line. The first occurrence of a slice flag
+// replaces its default value, later occurrences append to it, e.g.
+//
+//	Tags []string `cli:"tag" dft:"x"`
+//	// (none)         => [x]
+//	// --tag=a        => [a]
+//	// --tag=a -tag=b => [a b]
 func (fl *flag) set(actualFlagName, s string, clr color.Color) error {
+	if fl.isSlice() && !fl.isSet {
+		fl.value.Set(reflect.Zero(fl.value.Type()))
+	}
 	fl.isSet = true
 	fl.isAssigned = true
 	fl.actualFlagName = actualFlagName
-	if fl.isNeedDelaySet {
+	if fl.tag.isFromFile && strings.HasPrefix(s, fromFilePrefix) {
+		filename := strings.TrimPrefix(s, fromFilePrefix)
+		data, ok := fl.readValues[filename]
+		if !ok {
+			var err error
+			if data, err = readFromFile(filename, fl.stdinReader()); err != nil {
+				return err
+			}
+			fl.keepReadValue(filename, data)
+		}
+		s = data
+	}
+	return fl.setValue(s, true, clr)
+}
+
+// keepReadValue keeps raw value read from file, prompt or editor by key
+func (fl *flag) keepReadValue(key, s string) {
+	if fl.readValues == nil {
+		fl.readValues = make(map[string]string)
+	}
+	fl.readValues[key] = s
+}
+
+// setValue normalizes raw value s and sets it, or keeps it to be set after
+// parsing if delay specified and flag needs delay. Values of all sources are
+// set by it, so that they're normalized exactly once.
+func (fl *flag) setValue(s string, delay bool, clr color.Color) error {
+	s = fl.normalize(s)
+	if delay && fl.isNeedDelaySet {
 		fl.lastValue = s
 		return nil
 	}
 	return setWithProperType(fl, fl.field.Type, fl.value, s, clr, false)
 }
 
-func (fl *flag) counterIncr(s string, clr color.Color) error {
-	return setWithProperType(fl, fl.field.Type, fl.value, s, clr, false)
+// normalize normalizes raw value s by normalizers of tag `normalize` in order
+func (fl *flag) normalize(s string) string {
+	for _, normalize := range fl.tag.normalizers {
+		s = normalize(s)
+	}
+	return s
+}
+
+// normalizeValue normalizes value of string flag, or each element of string
+// slice, in place. It's used for values which aren't parsed from raw string,
+// e.g. unmarshaled from JSON.
+func (fl *flag) normalizeValue() {
+	if len(fl.tag.normalizers) == 0 {
+		return
+	}
+	val := reflect.Indirect(fl.value)
+	switch {
+	case val.Kind() == reflect.String:
+		val.SetString(fl.normalize(val.String()))
+	case val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < val.Len(); i++ {
+			val.Index(i).SetString(fl.normalize(val.Index(i).String()))
+		}
+	}
+}
+
+// stdinReader returns reader of `@-`, PromptReader used if stdin is nil
+func (fl *flag) stdinReader() io.Reader {
+	if fl.stdin == nil {
+		return PromptReader
+	}
+	return fl.stdin()
+}
+
+// readFromFile reads content of file, `-` means stdin
+func readFromFile(filename string, stdin io.Reader) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if filename == dashOne {
+		if stdin == nil {
+			err = errStdinNotAvailable
+		} else {
+			data, err = ioutil.ReadAll(stdin)
+		}
+	} else if filename == "" {
+		err = errors.New("missing filename after " + fromFilePrefix)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read from file %s: %v", filename, err)
+	}
+	return string(data), nil
+}
+
+func (fl *flag) counterIncr(actualFlagName string, clr color.Color) error {
+	fl.isSet = true
+	fl.isAssigned = true
+	fl.actualFlagName = actualFlagName
+	if fl.tag.isCount {
+		switch fl.field.Type.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fl.value.SetUint(fl.value.Uint() + 1)
+		default:
+			fl.value.SetInt(fl.value.Int() + 1)
+		}
+		return nil
+	}
+	return setWithProperType(fl, fl.field.Type, fl.value, "", clr, false)
 }
 
 func (fl *flag) isCounter() bool {
+	if fl.tag.isCount {
+		return true
+	}
 	if decoder := tryGetDecoder(fl.value.Type().Kind(), fl.value); decoder != nil {
 		if _, ok := decoder.(CounterDecoder); ok {
 			return true
@@ -297,7 +738,7 @@ func (fl *flag) setWithNoDelay(actualFlagName, s string, clr color.Color) error
 	fl.isSet = true
 	fl.isAssigned = true
 	fl.actualFlagName = actualFlagName
-	return setWithProperType(fl, fl.field.Type, fl.value, s, clr, false)
+	return fl.setValue(s, false, clr)
 }
 
 func tryGetDecoder(kind reflect.Kind, val reflect.Value) Decoder {
@@ -328,6 +769,12 @@ func setWithProperType(fl *flag, typ reflect.Type, val reflect.Value, s string,
 		}
 		return fl.tag.parserCreator(val.Interface()).Parse(s)
 	}
+	if parser, ok := typeParsers[typ]; ok {
+		return parser([]string{s}, val)
+	}
+	if typ == timeType {
+		return parseTime(s, fl.tag.timeFormat, val)
+	}
 
 	if decoder := tryGetDecoder(kind, val); decoder != nil {
 		return decoder.Decode(s)
@@ -386,40 +833,67 @@ func setWithProperType(fl *flag, typ reflect.Type, val reflect.Value, s string,
 			slice := reflect.MakeSlice(typ, 0, 4)
 			val.Set(slice)
 		}
-		index := val.Len()
-		sliceCap := val.Cap()
-		if index+1 <= sliceCap {
-			val.SetLen(index + 1)
-		} else {
-			slice := reflect.MakeSlice(typ, index+1, index+sliceCap/2+1)
-			for k := 0; k < index; k++ {
-				slice.Index(k).Set(val.Index(k))
+		// e.g. `--tag a,b` appends both a and b if tag `sep:","` specified,
+		// and each occurrence is an element if elements are structs
+		elems := []string{s}
+		if fl.tag.sep != "" && !isPlainStruct(sliceOf) {
+			elems = strings.Split(s, fl.tag.sep)
+		}
+		for _, elem := range elems {
+			index := val.Len()
+			sliceCap := val.Cap()
+			if index+1 <= sliceCap {
+				val.SetLen(index + 1)
+			} else {
+				slice := reflect.MakeSlice(typ, index+1, index+sliceCap/2+1)
+				for k := 0; k < index; k++ {
+					slice.Index(k).Set(val.Index(k))
+				}
+				val.Set(slice)
+			}
+			if err := setWithProperType(fl, sliceOf, val.Index(index), elem, clr, true); err != nil {
+				return err
 			}
-			val.Set(slice)
 		}
-		return setWithProperType(fl, sliceOf, val.Index(index), s, clr, true)
 
 	case reflect.Map:
 		if isSubField {
 			return fmt.Errorf("unsupported type %s as a sub field", kind.String())
 		}
-		keyString, valString, err := splitKeyVal(s, fl.tag.sep)
-		if err != nil {
-			return err
-		}
 		keyType := typ.Key()
 		valType := typ.Elem()
 		if val.IsNil() {
 			val.Set(reflect.MakeMap(typ))
 		}
-		k, v := reflect.New(keyType), reflect.New(valType)
-		if err := setWithProperType(fl, keyType, k.Elem(), keyString, clr, true); err != nil {
-			return err
+		sep := fl.tag.sep
+		if sep == "" {
+			sep = defaultSepForKeyValueOfMap
 		}
-		if err := setWithProperType(fl, valType, v.Elem(), valString, clr, true); err != nil {
-			return err
+		// e.g. `--label env=prod,team=core`, the last value wins for duplicate keys
+		for _, pair := range splitPairs(s, sep) {
+			keyString, valString, err := splitKeyVal(pair, sep)
+			if err != nil {
+				// `-Dkey` means `-Dkey=true` for map of booleans
+				if valType.Kind() != reflect.Bool || pair == "" {
+					return err
+				}
+				keyString, valString = pair, ""
+			}
+			k, v := reflect.New(keyType), reflect.New(valType)
+			if err := setWithProperType(fl, keyType, k.Elem(), keyString, clr, true); err != nil {
+				return err
+			}
+			if err := setWithProperType(fl, valType, v.Elem(), valString, clr, true); err != nil {
+				return err
+			}
+			val.SetMapIndex(k.Elem(), v.Elem())
 		}
-		val.SetMapIndex(k.Elem(), v.Elem())
+
+	case reflect.Struct:
+		if !isSubField {
+			return fmt.Errorf("unsupported type: %s", kind.String())
+		}
+		return setStructFields(fl, typ, val, s, clr)
 
 	default:
 		return fmt.Errorf("unsupported type: %s", kind.String())
@@ -427,6 +901,83 @@ func setWithProperType(fl *flag, typ reflect.Type, val reflect.Value, s string,
 	return nil
 }
 
+// isPlainStruct reports whether typ is a struct which isn't parsed by
+// type parsers or decoders
+func isPlainStruct(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return false
+	}
+	if _, ok := typeParsers[typ]; ok {
+		return false
+	}
+	decoderType := reflect.TypeOf((*Decoder)(nil)).Elem()
+	return !typ.Implements(decoderType) && !reflect.PtrTo(typ).Implements(decoderType)
+}
+
+// setStructFields sets fields of struct val by key/value pairs of s, e.g.
+// `host=a,port=1` sets fields Host and Port. Keys are names of fields
+// matched case-insensitively or names of tag `cli`, separated from values by
+// tag `sep` which is `=` by default.
+func setStructFields(fl *flag, typ reflect.Type, val reflect.Value, s string, clr color.Color) error {
+	sep := fl.tag.sep
+	if sep == "" {
+		sep = defaultSepForKeyValueOfMap
+	}
+	val.Set(reflect.Zero(typ))
+	keys := structKeys(typ)
+	for _, pair := range splitPairs(s, sep) {
+		key, valString, err := splitKeyVal(pair, sep)
+		if err != nil {
+			return err
+		}
+		index := -1
+		for i, names := range keys {
+			for _, name := range names {
+				if strings.EqualFold(name, key) {
+					index = i
+				}
+			}
+		}
+		if index < 0 {
+			var valid []string
+			for _, names := range keys {
+				for j, name := range names {
+					// prefer long names
+					if len(name) > 1 || j == len(names)-1 {
+						valid = append(valid, name)
+						break
+					}
+				}
+			}
+			return fmt.Errorf("unknown key `%s', valid keys: %s", key, strings.Join(valid, "|"))
+		}
+		field := typ.Field(index)
+		if err := setWithProperType(fl, field.Type, val.Field(index), valString, clr, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structKeys returns keys of each field of struct typ, which are names of
+// tag `cli` and lower case name of field. Keys of unexported fields are empty.
+func structKeys(typ reflect.Type) [][]string {
+	keys := make([][]string, typ.NumField())
+	for i := range keys {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		for _, name := range strings.Split(field.Tag.Get(tagCli), ",") {
+			if name = strings.Trim(strings.TrimSpace(name), "*!"); name != "" && name != dashOne {
+				keys[i] = append(keys[i], name)
+			}
+		}
+		keys[i] = append(keys[i], strings.ToLower(field.Name))
+	}
+	return keys
+}
+
 func splitKeyVal(s, sep string) (key, val string, err error) {
 	if s == "" {
 		err = fmt.Errorf("empty key,val pair")
@@ -434,9 +985,28 @@ func splitKeyVal(s, sep string) (key, val string, err error) {
 	}
 	index := strings.Index(s, sep)
 	if index == -1 {
-		return s, "", nil
+		err = fmt.Errorf("`%s' isn't a key%svalue pair", s, sep)
+		return
+	}
+	return s[:index], s[index+len(sep):], nil
+}
+
+// splitPairs splits comma-separated key/value pairs, s is a single pair
+// unless every part contains sep, e.g.
+//
+//	"a=1,b=2" => ["a=1", "b=2"]
+//	"a=1,2"   => ["a=1,2"]
+func splitPairs(s, sep string) []string {
+	pairs := strings.Split(s, pairSep)
+	if len(pairs) == 1 {
+		return pairs
+	}
+	for _, pair := range pairs {
+		if !strings.Contains(pair, sep) {
+			return []string{s}
+		}
 	}
-	return s[:index], s[index+1:], nil
+	return pairs
 }
 
 func minmaxIntCheck(kind reflect.Kind, v int64) bool {
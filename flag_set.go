@@ -1,14 +1,18 @@
 package cli
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
 	"net/url"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/Bowery/prompt"
 	"github.com/labstack/gommon/color"
+	"github.com/mattn/go-isatty"
 )
 
 type flagSet struct {
@@ -19,7 +23,39 @@ type flagSet struct {
 	flagMap   map[string]*flag
 	flagSlice []*flag
 
+	// positionals are fields assigned by free args, see tag `positional`
+	positionals []*flag
+
 	hasForce bool
+
+	// allowAbbrev indicates whether long flags matched by unique prefix
+	allowAbbrev bool
+
+	// skipUnknown indicates whether unknown flags ignored, and prompts and
+	// checks of values skipped
+	skipUnknown bool
+
+	// keepUnknown indicates whether unknown flags collected to unknownFlags
+	// instead of an error
+	keepUnknown  bool
+	unknownFlags []string
+
+	// loadDefaults loads default layers of flags before flags checked, e.g.
+	// config file, see Command.LoadDefaults
+	loadDefaults    func() error
+	loadingDefaults bool
+
+	// reader and writer of prompts, PromptReader and PromptWriter used if nil
+	promptReader io.Reader
+	promptWriter io.Writer
+	// bufferedPrompt returns buffered promptReader shared with Context.Stdin,
+	// promptReader is buffered for each reading if nil
+	bufferedPrompt func() *bufio.Reader
+
+	// readValues are values read from files, prompts and editor of flags
+	// parsed before, keyed by flag name. They're reused instead of reading
+	// again, and prompts and editor skipped if not nil, see Context.SetArgv
+	readValues map[string]map[string]string
 }
 
 func newFlagSet() *flagSet {
@@ -31,13 +67,74 @@ func newFlagSet() *flagSet {
 	}
 }
 
-func (fs *flagSet) readPrompt(w io.Writer, clr color.Color) {
+// PromptReader and PromptWriter are used to read values of flags which have
+// tag `prompt` or `pw` but are missing. Prompt is skipped if PromptReader is a
+// file but not a terminal, e.g. stdin redirected.
+var (
+	PromptReader io.Reader = os.Stdin
+	PromptWriter io.Writer = os.Stdout
+)
+
+// stdin returns reader of prompts, it's buffered and shared with
+// Context.Stdin if bufferedPrompt isn't nil
+func (fs *flagSet) stdin() io.Reader {
+	if fs.bufferedPrompt != nil {
+		if br := fs.bufferedPrompt(); br != nil {
+			return br
+		}
+		return nil
+	}
+	if fs.promptReader != nil {
+		return fs.promptReader
+	}
+	return PromptReader
+}
+
+// isTerminalReader reports whether r is a terminal, and whether r could be prompted
+func isTerminalReader(r io.Reader) (isTerminal, canPrompt bool) {
+	if f, ok := r.(*os.File); ok {
+		isTerminal = isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+		return isTerminal, isTerminal
+	}
+	return false, r != nil
+}
+
+func (fs *flagSet) readPrompt(clr color.Color) {
+	r, w := fs.promptReader, fs.promptWriter
+	if r == nil {
+		r = PromptReader
+	}
+	if w == nil {
+		w = PromptWriter
+	}
+	isTerminal, canPrompt := isTerminalReader(r)
+	if !canPrompt {
+		return
+	}
+	var br *bufio.Reader
+	if !isTerminal {
+		if fs.bufferedPrompt != nil {
+			br = fs.bufferedPrompt()
+		} else {
+			br = bufio.NewReader(r)
+		}
+	}
 	for _, fl := range fs.flagSlice {
 		if fl.isAssigned || fl.tag.prompt == "" {
 			continue
 		}
 		// read ...
 		prefix := fl.tag.prompt + ": "
+		if !isTerminal {
+			if fs.err = fl.readPromptFrom(br, w, prefix, clr); fs.err != nil {
+				if fs.err == io.EOF {
+					// no more input
+					fs.err = nil
+				}
+				return
+			}
+			continue
+		}
 		var (
 			data string
 			yes  bool
@@ -45,22 +142,22 @@ func (fs *flagSet) readPrompt(w io.Writer, clr color.Color) {
 		if fl.tag.isPassword {
 			data, fs.err = prompt.Password(prefix)
 			if fs.err == nil && data != "" {
-				fl.setWithNoDelay("", data, clr)
+				fl.setReadValue(data, clr)
 			}
 		} else if fl.isBoolean() {
 			yes, fs.err = prompt.Ask(prefix)
 			if fs.err == nil {
-				fl.setWithNoDelay("", fmt.Sprintf("%v", yes), clr)
+				fl.setReadValue(fmt.Sprintf("%v", yes), clr)
 			}
 		} else if fl.tag.dft != "" {
 			data, fs.err = prompt.BasicDefault(prefix, fl.tag.dft)
 			if fs.err == nil {
-				fl.setWithNoDelay("", data, clr)
+				fl.setReadValue(data, clr)
 			}
 		} else {
 			data, fs.err = prompt.Basic(prefix, fl.tag.isRequired)
 			if fs.err == nil {
-				fl.setWithNoDelay("", data, clr)
+				fl.setReadValue(data, clr)
 			}
 		}
 		if fs.err != nil {
@@ -69,6 +166,70 @@ func (fs *flagSet) readPrompt(w io.Writer, clr color.Color) {
 	}
 }
 
+// readPromptFrom writes prefix to w and reads a line from r as value of flag,
+// nothing echoed for password. io.EOF returned if nothing read.
+func (fl *flag) readPromptFrom(r *bufio.Reader, w io.Writer, prefix string, clr color.Color) error {
+	fmt.Fprint(w, prefix)
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		fmt.Fprintln(w)
+		return err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if fl.tag.isPassword || err == io.EOF {
+		fmt.Fprintln(w)
+	}
+	if line == "" {
+		return nil
+	}
+	if fl.isBoolean() {
+		switch strings.ToLower(line) {
+		case "y", "yes":
+			line = "true"
+		case "n", "no":
+			line = "false"
+		}
+	}
+	if err := fl.setReadValue(line, clr); err != nil {
+		return TypeConversionError{Flag: fl.name(), Value: line, Err: err, clr: clr}
+	}
+	return nil
+}
+
+// setReadValue sets value read from prompt or editor, and keeps it
+func (fl *flag) setReadValue(s string, clr color.Color) error {
+	if err := fl.setWithNoDelay("", s, clr); err != nil {
+		return err
+	}
+	fl.keepReadValue("", s)
+	return nil
+}
+
+// readValueMap returns values read by flags, keyed by flag name
+func (fs *flagSet) readValueMap() map[string]map[string]string {
+	m := make(map[string]map[string]string)
+	for _, fl := range fs.flagSlice {
+		if len(fl.readValues) > 0 {
+			m[fl.name()] = fl.readValues
+		}
+	}
+	return m
+}
+
+// setReadValues sets missing flags by values read from prompts and editor
+// when parsed before, instead of reading again
+func (fs *flagSet) setReadValues(clr color.Color) {
+	for _, fl := range fs.flagSlice {
+		s, ok := fl.readValues[""]
+		if fl.isAssigned || !ok {
+			continue
+		}
+		if fs.err = fl.setReadValue(s, clr); fs.err != nil {
+			return
+		}
+	}
+}
+
 func (fs *flagSet) readEditor(clr color.Color) {
 	editor, editorErr := getEditor()
 	for _, fl := range fs.flagSlice {
@@ -87,12 +248,161 @@ func (fs *flagSet) readEditor(clr color.Color) {
 		if fs.err = err; err != nil {
 			return
 		}
-		if fs.err = fl.setWithNoDelay("", string(data), clr); fs.err != nil {
+		if fs.err = fl.setReadValue(string(data), clr); fs.err != nil {
 			return
 		}
 	}
 }
 
+// checkValues checks choices, range and validators of assigned flags and
+// positionals
+func (fs *flagSet) checkValues(clr color.Color) {
+	for _, fl := range append(append([]*flag{}, fs.flagSlice...), fs.sortedPositionals()...) {
+		if !fl.isAssigned {
+			continue
+		}
+		if fl.tag.validator != nil {
+			if fs.err = fl.checkValidator(clr); fs.err != nil {
+				return
+			}
+		}
+		if len(fl.tag.choices) > 0 {
+			if fs.err = fl.checkChoices(clr); fs.err != nil {
+				return
+			}
+		}
+		if fl.tag.min != nil || fl.tag.max != nil {
+			if fs.err = fl.checkRange(clr); fs.err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (fs *flagSet) checkRequired(clr color.Color) {
+	var missing []string
+	for _, fl := range fs.flagSlice {
+		if !fl.isAssigned && fl.tag.isRequired {
+			missing = append(missing, fl.name())
+		}
+	}
+	for _, fl := range fs.sortedPositionals() {
+		if !fl.isSet && fl.tag.isRequired {
+			missing = append(missing, fl.positionalName())
+		}
+	}
+	if len(missing) > 0 {
+		fs.err = MissingRequiredError{Flags: missing, clr: clr}
+	}
+}
+
+// assignPositionals assigns free args to positionals by their indexes,
+// and assigned args are removed from free args
+func (fs *flagSet) assignPositionals(clr color.Color) {
+	if len(fs.positionals) == 0 {
+		return
+	}
+	assigned := make(map[int]bool)
+	for _, fl := range fs.positionals {
+		var (
+			index = *fl.tag.position
+			name  = fl.positionalName()
+		)
+		if index >= len(fs.args) {
+			// default value
+			if fl.isNeedDelaySet && fl.isAssigned {
+				if err := setWithProperType(fl, fl.field.Type, fl.value, fl.lastValue, clr, false); err != nil {
+					fs.err = TypeConversionError{Flag: name, Value: fl.lastValue, Err: err, clr: clr}
+					return
+				}
+			}
+			continue
+		}
+		if err := fl.setWithNoDelay(name, fs.args[index], clr); err != nil {
+			fs.err = TypeConversionError{Flag: name, Value: fs.args[index], Err: err, clr: clr}
+			return
+		}
+		assigned[index] = true
+	}
+	args := make([]string, 0, len(fs.args)-len(assigned))
+	for i, arg := range fs.args {
+		if !assigned[i] {
+			args = append(args, arg)
+		}
+	}
+	fs.args = args
+}
+
+// sortedPositionals returns positionals sorted by indexes
+func (fs *flagSet) sortedPositionals() []*flag {
+	positionals := append([]*flag{}, fs.positionals...)
+	sort.SliceStable(positionals, func(i, j int) bool {
+		return *positionals[i].tag.position < *positionals[j].tag.position
+	})
+	return positionals
+}
+
+// positionalsUsage returns synopsis of positionals like `<src> <dst>`
+func (fs *flagSet) positionalsUsage() string {
+	names := make([]string, 0, len(fs.positionals))
+	for _, fl := range fs.sortedPositionals() {
+		names = append(names, fl.positionalName())
+	}
+	return strings.Join(names, " ")
+}
+
+// checkMutex checks mutually exclusive groups of flags, at most one flag of
+// each group could be set, and exactly one if any flag of group has tag
+// `mutex_required`
+func (fs *flagSet) checkMutex(clr color.Color) {
+	var (
+		groups   []string
+		flags    = map[string][]*flag{}
+		required = map[string]bool{}
+	)
+	for _, fl := range fs.flagSlice {
+		if fl.tag.mutex == "" {
+			continue
+		}
+		if _, ok := flags[fl.tag.mutex]; !ok {
+			groups = append(groups, fl.tag.mutex)
+		}
+		flags[fl.tag.mutex] = append(flags[fl.tag.mutex], fl)
+		required[fl.tag.mutex] = required[fl.tag.mutex] || fl.tag.isRequiredOne
+	}
+	for _, group := range groups {
+		var set, all []string
+		assigned := false
+		for _, fl := range flags[group] {
+			all = append(all, fl.name())
+			if fl.isSet || fl.isLoaded {
+				set = append(set, fl.name())
+			}
+			assigned = assigned || fl.isAssigned
+		}
+		if len(set) > 1 {
+			fs.err = MutexFlagsError{Group: group, Flags: set, clr: clr}
+			return
+		}
+		if required[group] && !assigned {
+			fs.err = MutexFlagsError{Group: group, Flags: all, Missing: true, clr: clr}
+			return
+		}
+	}
+}
+
+// hasAnyName reports whether any name of tag has been used by flags of fs
+func (fs *flagSet) hasAnyName(tag *tagProperty) bool {
+	for _, names := range [][]string{tag.shortNames, tag.longNames} {
+		for _, name := range names {
+			if _, ok := fs.flagMap[name]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // UsageStyle is style of usage
 type UsageStyle int32
 
@@ -119,9 +429,78 @@ func SetUsageStyle(style UsageStyle) {
 	defaultStyle = style
 }
 
+// defaultTerminalWidth is used if width of terminal unknown
+const defaultTerminalWidth = 80
+
+var usageWidth = 0
+
+// GetUsageWidth gets width of usage, 0 means no wrapping
+func GetUsageWidth() int {
+	if usageWidth > 0 {
+		return usageWidth
+	}
+	if f := terminalFile(os.Stdout); f != nil {
+		width, _, _ := terminalSize(f)
+		return width
+	}
+	return 0
+}
+
+// SetUsageWidth sets width of usage, descriptions of flags would be wrapped
+// to fit the width. 0 means width of terminal is used if stdout is a terminal,
+// or COLUMNS if size of terminal unknown, otherwise descriptions are not wrapped.
+func SetUsageWidth(width int) {
+	usageWidth = width
+}
+
 type flagSlice []*flag
 
+// visible returns flags which are not hidden
+func (fs flagSlice) visible() flagSlice {
+	ret := make(flagSlice, 0, len(fs))
+	for _, fl := range fs {
+		if !fl.tag.isHidden {
+			ret = append(ret, fl)
+		}
+	}
+	return ret
+}
+
+// groups splits flags by tag `group` in order of first appearance,
+// ungrouped flags are placed in the first group which has empty name
+func (fs flagSlice) groups() []flagGroup {
+	var (
+		groups  []flagGroup
+		indices = map[string]int{}
+	)
+	for _, fl := range fs {
+		i, ok := indices[fl.tag.group]
+		if !ok {
+			i = len(groups)
+			indices[fl.tag.group] = i
+			groups = append(groups, flagGroup{name: fl.tag.group})
+		}
+		groups[i].flags = append(groups[i].flags, fl)
+	}
+	if i, ok := indices[""]; ok && i > 0 {
+		ungrouped := groups[i]
+		copy(groups[1:i+1], groups[:i])
+		groups[0] = ungrouped
+	}
+	return groups
+}
+
+type flagGroup struct {
+	name  string
+	flags flagSlice
+}
+
 func (fs flagSlice) String(clr color.Color) string {
+	return fs.stringWithLayout(clr, fs)
+}
+
+// stringWithLayout formats fs, columns aligned by all flags of layout
+func (fs flagSlice) stringWithLayout(clr color.Color, layout flagSlice) string {
 	var (
 		lenShort                 = 0
 		lenLong                  = 0
@@ -129,7 +508,7 @@ func (fs flagSlice) String(clr color.Color) string {
 		lenSep                   = len(sepName)
 		sepSpaces                = strings.Repeat(" ", lenSep)
 	)
-	for _, fl := range fs {
+	for _, fl := range layout {
 		tag := fl.tag
 		l := 0
 		for _, shortName := range tag.shortNames {
@@ -146,8 +525,8 @@ func (fs flagSlice) String(clr color.Color) string {
 			lenLong = l
 		}
 		lenDft := 0
-		if defaultStyle == NormalStyle && tag.dft != "" {
-			lenDft = len(tag.dft) + 3 // 3=len("[=]")
+		if defaultStyle == NormalStyle && fl.displayDefault() != "" {
+			lenDft = len(fl.displayDefault()) + 3 // 3=len("[=]")
 			l += lenDft
 		}
 		if tag.name != "" {
@@ -158,6 +537,10 @@ func (fs flagSlice) String(clr color.Color) string {
 		}
 	}
 
+	var (
+		width  = GetUsageWidth()
+		indent = lenShort + lenSep + lenNameAndDefaultAndLong + 1 // 1=len(usagePrefix)
+	)
 	buff := bytes.NewBufferString("")
 	for _, fl := range fs {
 		var (
@@ -171,8 +554,8 @@ func (fs flagSlice) String(clr color.Color) string {
 		)
 		spaceSize, lenDft := lenNameAndDefaultAndLong, 0
 
-		if tag.dft != "" {
-			defaultStr = fmt.Sprintf("[=%s]", tag.dft)
+		if fl.displayDefault() != "" {
+			defaultStr = fmt.Sprintf("[=%s]", fl.displayDefault())
 			lenDft = len(defaultStr)
 			defaultStr = clr.Grey(defaultStr)
 		}
@@ -182,21 +565,19 @@ func (fs flagSlice) String(clr color.Color) string {
 		if tag.isRequired {
 			usagePrefix = clr.Red("*")
 		}
-		usage := usagePrefix + tag.usage
-		lastNotNewLineIndex := len(usage) - 1
-		for i := len(usage) - 1; i >= 0; i-- {
-			if usage[i] != '\n' {
-				lastNotNewLineIndex = i
-				break
-			}
-		}
+		usage := fl.usage()
 
 		// move defaultStr to the end when in DenseNormalStyle
 		if defaultStyle == DenseNormalStyle {
-			usage = usage[:lastNotNewLineIndex+1] + " " + defaultStr + usage[lastNotNewLineIndex+1:]
+			body := strings.TrimRight(usage, "\n")
+			usage = body + " " + defaultStr + usage[len(body):]
 			defaultStr = ""
 			lenDft = 0
 		}
+		if width > 0 {
+			usage = wrapUsage(usage, indent, width)
+		}
+		usage = usagePrefix + usage
 
 		spaceSize -= len(nameStr) + lenDft + len(longStr)
 
@@ -221,13 +602,71 @@ func (fs flagSlice) String(clr color.Color) string {
 	return buff.String()
 }
 
+// wrapUsage wraps lines of usage to fit width, lines except the first one
+// are indented, trailing newlines are kept
+func wrapUsage(usage string, indent, width int) string {
+	limit := width - indent
+	if limit <= 0 {
+		return usage
+	}
+	body := strings.TrimRight(usage, "\n")
+	lines := []string{}
+	for _, line := range strings.Split(body, "\n") {
+		lines = append(lines, wrapLine(line, limit)...)
+	}
+	return strings.Join(lines, "\n"+strings.Repeat(" ", indent)) + usage[len(body):]
+}
+
+// wrapLine splits line at spaces into lines not wider than limit, words
+// (includes hyphenated words like `read-only`) are never broken
+func wrapLine(line string, limit int) []string {
+	if visibleWidth(line) <= limit {
+		return []string{line}
+	}
+	var (
+		lines   []string
+		current string
+	)
+	for _, word := range strings.Fields(line) {
+		if current == "" {
+			current = word
+		} else if visibleWidth(current)+1+visibleWidth(word) <= limit {
+			current += " " + word
+		} else {
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	return append(lines, current)
+}
+
+// visibleWidth returns number of runes of s, escape sequences of color excluded
+func visibleWidth(s string) int {
+	width, inEscape := 0, false
+	for _, c := range s {
+		switch {
+		case inEscape:
+			inEscape = c != 'm'
+		case c == '\x1b':
+			inEscape = true
+		default:
+			width++
+		}
+	}
+	return width
+}
+
 func fillSpaces(s string, spaceSize int) string {
 	return s + strings.Repeat(" ", spaceSize)
 }
 
 func (fs flagSlice) StringWithStyle(clr color.Color, style UsageStyle) string {
+	return fs.stringWithStyleAndLayout(clr, style, fs)
+}
+
+func (fs flagSlice) stringWithStyleAndLayout(clr color.Color, style UsageStyle, layout flagSlice) string {
 	if style != ManualStyle && style != DenseManualStyle {
-		return fs.String(clr)
+		return fs.stringWithLayout(clr, layout)
 	}
 
 	buf := bytes.NewBufferString("")
@@ -242,8 +681,8 @@ func (fs flagSlice) StringWithStyle(clr color.Color, style UsageStyle) string {
 		if fl.tag.name != "" {
 			buf.WriteString("=" + clr.Bold(fl.tag.name))
 		}
-		if fl.tag.dft != "" {
-			buf.WriteString(clr.Grey(fmt.Sprintf("[=%s]", fl.tag.dft)))
+		if fl.displayDefault() != "" {
+			buf.WriteString(clr.Grey(fmt.Sprintf("[=%s]", fl.displayDefault())))
 		}
 		buf.WriteString("\n")
 		buf.WriteString(linePrefix)
@@ -251,7 +690,7 @@ func (fs flagSlice) StringWithStyle(clr color.Color, style UsageStyle) string {
 		if fl.tag.isRequired {
 			buf.WriteString(clr.Red("*"))
 		}
-		buf.WriteString(fl.tag.usage)
+		buf.WriteString(fl.usage())
 		if style != DenseManualStyle {
 			buf.WriteString("\n")
 		}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"net"
 	"net/http"
@@ -106,6 +107,167 @@ func (cmd *Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf.Bytes())
 }
 
+// HTTPHandler returns a http.Handler which routes path of request to
+// commands, e.g. `/hello/world?name=x` runs `hello world --name=x`.
+// Query, cookies, headers and JSON body of request are bound to argv, and
+// output of command is written to response. Response status is 404 if command
+// not found, 400 if flags invalid, and error is written as JSON like `{"error":"..."}`.
+// Error returned by command after it wrote response is dropped since status
+// and partial output have been sent.
+func (cmd *Command) HTTPHandler() http.Handler {
+	return httpHandler{cmd: cmd}
+}
+
+type httpHandler struct {
+	cmd *Command
+}
+
+func (h httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clr := color.Color{}
+	clr.Disable()
+
+	router := []string{}
+	for _, s := range strings.Split(r.URL.Path, "/") {
+		if s != "" {
+			router = append(router, s)
+		}
+	}
+	child, end := h.cmd.SubRoute(router)
+	if (!child.CanSubRoute && end != len(router)) || child.Fn == nil {
+		path := strings.Join(router, " ")
+		if path == "" {
+			path = h.cmd.Name
+		}
+		writeHTTPError(w, http.StatusNotFound, throwCommandNotFound(path))
+		return
+	}
+	if len(child.HTTPMethods) > 0 {
+		allowed := false
+		for _, m := range child.HTTPMethods {
+			if m == r.Method {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			writeHTTPError(w, http.StatusMethodNotAllowed, throwMethodNotAllowed(r.Method))
+			return
+		}
+	}
+
+	rw := &httpResponseWriter{ResponseWriter: w}
+	ctx, status, err := h.newContext(child, router, end, rw, r, clr)
+	if err != nil {
+		writeHTTPError(w, status, err)
+		return
+	}
+	if err := h.cmd.run(ctx); err != nil {
+		if rw.written {
+			// status and partial output have been sent
+			debug.Debugf("error after response written: %v", err)
+			return
+		}
+		writeHTTPError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// httpResponseWriter records whether response has been written, so that
+// error of command isn't appended to partial output
+type httpResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *httpResponseWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *httpResponseWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush implements http.Flusher
+func (w *httpResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
+
+// newContext creates context of command child, flags are bound from query,
+// cookies, headers and body of request, required flags and choices checked after binding
+func (h httpHandler) newContext(child *Command, router []string, end int, w http.ResponseWriter, r *http.Request, clr color.Color) (*Context, int, error) {
+	ctx := &Context{
+		path:         child.Path(),
+		router:       router[:end],
+		argvList:     child.argvList(),
+		nativeArgs:   router[end:],
+		color:        clr,
+		command:      child,
+		writer:       w,
+		flagSet:      newFlagSet(),
+		goCtx:        r.Context(),
+		global:       child.globalArgv(),
+		HTTPRequest:  r,
+		HTTPResponse: w,
+	}
+	persistentList := child.persistentArgvList()
+	argvList := withGlobalArgv(ctx.argvList, ctx.global)
+	if isEmptyArgvList(argvList) && len(persistentList) == 0 {
+		ctx.flagSet.args = ctx.nativeArgs
+		return ctx, 0, nil
+	}
+	// values of prompt and editor flags are bound from request, never read
+	// from stdin or editor of server
+	flagSet := newFlagSet()
+	flagSet.readValues = make(map[string]map[string]string)
+	ctx.flagSet = parseArgvListTo(flagSet, ctx.nativeArgs, argvList, clr, persistentList...)
+	if err := ctx.flagSet.err; err != nil {
+		// required flags may be bound from request
+		if _, ok := err.(MissingRequiredError); !ok {
+			return nil, http.StatusBadRequest, err
+		}
+		ctx.flagSet.err = nil
+	}
+	if err := ctx.BindHTTP(BindOrder(BindSourceQuery, BindSourceCookie, BindSourceHeader, BindSourceBody)); err != nil {
+		if _, ok := err.(bodyTooLargeError); ok {
+			return nil, http.StatusRequestEntityTooLarge, err
+		}
+		return nil, http.StatusBadRequest, err
+	}
+	if ctx.flagSet.checkMutex(clr); ctx.flagSet.err != nil {
+		return nil, http.StatusBadRequest, ctx.flagSet.err
+	}
+	if ctx.flagSet.checkRequired(clr); ctx.flagSet.err != nil {
+		return nil, http.StatusBadRequest, ctx.flagSet.err
+	}
+	for _, argv := range ctx.argvList {
+		if err := validateArgv(ctx, argv); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+	return ctx, 0, nil
+}
+
+// writeHTTPError writes err as JSON with status code
+func writeHTTPError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// JSONError writes err as JSON like `{"error":"..."}` with status code if
+// HTTPResponse isn't nil, or writes err to Stderr otherwise
+func (ctx *Context) JSONError(status int, err error) *Context {
+	if ctx.HTTPResponse != nil {
+		writeHTTPError(ctx.HTTPResponse, status, err)
+		return ctx
+	}
+	return ctx.ErrString("%v\n", err)
+}
+
 // ListenAndServeHTTP set IsServer flag with true and startup http service
 func (cmd *Command) ListenAndServeHTTP(addr string) error {
 	cmd.SetIsServer(true)
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type httpHelloT struct {
+	Name  string   `cli:"*name" json:"name"`
+	Count int      `cli:"count" dft:"1" json:"count"`
+	Tags  []string `cli:"tag" json:"tags"`
+}
+
+func (argv *httpHelloT) Validate(ctx *Context) error {
+	if argv.Count < 0 {
+		return errors.New("count must not be negative")
+	}
+	return nil
+}
+
+func newHTTPTestApp() *Command {
+	root := &Command{Name: "app"}
+	root.Register(&Command{
+		Name:    "hello",
+		Aliases: []string{"hi"},
+		Argv:    func() interface{} { return new(httpHelloT) },
+		Fn: func(ctx *Context) error {
+			argv := ctx.Argv().(*httpHelloT)
+			ctx.String("%s %d %s", argv.Name, argv.Count, strings.Join(argv.Tags, ","))
+			return nil
+		},
+	})
+	root.Register(&Command{
+		Name:        "fail",
+		HTTPMethods: []string{"POST"},
+		Fn: func(ctx *Context) error {
+			return errors.New("oops")
+		},
+	})
+	root.Register(&Command{
+		Name: "partial",
+		Fn: func(ctx *Context) error {
+			ctx.String("partial output")
+			return errors.New("oops")
+		},
+	})
+	return root
+}
+
+func TestHTTPHandler(t *testing.T) {
+	handler := newHTTPTestApp().HTTPHandler()
+	for i, tt := range []struct {
+		method string
+		target string
+		body   string
+		status int
+		want   string
+		err    string
+	}{
+		{"GET", "/hello?name=cli", "", http.StatusOK, "cli 1 ", ""},
+		{"GET", "/hi/?name=cli&count=3&tag=a&tag=b", "", http.StatusOK, "cli 3 a,b", ""},
+		{"POST", "/hello", `{"name":"cli","count":2,"tags":["x"]}`, http.StatusOK, "cli 2 x", ""},
+		{"POST", "/hello?name=q", `{"name":"cli","count":2}`, http.StatusOK, "q 2 ", ""},
+		{"GET", "/", "", http.StatusNotFound, "", "command app not found"},
+		{"GET", "/hello/world", "", http.StatusNotFound, "", "command hello world not found"},
+		{"GET", "/hello", "", http.StatusBadRequest, "", "required parameter --name missing"},
+		{"GET", "/hello?name=cli&count=x", "", http.StatusBadRequest, "", "parameter --count invalid: `x' couldn't converted to an int"},
+		{"GET", "/hello?name=cli&count=-1", "", http.StatusBadRequest, "", "count must not be negative"},
+		{"POST", "/hello", `{"name":`, http.StatusBadRequest, "", "malformed JSON body: unexpected end of JSON input"},
+		{"GET", "/fail", "", http.StatusMethodNotAllowed, "", "method GET not allowed"},
+		{"POST", "/fail", "", http.StatusInternalServerError, "", "oops"},
+		// query wins body for slices
+		{"POST", "/hello?name=q&tag=a", `{"tags":["x","y"]}`, http.StatusOK, "q 1 a", ""},
+		// error after output written
+		{"GET", "/partial", "", http.StatusOK, "partial output", ""},
+	} {
+		r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, tt.status, w.Code, "case %d", i)
+		if tt.err == "" {
+			assert.Equal(t, tt.want, w.Body.String(), "case %d", i)
+			continue
+		}
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), "case %d", i)
+		var body struct {
+			Error string `json:"error"`
+		}
+		if assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body), "case %d", i) {
+			assert.Equal(t, tt.err, body.Error, "case %d", i)
+		}
+	}
+}
+
+func TestHTTPHandlerPrompt(t *testing.T) {
+	defer func(r io.Reader, w io.Writer) { PromptReader, PromptWriter = r, w }(PromptReader, PromptWriter)
+	stdout := bytes.NewBufferString("")
+	PromptReader, PromptWriter = strings.NewReader("server-stdin\nserver-stdin\n"), stdout
+	type argT struct {
+		Name string `cli:"name" prompt:"name" json:"name"`
+	}
+	root := &Command{Name: "app"}
+	root.Register(&Command{
+		Name: "greet",
+		Argv: func() interface{} { return new(argT) },
+		Fn: func(ctx *Context) error {
+			ctx.String("%s", ctx.Argv().(*argT).Name)
+			return nil
+		},
+	})
+	handler := root.HTTPHandler()
+	for i, tt := range []struct {
+		target string
+		want   string
+	}{
+		{"/greet?name=q", "q"},
+		{"/greet", ""},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", tt.target, nil))
+		assert.Equal(t, http.StatusOK, w.Code, "case %d", i)
+		assert.Equal(t, tt.want, w.Body.String(), "case %d", i)
+	}
+	assert.Equal(t, "", stdout.String())
+}
+
+func TestContextJSONError(t *testing.T) {
+	// HTTP
+	w := httptest.NewRecorder()
+	ctx := &Context{HTTPResponse: w}
+	ctx.JSONError(http.StatusConflict, errors.New("already exists"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"error\":\"already exists\"}\n", w.Body.String())
+
+	// CLI
+	stdout, stderr := bytes.NewBufferString(""), bytes.NewBufferString("")
+	ctx = &Context{writer: stdout, errWriter: stderr}
+	ctx.JSONError(http.StatusConflict, errors.New("already exists"))
+	assert.Equal(t, "", stdout.String())
+	assert.Equal(t, "already exists\n", stderr.String())
+}
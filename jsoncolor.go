@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/labstack/gommon/color"
+)
+
+// JSONColor writes pretty json string of obj to writer like JSONIndent with
+// two-space indent, keys, strings, numbers, booleans and null are colored if
+// color of ctx enabled
+func (ctx *Context) JSONColor(obj interface{}) *Context {
+	if err := ctx.JSONColorE(obj); err != nil {
+		ctx.Logf(LevelDebug, "JSONColor: %v", err)
+	}
+	return ctx
+}
+
+// JSONColorE writes colored pretty json string of obj to writer like
+// JSONColor, error of marshaling or writing returned
+func (ctx *Context) JSONColorE(obj interface{}) error {
+	var buf bytes.Buffer
+	if err := ctx.JSONIndentTo(&buf, obj, "", "  "); err != nil {
+		return err
+	}
+	_, err := io.WriteString(ctx, colorizeJSON(buf.Bytes(), *ctx.Color()))
+	return err
+}
+
+var jsonNumberBytes = []byte("0123456789.eE+-")
+
+// colorizeJSON colors tokens of valid JSON data
+func colorizeJSON(data []byte, clr color.Color) string {
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)))
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for ; end < len(data) && data[end] != '"'; end++ {
+				if data[end] == '\\' {
+					end++
+				}
+			}
+			end++
+			token := string(data[i:end])
+			if isJSONKey(data[end:]) {
+				buf.WriteString(clr.Blue(token))
+			} else {
+				buf.WriteString(clr.Green(token))
+			}
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(data) && bytes.IndexByte(jsonNumberBytes, data[end]) >= 0 {
+				end++
+			}
+			buf.WriteString(clr.Cyan(string(data[i:end])))
+			i = end
+		case bytes.HasPrefix(data[i:], []byte("true")):
+			buf.WriteString(clr.Yellow("true"))
+			i += len("true")
+		case bytes.HasPrefix(data[i:], []byte("false")):
+			buf.WriteString(clr.Yellow("false"))
+			i += len("false")
+		case bytes.HasPrefix(data[i:], []byte("null")):
+			buf.WriteString(clr.Grey("null"))
+			i += len("null")
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	return buf.String()
+}
+
+// isJSONKey reports whether the string before rest is a key of object
+func isJSONKey(rest []byte) bool {
+	rest = bytes.TrimLeft(rest, " \t\r\n")
+	return len(rest) > 0 && rest[0] == ':'
+}
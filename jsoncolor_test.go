@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextJSONColor(t *testing.T) {
+	obj := map[string]interface{}{
+		"name":  "a \"quoted\" string",
+		"count": -1.5e3,
+		"nested": map[string]interface{}{
+			"ok":   true,
+			"no":   false,
+			"none": nil,
+			"list": []interface{}{1, "x:y"},
+		},
+	}
+
+	// uncolored output equals to JSONIndent
+	clr := color.Color{}
+	clr.Disable()
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w, color: clr}
+	ctx.JSONColor(obj)
+	want, _ := json.MarshalIndent(obj, "", "  ")
+	assert.Equal(t, string(want), w.String())
+
+	clr.Enable()
+	w.Reset()
+	ctx = &Context{writer: w, color: clr}
+	ctx.JSONColor(obj)
+	assert.Equal(t, "{\n"+
+		"  "+clr.Blue(`"count"`)+": "+clr.Cyan("-1500")+",\n"+
+		"  "+clr.Blue(`"name"`)+": "+clr.Green(`"a \"quoted\" string"`)+",\n"+
+		"  "+clr.Blue(`"nested"`)+": {\n"+
+		"    "+clr.Blue(`"list"`)+": [\n"+
+		"      "+clr.Cyan("1")+",\n"+
+		"      "+clr.Green(`"x:y"`)+"\n"+
+		"    ],\n"+
+		"    "+clr.Blue(`"no"`)+": "+clr.Yellow("false")+",\n"+
+		"    "+clr.Blue(`"none"`)+": "+clr.Grey("null")+",\n"+
+		"    "+clr.Blue(`"ok"`)+": "+clr.Yellow("true")+"\n"+
+		"  }\n"+
+		"}", w.String())
+
+	// error of marshaling returned or logged at LevelDebug
+	bad := map[string]interface{}{"ch": make(chan int)}
+	w.Reset()
+	ew := bytes.NewBufferString("")
+	ctx = &Context{writer: w, errWriter: ew, color: clr}
+	var typeErr *json.UnsupportedTypeError
+	assert.True(t, errors.As(ctx.JSONColorE(bad), &typeErr))
+	ctx.SetLogLevel(LevelDebug)
+	ctx.JSONColor(bad)
+	assert.Equal(t, "", w.String())
+	assert.Contains(t, ew.String(), "JSONColor: json: unsupported type: chan int\n")
+}
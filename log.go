@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is severity of logs written by Context.Logf
+type Level int
+
+// Levels of logs, zero value is LevelInfo
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns name of level, e.g. "WARN"
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(level))
+}
+
+// LevelFromVerbosity returns threshold of logs for verbosity, which is
+// usually count of flag `-v`, e.g. LevelInfo for 0 and LevelDebug for 1
+func LevelFromVerbosity(verbosity int) Level {
+	if verbosity > 0 {
+		return LevelDebug
+	}
+	return LevelInfo
+}
+
+// SetLogLevel sets threshold of Logf, logs below level are discarded.
+// Default is LevelInfo, e.g.
+//
+//	type argT struct {
+//		Verbose int `cli:"v,verbose" usage:"verbose level" count:"true"`
+//	}
+//
+//	ctx.SetLogLevel(cli.LevelFromVerbosity(argv.Verbose))
+func (ctx *Context) SetLogLevel(level Level) *Context {
+	ctx.logLevel = level
+	return ctx
+}
+
+// LogLevel returns threshold of Logf
+func (ctx *Context) LogLevel() Level {
+	return ctx.logLevel
+}
+
+// Logf writes a line prefixed by colorized level tag to stderr if level
+// isn't below threshold of ctx, e.g.
+//
+//	ctx.Logf(cli.LevelWarn, "config %s not found", filename)
+//
+// writes "[WARN] config app.json not found".
+func (ctx *Context) Logf(level Level, format string, args ...interface{}) *Context {
+	if level < ctx.logLevel {
+		return ctx
+	}
+	msg := fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	fmt.Fprintf(ctx.Stderr(), "%s %s", ctx.levelTag(level), msg)
+	return ctx
+}
+
+// levelTag returns colorized tag of level, e.g. "[WARN]"
+func (ctx *Context) levelTag(level Level) string {
+	tag := "[" + level.String() + "]"
+	switch {
+	case level >= LevelError:
+		return ctx.color.Red(tag)
+	case level == LevelWarn:
+		return ctx.color.Yellow(tag)
+	case level == LevelInfo:
+		return ctx.color.Green(tag)
+	}
+	return ctx.color.Grey(tag)
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextLogf(t *testing.T) {
+	clr := color.Color{}
+	clr.Disable()
+	w := bytes.NewBufferString("")
+	ctx := &Context{errWriter: w, color: clr}
+
+	// default threshold is LevelInfo
+	assert.Equal(t, LevelInfo, ctx.LogLevel())
+	ctx.Logf(LevelDebug, "debug %d", 1)
+	ctx.Logf(LevelInfo, "info %d\n", 2)
+	assert.Equal(t, "[INFO] info 2\n", w.String())
+
+	w.Reset()
+	ctx.SetLogLevel(LevelWarn)
+	ctx.Logf(LevelDebug, "debug")
+	ctx.Logf(LevelInfo, "info")
+	ctx.Logf(LevelWarn, "warn")
+	ctx.Logf(LevelError, "error")
+	assert.Equal(t, "[WARN] warn\n[ERROR] error\n", w.String())
+
+	w.Reset()
+	ctx.SetLogLevel(LevelFromVerbosity(2))
+	ctx.Logf(LevelDebug, "debug")
+	assert.Equal(t, "[DEBUG] debug\n", w.String())
+	assert.Equal(t, LevelInfo, LevelFromVerbosity(0))
+
+	// colored level tags
+	clr.Enable()
+	ctx = &Context{errWriter: w, color: clr}
+	ctx.SetLogLevel(LevelDebug)
+	for i, tt := range []struct {
+		level Level
+		want  string
+	}{
+		{LevelDebug, clr.Grey("[DEBUG]") + " x\n"},
+		{LevelInfo, clr.Green("[INFO]") + " x\n"},
+		{LevelWarn, clr.Yellow("[WARN]") + " x\n"},
+		{LevelError, clr.Red("[ERROR]") + " x\n"},
+	} {
+		w.Reset()
+		ctx.Logf(tt.level, "x")
+		assert.Equal(t, tt.want, w.String(), "case %d", i)
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultPager is used by Pager if environment variable PAGER is empty
+var DefaultPager = "less -R"
+
+// defaultTerminalHeight is used if height of terminal unknown
+const defaultTerminalHeight = 24
+
+// Pager buffers output, see Context.Pager
+type Pager struct {
+	ctx    *Context
+	buf    bytes.Buffer
+	closed bool
+}
+
+// Pager returns a writer which buffers output until Close. Buffered output
+// is piped through $PAGER ("less -R" by default) if writer of ctx is a
+// terminal and output exceeds height of the terminal, or written to writer
+// of ctx otherwise, e.g.
+//
+//	pager := ctx.Pager()
+//	defer pager.Close()
+//	fmt.Fprintln(pager, longText)
+func (ctx *Context) Pager() *Pager {
+	return &Pager{ctx: ctx}
+}
+
+// UsePager forces Pager to use pager if use is true even though output is
+// short, or disables pager if use is false. Pager is never used if writer
+// isn't a terminal.
+func (ctx *Context) UsePager(use bool) *Context {
+	ctx.usePager = &use
+	return ctx
+}
+
+// Write implements io.Writer
+func (p *Pager) Write(data []byte) (int, error) {
+	return p.buf.Write(data)
+}
+
+// Close writes buffered output through pager or to writer directly
+func (p *Pager) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	w := p.ctx.unbufferedWriter()
+	if !p.ctx.shouldPage(p.buf.Bytes()) {
+		_, err := w.Write(p.buf.Bytes())
+		return err
+	}
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = strings.Fields(DefaultPager)
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		// pager not found
+		_, err := w.Write(p.buf.Bytes())
+		return err
+	}
+	return p.command(path, args[1:], w).Run()
+}
+
+// command creates pager command which writes to w. File of w is passed to
+// pager as stdout rather than w itself, so that pager runs on the terminal
+// instead of a pipe
+func (p *Pager) command(path string, args []string, w io.Writer) *exec.Cmd {
+	pager := exec.Command(path, args...)
+	pager.Stdin, pager.Stdout, pager.Stderr = &p.buf, w, p.ctx.Stderr()
+	if f := writerFile(w); f != nil {
+		pager.Stdout = f
+	}
+	return pager
+}
+
+// shouldPage reports whether content should be piped through pager, content
+// is paged if it exceeds rows of terminal
+func (ctx *Context) shouldPage(content []byte) bool {
+	if !ctx.IsTTY() || ctx.usePager != nil && !*ctx.usePager {
+		return false
+	}
+	if ctx.usePager != nil {
+		return true
+	}
+	_, rows, _ := ctx.TerminalSize()
+	return bytes.Count(content, []byte{'\n'}) >= rows
+}
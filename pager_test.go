@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPager(t *testing.T) {
+	oldPager := os.Getenv("PAGER")
+	defer os.Setenv("PAGER", oldPager)
+	// pager must not be invoked for non-terminal writer
+	os.Setenv("PAGER", "false")
+
+	content := strings.Repeat("line\n", 100)
+	for i, use := range []*bool{nil, newBool(true), newBool(false)} {
+		w := bytes.NewBufferString("")
+		ctx := &Context{writer: w}
+		if use != nil {
+			ctx.UsePager(*use)
+		}
+		pager := ctx.Pager()
+		fmt.Fprint(pager, content)
+		assert.Equal(t, "", w.String(), "case %d", i)
+		assert.Nil(t, pager.Close(), "case %d", i)
+		assert.Nil(t, pager.Close(), "case %d", i)
+		assert.Equal(t, content, w.String(), "case %d", i)
+	}
+}
+
+func TestShouldPage(t *testing.T) {
+	ctx := &Context{writer: bytes.NewBufferString("")}
+	assert.False(t, ctx.shouldPage([]byte(strings.Repeat("\n", 100))))
+	assert.False(t, ctx.UsePager(true).shouldPage([]byte(strings.Repeat("\n", 100))))
+	assert.False(t, isTerminalWriter(bytes.NewBufferString("")))
+	assert.Nil(t, terminalFile(bytes.NewBufferString("")))
+}
+
+func TestPagerCommand(t *testing.T) {
+	f, err := ioutil.TempFile("", "cli")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	ctx := &Context{writer: bytes.NewBufferString("")}
+	pager := ctx.Pager()
+	for i, w := range []io.Writer{&stdoutWriter{Writer: bytes.NewBufferString(""), file: f}, f} {
+		assert.Equal(t, f, pager.command("less", nil, w).Stdout, "case %d", i)
+	}
+	w := bytes.NewBufferString("")
+	assert.Equal(t, w, pager.command("less", nil, w).Stdout)
+}
+
+func newBool(b bool) *bool { return &b }
@@ -2,7 +2,16 @@ package cli
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/mail"
 	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // FlagParser represents a parser for parsing flag
@@ -23,11 +32,200 @@ func RegisterFlagParser(name string, creator FlagParserCreator) {
 	parserCreators[name] = creator
 }
 
+// NormalizerFunc normalizes raw value of flag before conversion and
+// validation, e.g. strings.ToLower
+type NormalizerFunc func(string) string
+
+var normalizers = map[string]NormalizerFunc{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// RegisterNormalizer registers NormalizerFunc by name, normalizers are
+// referenced by tag `normalize`, e.g.
+//
+//	Email string `cli:"email" normalize:"trim,lower"`
+//
+// Builtin normalizers are trim, lower and upper.
+func RegisterNormalizer(name string, fn NormalizerFunc) {
+	if _, ok := normalizers[name]; ok {
+		panic("RegisterNormalizer has registered: " + name)
+	}
+	normalizers[name] = fn
+}
+
+// FlagValidatorFunc validates value of flag named name after conversion,
+// value is formatted by fmt, and each element of slice validated alone
+type FlagValidatorFunc func(name, value string) error
+
+// regexpValidatorPrefix leads pattern of validator `regexp:<pattern>`
+const regexpValidatorPrefix = "regexp:"
+
+var flagValidators = map[string]FlagValidatorFunc{
+	"url":   validateURL,
+	"email": validateEmail,
+}
+
+// RegisterFlagValidator registers FlagValidatorFunc by name, validators are
+// referenced by tag `validate`, e.g.
+//
+//	Endpoint string `cli:"endpoint" validate:"url"`
+//	Name     string `cli:"name" validate:"regexp:^[a-z][a-z0-9-]*$"`
+//
+// Builtin validators are url, email and regexp:<pattern>.
+func RegisterFlagValidator(name string, fn FlagValidatorFunc) {
+	if _, ok := flagValidators[name]; ok || strings.HasPrefix(name, regexpValidatorPrefix) {
+		panic("RegisterFlagValidator has registered: " + name)
+	}
+	flagValidators[name] = fn
+}
+
+// regexpValidators caches validators `regexp:<pattern>` by pattern, since
+// tags are parsed for each parsing and rendering usage
+var regexpValidators struct {
+	sync.Mutex
+	validators map[string]FlagValidatorFunc
+}
+
+// lookupFlagValidator returns validator by name, pattern of validator
+// `regexp:<pattern>` is compiled once
+func lookupFlagValidator(name string) (FlagValidatorFunc, error) {
+	if strings.HasPrefix(name, regexpValidatorPrefix) {
+		return lookupRegexpValidator(strings.TrimPrefix(name, regexpValidatorPrefix))
+	}
+	if fn, ok := flagValidators[name]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown validator %s", name)
+}
+
+func lookupRegexpValidator(pattern string) (FlagValidatorFunc, error) {
+	regexpValidators.Lock()
+	defer regexpValidators.Unlock()
+	if fn, ok := regexpValidators.validators[pattern]; ok {
+		return fn, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	fn := func(_, value string) error {
+		if !re.MatchString(value) {
+			return fmt.Errorf("`%s' does not match %s", value, pattern)
+		}
+		return nil
+	}
+	if regexpValidators.validators == nil {
+		regexpValidators.validators = make(map[string]FlagValidatorFunc)
+	}
+	regexpValidators.validators[pattern] = fn
+	return fn, nil
+}
+
+// validateURL requires an absolute URL with scheme and host
+func validateURL(_, value string) error {
+	if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("`%s' is not a valid URL", value)
+	}
+	return nil
+}
+
+// validateEmail requires a bare email address, e.g. name@example.com
+func validateEmail(_, value string) error {
+	if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+		return fmt.Errorf("`%s' is not a valid email address", value)
+	}
+	return nil
+}
+
+// TypeParserFunc parses tokens of flag and sets val, val is a settable value
+// of registered type. Tokens contain value of a single occurrence of flag.
+type TypeParserFunc func(tokens []string, val reflect.Value) error
+
+var typeParsers = map[reflect.Type]TypeParserFunc{}
+
+// RegisterTypeParser registers TypeParserFunc for values of typ, it's used
+// before built-in conversion for flags of typ or slices of typ, e.g.
+//
+//	cli.RegisterTypeParser(reflect.TypeOf(net.IP{}), func(tokens []string, val reflect.Value) error {
+//		ip := net.ParseIP(tokens[0])
+//		if ip == nil {
+//			return fmt.Errorf("invalid ip %s", tokens[0])
+//		}
+//		val.Set(reflect.ValueOf(ip))
+//		return nil
+//	})
+func RegisterTypeParser(typ reflect.Type, parser TypeParserFunc) {
+	if _, ok := typeParsers[typ]; ok {
+		panic("RegisterTypeParser has registered: " + typ.String())
+	}
+	typeParsers[typ] = parser
+}
+
+// RegisterBitmask registers TypeParserFunc for integer type typ, which
+// parses comma-separated names to OR-ed values of these names, e.g.
+//
+//	type Feature uint
+//
+//	const (
+//		FeatureA Feature = 1 << iota
+//		FeatureB
+//	)
+//
+//	cli.RegisterBitmask(reflect.TypeOf(Feature(0)), map[string]uint64{
+//		"a": uint64(FeatureA),
+//		"b": uint64(FeatureB),
+//	})
+//
+// Then `--features a,b` sets field `Features Feature` to FeatureA|FeatureB,
+// and empty value is 0.
+func RegisterBitmask(typ reflect.Type, names map[string]uint64) {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic("RegisterBitmask: " + typ.String() + " isn't an integer type")
+	}
+	options := make([]string, 0, len(names))
+	for name := range names {
+		options = append(options, name)
+	}
+	sort.Slice(options, func(i, j int) bool {
+		if names[options[i]] != names[options[j]] {
+			return names[options[i]] < names[options[j]]
+		}
+		return options[i] < options[j]
+	})
+	RegisterTypeParser(typ, func(tokens []string, val reflect.Value) error {
+		var mask uint64
+		for _, name := range strings.Split(tokens[0], pairSep) {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			bit, ok := names[name]
+			if !ok {
+				return fmt.Errorf("unknown name `%s', valid options: %s", name, strings.Join(options, "|"))
+			}
+			mask |= bit
+		}
+		if val.Kind() >= reflect.Uint && val.Kind() <= reflect.Uint64 {
+			val.SetUint(mask)
+		} else {
+			val.SetInt(int64(mask))
+		}
+		return nil
+	})
+}
+
 func init() {
 	RegisterFlagParser("json", newJSONParser)
 	RegisterFlagParser("jsonfile", newJSONFileParser)
 	RegisterFlagParser("jsoncfg", newJSONConfigFileParser)
 	RegisterFlagParser("url", newURLParser)
+
+	RegisterTypeParser(reflect.TypeOf(time.Duration(0)), parseDuration)
+	RegisterTypeParser(reflect.TypeOf(ByteSize(0)), parseByteSize)
 }
 
 // JSON parser
@@ -86,3 +284,101 @@ func (p *URLParser) Parse(s string) error {
 	*p.ptr = *u
 	return nil
 }
+
+// parseDuration parses duration like `1h30m`, integer means nanoseconds
+func parseDuration(tokens []string, val reflect.Value) error {
+	s := tokens[0]
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		val.SetInt(i)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("`%s' couldn't converted to a duration like 2h30m", s)
+	}
+	val.SetInt(int64(d))
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// parseTime parses time by layout, time.RFC3339 used if layout is empty
+func parseTime(s, layout string, val reflect.Value) error {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return fmt.Errorf("`%s' couldn't converted to a time of format %s", s, layout)
+	}
+	val.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// ByteSize represents number of bytes, it's parsed from size with unit, e.g.
+// `512`, `10KB`, `1.5GiB`. Units are case-insensitive, KB/MB/GB/TB are
+// powers of 1000 and KiB/MiB/GiB/TiB are powers of 1024.
+type ByteSize uint64
+
+// Units of ByteSize
+const (
+	KB ByteSize = 1000
+	MB          = KB * 1000
+	GB          = MB * 1000
+	TB          = GB * 1000
+
+	KiB ByteSize = 1 << 10
+	MiB          = KiB << 10
+	GiB          = MiB << 10
+	TiB          = GiB << 10
+)
+
+var byteSizeUnits = []struct {
+	name string
+	size ByteSize
+}{
+	// longer suffix first
+	{"kib", KiB}, {"mib", MiB}, {"gib", GiB}, {"tib", TiB},
+	{"kb", KB}, {"mb", MB}, {"gb", GB}, {"tb", TB},
+	{"b", 1},
+}
+
+// String formats b with the largest unit which divides b
+func (b ByteSize) String() string {
+	for _, unit := range []struct {
+		name string
+		size ByteSize
+	}{
+		{"TiB", TiB}, {"TB", TB}, {"GiB", GiB}, {"GB", GB},
+		{"MiB", MiB}, {"MB", MB}, {"KiB", KiB}, {"KB", KB},
+	} {
+		if b >= unit.size && b%unit.size == 0 {
+			return fmt.Sprintf("%d%s", b/unit.size, unit.name)
+		}
+	}
+	return fmt.Sprintf("%dB", uint64(b))
+}
+
+// Encode implements Encoder
+func (b ByteSize) Encode() string { return b.String() }
+
+func parseByteSize(tokens []string, val reflect.Value) error {
+	s := strings.TrimSpace(tokens[0])
+	num, size := strings.ToLower(s), ByteSize(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(num, unit.name) {
+			num, size = strings.TrimSpace(strings.TrimSuffix(num, unit.name)), unit.size
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("`%s' couldn't converted to a byte size", s)
+	}
+	n := f * float64(size)
+	if n >= 1<<64 {
+		return fmt.Errorf("`%s' overflows byte size", s)
+	}
+	val.SetUint(uint64(n))
+	return nil
+}
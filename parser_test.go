@@ -1,10 +1,15 @@
 package cli
 
 import (
+	"fmt"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestJSONParser(t *testing.T) {
@@ -48,3 +53,165 @@ func TestURLParser(t *testing.T) {
 		t.Errorf("host want %v, got %v", "www.google.com", v.Addr.Host)
 	}
 }
+
+func TestTypeParser(t *testing.T) {
+	type argT struct {
+		Timeout  time.Duration   `cli:"timeout" dft:"1s"`
+		Retries  []time.Duration `cli:"retry"`
+		Size     ByteSize        `cli:"size"`
+		Upper    upperT          `cli:"upper"`
+		MaxBytes ByteSize        `cli:"max" dft:"1KiB"`
+	}
+	RegisterTypeParser(reflect.TypeOf(upperT("")), func(tokens []string, val reflect.Value) error {
+		if tokens[0] == "" {
+			return fmt.Errorf("empty string")
+		}
+		val.SetString(strings.ToUpper(tokens[0]))
+		return nil
+	})
+	defer delete(typeParsers, reflect.TypeOf(upperT("")))
+
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want argT
+		err  string
+	}{
+		{args: []string{}, want: argT{Timeout: time.Second, MaxBytes: 1024}},
+		{args: []string{"--timeout=1m30s", "--retry", "100ms", "--retry=2s"}, want: argT{Timeout: 90 * time.Second, Retries: []time.Duration{100 * time.Millisecond, 2 * time.Second}, MaxBytes: 1024}},
+		{args: []string{"--timeout=1000"}, want: argT{Timeout: 1000, MaxBytes: 1024}},
+		{args: []string{"--size=512", "--max=10MB"}, want: argT{Timeout: time.Second, Size: 512, MaxBytes: 10 * MB}},
+		{args: []string{"--size=1.5gib"}, want: argT{Timeout: time.Second, Size: 3 * GiB / 2, MaxBytes: 1024}},
+		{args: []string{"--size", "2 KB"}, want: argT{Timeout: time.Second, Size: 2000, MaxBytes: 1024}},
+		{args: []string{"--upper=abc"}, want: argT{Timeout: time.Second, Upper: "ABC", MaxBytes: 1024}},
+		{args: []string{"--timeout=1x"}, err: "parameter --timeout invalid: `1x' couldn't converted to a duration like 2h30m"},
+		{args: []string{"--size=10XB"}, err: "parameter --size invalid: `10XB' couldn't converted to a byte size"},
+		{args: []string{"--size=-1"}, err: "parameter --size invalid: `-1' couldn't converted to a byte size"},
+		{args: []string{"--size=100000000TB"}, err: "parameter --size invalid: `100000000TB' overflows byte size"},
+		{args: []string{"--upper="}, err: "parameter --upper invalid: empty string"},
+	} {
+		v := new(argT)
+		flagSet := parseArgv(tt.args, v, clr)
+		if tt.err != "" {
+			if assert.Error(t, flagSet.err, "case %d", i) {
+				assert.Equal(t, tt.err, flagSet.err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, flagSet.err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+	assert.Panics(t, func() { RegisterTypeParser(reflect.TypeOf(ByteSize(0)), parseByteSize) })
+}
+
+type upperT string
+
+func TestTimeFlag(t *testing.T) {
+	type argT struct {
+		Since time.Time     `cli:"since"`
+		Day   time.Time     `cli:"day" format:"2006-01-02"`
+		Days  []time.Time   `cli:"d" format:"01/02"`
+		Wait  time.Duration `cli:"wait"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want argT
+		err  string
+	}{
+		{args: []string{"--since=2024-03-01T10:00:00Z", "--wait", "2h30m"}, want: argT{
+			Since: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			Wait:  2*time.Hour + 30*time.Minute,
+		}},
+		{args: []string{"--day", "2024-03-01", "-d", "01/02", "-d", "12/31"}, want: argT{
+			Day:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			Days: []time.Time{time.Date(0, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(0, 12, 31, 0, 0, 0, 0, time.UTC)},
+		}},
+		{args: []string{"--since=2024-03-01"}, err: "parameter --since invalid: `2024-03-01' couldn't converted to a time of format " + time.RFC3339},
+		{args: []string{"--day=03/01/2024"}, err: "parameter --day invalid: `03/01/2024' couldn't converted to a time of format 2006-01-02"},
+		{args: []string{"--wait=2x"}, err: "parameter --wait invalid: `2x' couldn't converted to a duration like 2h30m"},
+	} {
+		v := new(argT)
+		err := parseArgv(tt.args, v, clr).err
+		if tt.err != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.err, err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	for _, tt := range []struct {
+		size ByteSize
+		want string
+	}{
+		{0, "0B"},
+		{512, "512B"},
+		{2000, "2KB"},
+		{1024, "1KiB"},
+		{1536, "1536B"},
+		{3 * GiB, "3GiB"},
+		{5 * TB, "5TB"},
+	} {
+		assert.Equal(t, tt.want, tt.size.String())
+	}
+}
+
+type featureT uint8
+
+const (
+	featureA featureT = 1 << iota
+	featureB
+	featureC
+)
+
+func TestBitmask(t *testing.T) {
+	typ := reflect.TypeOf(featureT(0))
+	RegisterBitmask(typ, map[string]uint64{
+		"a":   uint64(featureA),
+		"b":   uint64(featureB),
+		"c":   uint64(featureC),
+		"all": uint64(featureA | featureB | featureC),
+	})
+	defer delete(typeParsers, typ)
+
+	type argT struct {
+		Features featureT   `cli:"features"`
+		Dft      featureT   `cli:"dft" dft:"a,b"`
+		List     []featureT `cli:"l"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		want argT
+		err  string
+	}{
+		{args: []string{"--features", "a,c"}, want: argT{Features: featureA | featureC, Dft: featureA | featureB}},
+		{args: []string{"--features= b , b ", "--dft=all"}, want: argT{Features: featureB, Dft: featureA | featureB | featureC}},
+		{args: []string{"--features=", "--dft", ""}, want: argT{}},
+		{args: []string{"-l", "a", "-l", "b,c"}, want: argT{Dft: featureA | featureB, List: []featureT{featureA, featureB | featureC}}},
+		{args: []string{"--features=a,d"}, err: "parameter --features invalid: unknown name `d', valid options: a|b|c|all"},
+	} {
+		v := new(argT)
+		err := parseArgv(tt.args, v, clr).err
+		if tt.err != "" {
+			if assert.Error(t, err, "case %d", i) {
+				assert.Equal(t, tt.err, err.Error(), "case %d", i)
+			}
+			continue
+		}
+		if assert.Nil(t, err, "case %d", i) {
+			assert.Equal(t, tt.want, *v, "case %d", i)
+		}
+	}
+	assert.Panics(t, func() { RegisterBitmask(reflect.TypeOf(""), nil) })
+}
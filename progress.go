@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	maxProgressBarWidth = 40
+	minProgressBarWidth = 10
+
+	// progressStep is step of percentages written if writer isn't a terminal
+	progressStep = 25
+)
+
+// ProgressBar shows progress of processing items, see Context.ProgressBar
+type ProgressBar struct {
+	ctx      *Context
+	total    int
+	current  int
+	tty      bool
+	width    int
+	reported int // the last percentage written if writer isn't a terminal
+	finished bool
+}
+
+// ProgressBar creates a progress bar of total items, e.g.
+//
+//	bar := ctx.ProgressBar(len(files))
+//	for _, file := range files {
+//		process(file)
+//		bar.Add(1)
+//	}
+//	bar.Finish()
+//
+// A bar like `[====>     ]  42%` is redrawn in place if writer of ctx is a
+// terminal, width of the bar fits width of terminal. Percentages are written
+// as lines every 25% otherwise.
+func (ctx *Context) ProgressBar(total int) *ProgressBar {
+	bar := &ProgressBar{
+		ctx:   ctx,
+		total: total,
+		tty:   ctx.IsTTY(),
+		width: maxProgressBarWidth,
+	}
+	if bar.tty {
+		cols, _, _ := ctx.TerminalSize()
+		// room for brackets and percentage, e.g. "[] 100%"
+		if cols -= len("[] 100%") + 1; cols < bar.width {
+			bar.width = cols
+		}
+		if bar.width < minProgressBarWidth {
+			bar.width = minProgressBarWidth
+		}
+		ctx.outputMu.Lock()
+		defer ctx.outputMu.Unlock()
+		bar.draw()
+	}
+	return bar
+}
+
+// Add adds n processed items, progress is kept in range [0, total]
+func (bar *ProgressBar) Add(n int) *ProgressBar {
+	bar.ctx.outputMu.Lock()
+	defer bar.ctx.outputMu.Unlock()
+	if bar.finished {
+		return bar
+	}
+	bar.current += n
+	if bar.current > bar.total {
+		bar.current = bar.total
+	}
+	if bar.current < 0 {
+		bar.current = 0
+	}
+	bar.render()
+	return bar
+}
+
+// Finish completes the progress bar, a newline is written after the bar on
+// terminal. It does nothing if finished already.
+func (bar *ProgressBar) Finish() {
+	bar.ctx.outputMu.Lock()
+	defer bar.ctx.outputMu.Unlock()
+	if bar.finished {
+		return
+	}
+	bar.finished = true
+	bar.current = bar.total
+	bar.render()
+	if bar.tty {
+		fmt.Fprintln(bar.ctx.Writer())
+		bar.ctx.Flush()
+	}
+}
+
+// percent returns percentage of progress, 100 if total isn't positive
+func (bar *ProgressBar) percent() int {
+	if bar.total <= 0 {
+		return 100
+	}
+	return bar.current * 100 / bar.total
+}
+
+// render draws the bar on terminal, or writes percentages of reached steps,
+// outputMu of ctx should be locked
+func (bar *ProgressBar) render() {
+	if bar.tty {
+		bar.draw()
+		return
+	}
+	percent := bar.percent()
+	for step := bar.reported + progressStep; step <= percent; step += progressStep {
+		fmt.Fprintf(bar.ctx.Writer(), "%d%%\n", step)
+		bar.reported = step
+	}
+	bar.ctx.Flush()
+}
+
+// draw redraws the bar in place
+func (bar *ProgressBar) draw() {
+	percent := bar.percent()
+	filled := bar.width * percent / 100
+	done := strings.Repeat("=", filled)
+	if filled > 0 && filled < bar.width {
+		done = done[:filled-1] + ">"
+	}
+	fmt.Fprintf(bar.ctx.Writer(), "\r[%s%s] %3d%%", bar.ctx.color.Green(done), strings.Repeat(" ", bar.width-filled), percent)
+	bar.ctx.Flush()
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressBarNonTTY(t *testing.T) {
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w}
+	bar := ctx.ProgressBar(8)
+	for i := 0; i < 3; i++ {
+		bar.Add(1)
+	}
+	assert.Equal(t, "25%\n", w.String())
+	bar.Add(4)
+	assert.Equal(t, "25%\n50%\n75%\n", w.String())
+	bar.Finish()
+	bar.Finish()
+	bar.Add(1)
+	assert.Equal(t, "25%\n50%\n75%\n100%\n", w.String())
+
+	// empty progress
+	w.Reset()
+	ctx.ProgressBar(0).Finish()
+	assert.Equal(t, "25%\n50%\n75%\n100%\n", w.String())
+}
+
+func TestProgressBarTTY(t *testing.T) {
+	clr := color.Color{}
+	clr.Disable()
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w, color: clr}
+	bar := &ProgressBar{ctx: ctx, total: 50, tty: true, width: 10}
+
+	bar.Add(21)
+	assert.Equal(t, "\r[===>      ]  42%", w.String())
+	w.Reset()
+	bar.Add(-100)
+	assert.Equal(t, "\r[          ]   0%", w.String())
+	w.Reset()
+	bar.Add(100)
+	assert.Equal(t, "\r[==========] 100%", w.String())
+	w.Reset()
+	bar.Finish()
+	assert.Equal(t, "\r[==========] 100%\n", w.String())
+
+	// filled part colored
+	clr.Enable()
+	w.Reset()
+	ctx = &Context{writer: w, color: clr}
+	bar = &ProgressBar{ctx: ctx, total: 2, tty: true, width: 10}
+	bar.Add(1)
+	assert.Equal(t, "\r["+clr.Green("====>")+strings.Repeat(" ", 5)+"]  50%", w.String())
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+)
+
+// outputFlag is the conventional flag of output format read by Render
+const outputFlag = "--output"
+
+var outputFormats = []string{"json", "yaml", "table", "csv"}
+
+// Render writes obj to writer in format specified by flag `-o`/`--output`,
+// which could be declared by embedding Outputer, e.g.
+//
+//	type argT struct {
+//		cli.Helper
+//		cli.Outputer
+//	}
+//
+// Formats json, yaml, table and csv are written by JSONIndent, YAMLE,
+// StructTableE and CSVE, and json used if the flag absent or empty. A
+// struct is rendered as a table or CSV of one row.
+func (ctx *Context) Render(obj interface{}) error {
+	format := "json"
+	if fl, ok := ctx.lookupFlag(outputFlag); ok && fl.value.Kind() == reflect.String {
+		if s := strings.TrimSpace(fl.value.String()); s != "" {
+			format = s
+		}
+	}
+	switch strings.ToLower(format) {
+	case "json":
+		if err := ctx.JSONIndentTo(ctx, obj, "", "  "); err != nil {
+			return err
+		}
+		_, err := ctx.Write([]byte("\n"))
+		return err
+	case "yaml":
+		return ctx.YAMLE(obj)
+	case "table":
+		return ctx.StructTableE(renderRows(obj))
+	case "csv":
+		return ctx.CSVE(renderRows(obj))
+	}
+	return outputFormatError{format: format}
+}
+
+// renderRows wraps obj as a slice of one element if it's a struct or
+// pointer to struct
+func renderRows(obj interface{}) interface{} {
+	val := reflect.ValueOf(obj)
+	if elem := indirectValue(val); elem.IsValid() && elem.Kind() == reflect.Struct {
+		rows := reflect.MakeSlice(reflect.SliceOf(val.Type()), 1, 1)
+		rows.Index(0).Set(val)
+		return rows.Interface()
+	}
+	return obj
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextRender(t *testing.T) {
+	type argT struct {
+		Outputer
+	}
+	type userT struct {
+		Name string `csv:"name" table:"Name"`
+		Age  int    `csv:"age" table:"Age"`
+	}
+	defer func(m func(interface{}) ([]byte, error)) { YAMLMarshaler = m }(YAMLMarshaler)
+	YAMLMarshaler = func(obj interface{}) ([]byte, error) {
+		return json.Marshal(obj)
+	}
+	users := []userT{{"Tom", 10}, {"Jim", 8}}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		args []string
+		obj  interface{}
+		want string
+	}{
+		{nil, users, "[\n  {\n    \"Name\": \"Tom\",\n    \"Age\": 10\n  },\n  {\n    \"Name\": \"Jim\",\n    \"Age\": 8\n  }\n]\n"},
+		{[]string{"-o", "json"}, users[0], "{\n  \"Name\": \"Tom\",\n  \"Age\": 10\n}\n"},
+		{[]string{"--output=yaml"}, users[0], `{"Name":"Tom","Age":10}`},
+		{[]string{"-o", "csv"}, users, "name,age\nTom,10\nJim,8\n"},
+		{[]string{"-o", "CSV"}, &users[1], "name,age\nJim,8\n"},
+		{[]string{"-o", "table"}, users, "+------+-----+\n| Name | Age |\n+------+-----+\n| Tom  | 10  |\n| Jim  | 8   |\n+------+-----+\n"},
+	} {
+		ctx, err := newContext("", nil, tt.args, []interface{}{new(argT)}, clr)
+		assert.Nil(t, err, "case %d", i)
+		w := bytes.NewBufferString("")
+		ctx.writer = w
+		assert.Nil(t, ctx.Render(tt.obj), "case %d", i)
+		assert.Equal(t, tt.want, w.String(), "case %d", i)
+	}
+
+	// no output flag
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w, color: clr}
+	assert.Nil(t, ctx.Render(users[1]))
+	assert.Equal(t, "{\n  \"Name\": \"Jim\",\n  \"Age\": 8\n}\n", w.String())
+
+	// unknown format
+	ctx, err := newContext("", nil, []string{"-o", "xml"}, []interface{}{new(argT)}, clr)
+	assert.Nil(t, err)
+	ctx.writer = bytes.NewBufferString("")
+	err = ctx.Render(users)
+	if assert.Error(t, err) {
+		assert.Equal(t, "unknown output format `xml', supported: json|yaml|table|csv", err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"time"
+)
+
+// MaxRetryDelay is max delay between attempts of RetryWithBackoff
+var MaxRetryDelay = time.Minute
+
+// Retry calls fn until it succeeds or attempts exhausted without delay,
+// see RetryWithBackoff
+func (ctx *Context) Retry(attempts int, fn func() error) error {
+	return ctx.RetryWithBackoff(attempts, 0, fn)
+}
+
+// RetryWithBackoff calls fn until it succeeds or attempts exhausted, the
+// last error of fn returned after exhaustion. fn is called once at least even
+// if attempts isn't positive. Delay before the nth retry is base*2^(n-1),
+// e.g. 100ms, 200ms, 400ms for base 100ms, and at most MaxRetryDelay. Error
+// of GoContext returned if it's canceled before an attempt or while waiting.
+// Retries are logged at LevelDebug.
+func (ctx *Context) RetryWithBackoff(attempts int, base time.Duration, fn func() error) error {
+	goCtx := ctx.GoContext()
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			delay := retryDelay(base, i)
+			ctx.Logf(LevelDebug, "attempt %d/%d failed: %v, retrying in %v", i, attempts, err, delay)
+			timer := time.NewTimer(delay)
+			select {
+			case <-goCtx.Done():
+				timer.Stop()
+				return goCtx.Err()
+			case <-timer.C:
+			}
+		} else if goCtx.Err() != nil {
+			return goCtx.Err()
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// retryDelay returns delay before the nth retry, it's base*2^(n-1) and
+// capped by MaxRetryDelay without overflow
+func retryDelay(base time.Duration, n int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := base
+	for i := 1; i < n && delay < MaxRetryDelay; i++ {
+		delay <<= 1
+	}
+	if delay > MaxRetryDelay {
+		delay = MaxRetryDelay
+	}
+	return delay
+}
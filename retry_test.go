@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextRetry(t *testing.T) {
+	clr := color.Color{}
+	clr.Disable()
+
+	// success on second try
+	errW := bytes.NewBufferString("")
+	ctx := (&Context{errWriter: errW, color: clr}).SetLogLevel(LevelDebug)
+	calls := 0
+	err := ctx.Retry(3, func() error {
+		if calls++; calls < 2 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "[DEBUG] attempt 1/3 failed: flaky, retrying in 0s\n", errW.String())
+
+	// exhaustion returns the last error
+	calls = 0
+	start := time.Now()
+	err = (&Context{color: clr}).RetryWithBackoff(3, time.Millisecond, func() error {
+		calls++
+		return fmt.Errorf("failure %d", calls)
+	})
+	if assert.Error(t, err) {
+		assert.Equal(t, "failure 3", err.Error())
+	}
+	assert.Equal(t, 3, calls)
+	assert.True(t, time.Since(start) >= 3*time.Millisecond)
+
+	// canceled while waiting
+	goCtx, cancel := context.WithCancel(context.Background())
+	ctx = (&Context{color: clr}).WithGoContext(goCtx)
+	calls = 0
+	err = ctx.RetryWithBackoff(5, time.Hour, func() error {
+		calls++
+		cancel()
+		return errors.New("failure")
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, calls)
+
+	// canceled before the first attempt
+	calls = 0
+	assert.Equal(t, context.Canceled, ctx.Retry(3, func() error {
+		calls++
+		return nil
+	}))
+	assert.Equal(t, 0, calls)
+
+	// called once at least
+	for _, attempts := range []int{0, -1} {
+		calls = 0
+		err = (&Context{color: clr}).Retry(attempts, func() error {
+			calls++
+			return errors.New("failure")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for i, tt := range []struct {
+		base time.Duration
+		n    int
+		want time.Duration
+	}{
+		{100 * time.Millisecond, 1, 100 * time.Millisecond},
+		{100 * time.Millisecond, 3, 400 * time.Millisecond},
+		{time.Second, 100, MaxRetryDelay},
+		{time.Hour, 1, MaxRetryDelay},
+		{0, 10, 0},
+		{-time.Second, 10, 0},
+	} {
+		assert.Equal(t, tt.want, retryDelay(tt.base, tt.n), "case %d", i)
+	}
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// FlagsJSONSchema returns JSON schema of flags of the command, including
+// flags of global ancestors and inherited persistent flags. Each flag is a
+// property named by it's first name without dashes, e.g.
+//
+//	{
+//		"type": "integer",
+//		"description": "listening port",
+//		"default": 8080,
+//		"x-flags": ["-p", "--port"]
+//	}
+//
+// Global flags of root are included too.
+// Tag `usage`, `dft`, `choices`, `min`, `max` and `deprecated` are documented,
+// required flags listed in `required`. Struct fields without `cli` tag are
+// nested objects, unless they are embedded.
+func (cmd *Command) FlagsJSONSchema() ([]byte, error) {
+	schema := newJSONSchemaObject()
+	schema["$schema"] = jsonSchemaDraft
+	if cmd.Name != "" {
+		schema["title"] = cmd.Name
+	}
+	if cmd.Desc != "" {
+		schema["description"] = cmd.Desc
+	}
+	argvList := withGlobalArgv(cmd.argvList(), cmd.globalArgv())
+	add := func(argv interface{}, persistentOnly bool) error {
+		if argv == nil {
+			return nil
+		}
+		typ := reflect.TypeOf(argv)
+		if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+			return errNotAPointerToStruct
+		}
+		return buildJSONSchema(typ.Elem(), schema, persistentOnly)
+	}
+	for i := len(argvList) - 1; i >= 0; i-- {
+		if err := add(argvList[i], false); err != nil {
+			return nil, err
+		}
+	}
+	for _, argv := range cmd.persistentArgvList() {
+		if err := add(argv, true); err != nil {
+			return nil, err
+		}
+	}
+	finishJSONSchemaObject(schema)
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func newJSONSchemaObject() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+// finishJSONSchemaObject removes empty `required` of obj
+func finishJSONSchemaObject(obj map[string]interface{}) {
+	if required, ok := obj["required"].([]string); ok && len(required) == 0 {
+		delete(obj, "required")
+	}
+}
+
+// buildJSONSchema adds flags of struct typ to properties of obj
+func buildJSONSchema(typ reflect.Type, obj map[string]interface{}, persistentOnly bool) error {
+	props := obj["properties"].(map[string]interface{})
+	required, _ := obj["required"].([]string)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag, isEmpty, err := parseTag(field.Name, field.Tag)
+		if err != nil {
+			return err
+		}
+		if tag == nil {
+			continue
+		}
+		if isEmpty && field.Type.Kind() == reflect.Struct {
+			if field.Anonymous {
+				obj["required"] = required
+				if err := buildJSONSchema(field.Type, obj, persistentOnly); err != nil {
+					return err
+				}
+				required, _ = obj["required"].([]string)
+				continue
+			}
+			sub := newJSONSchemaObject()
+			if err := buildJSONSchema(field.Type, sub, persistentOnly); err != nil {
+				return err
+			}
+			if len(sub["properties"].(map[string]interface{})) > 0 {
+				finishJSONSchemaObject(sub)
+				props[field.Name] = sub
+			}
+			continue
+		}
+		if tag.isHidden || tag.position != nil || (persistentOnly && !tag.isPersistent) {
+			continue
+		}
+		prop, err := jsonSchemaProperty(field, tag)
+		if err != nil {
+			return err
+		}
+		names := append(append([]string{}, tag.shortNames...), tag.longNames...)
+		name := strings.TrimLeft(names[0], dashOne)
+		if len(tag.longNames) > 0 {
+			name = strings.TrimLeft(tag.longNames[0], dashOne)
+		}
+		if _, ok := props[name]; ok {
+			continue
+		}
+		props[name] = prop
+		if tag.isRequired {
+			required = append(required, name)
+		}
+	}
+	obj["required"] = required
+	return nil
+}
+
+// jsonSchemaProperty creates schema of flag
+func jsonSchemaProperty(field reflect.StructField, tag *tagProperty) (map[string]interface{}, error) {
+	prop := jsonSchemaType(field.Type)
+	if tag.parserCreator != nil {
+		prop = map[string]interface{}{"type": "string"}
+	}
+	if tag.usage != "" {
+		prop["description"] = tag.usage
+	}
+	if tag.dft != "" {
+		val, err := defaultValueOf(field, tag)
+		if err != nil {
+			return nil, err
+		}
+		intf := reflect.Indirect(val).Interface()
+		if encoder, ok := intf.(Encoder); ok {
+			prop["default"] = encoder.Encode()
+		} else if prop["type"] == "string" {
+			prop["default"] = fmt.Sprint(intf)
+		} else {
+			prop["default"] = intf
+		}
+	}
+	if len(tag.choices) > 0 {
+		prop["enum"] = tag.choices
+	}
+	if tag.min != nil {
+		prop["minimum"] = *tag.min
+	}
+	if tag.max != nil {
+		prop["maximum"] = *tag.max
+	}
+	if tag.deprecated != "" {
+		prop["deprecated"] = true
+	}
+	prop["x-flags"] = append(append([]string{}, tag.shortNames...), tag.longNames...)
+	return prop, nil
+}
+
+// jsonSchemaType returns schema of values of typ, types parsed from string
+// by decoders or type parsers are strings
+func jsonSchemaType(typ reflect.Type) map[string]interface{} {
+	decoderType := reflect.TypeOf((*Decoder)(nil)).Elem()
+	if _, ok := typeParsers[typ]; ok || typ.Implements(decoderType) || reflect.PtrTo(typ).Implements(decoderType) {
+		return map[string]interface{}{"type": "string"}
+	}
+	if typ == timeType {
+		return map[string]interface{}{"type": "string"}
+	}
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return jsonSchemaType(typ.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaType(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaType(typ.Elem())}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagsJSONSchema(t *testing.T) {
+	type dbT struct {
+		Host string `cli:"db-host" usage:"database host" dft:"localhost"`
+		Port int    `cli:"db-port" usage:"database port" dft:"5432"`
+	}
+	type rootT struct {
+		DryRun bool `cli:"dry-run" usage:"print actions only" persistent:"true"`
+	}
+	type serveT struct {
+		Helper
+		Name    string            `cli:"*n,name" usage:"service name"`
+		Port    int               `cli:"p,port" usage:"listening port" dft:"8080" min:"1" max:"65535"`
+		Format  string            `cli:"format" usage:"output format" dft:"json" choices:"json|yaml"`
+		Tags    []string          `cli:"t,tag" usage:"tags"`
+		Labels  map[string]string `cli:"L" usage:"labels"`
+		Timeout time.Duration     `cli:"timeout" usage:"request timeout" dft:"30s"`
+		Limit   ByteSize          `cli:"limit" dft:"1MiB"`
+		Ratio   float64           `cli:"ratio" dft:"0.5"`
+		Old     string            `cli:"old" deprecated:"use --name instead"`
+		Debug   bool              `cli:"debug" hidden:"true"`
+		DB      dbT
+	}
+	root := &Command{Name: "app", Argv: func() interface{} { return new(rootT) }}
+	serve := root.Register(&Command{
+		Name: "serve",
+		Desc: "start service",
+		Argv: func() interface{} { return new(serveT) },
+	})
+	data, err := serve.FlagsJSONSchema()
+	require.Nil(t, err)
+	want, err := ioutil.ReadFile("testdata/flags_schema.golden")
+	require.Nil(t, err)
+	assert.Equal(t, string(want), string(data))
+
+	// invalid tag
+	type invalidT struct {
+		A int `cli:"a" min:"x"`
+	}
+	_, err = (&Command{Argv: func() interface{} { return new(invalidT) }}).FlagsJSONSchema()
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+)
+
+// spinnerFrames are drawn in turn while spinner running on terminal
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const (
+	spinnerInterval = 100 * time.Millisecond
+
+	// clearLine moves cursor to beginning of line and erases the line
+	clearLine = "\r\033[K"
+)
+
+// Spinner shows an animated spinner followed by label while running on
+// terminal, see Context.Spinner
+type Spinner struct {
+	ctx     *Context
+	label   string
+	tty     bool
+	frame   int
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Spinner creates a spinner for long operations, e.g.
+//
+//	spinner := ctx.Spinner("downloading").Start()
+//	defer spinner.Stop()
+//
+// The spinner is animated only if writer of ctx is a terminal, otherwise
+// label is written as a line without animation. Output written by
+// Context.String and Context.Write while spinner running isn't corrupted,
+// the spinner is redrawn below the output.
+func (ctx *Context) Spinner(label string) *Spinner {
+	return &Spinner{
+		ctx:   ctx,
+		label: label,
+		tty:   ctx.IsTTY(),
+	}
+}
+
+// Start starts the spinner, it does nothing if the spinner is running
+func (s *Spinner) Start() *Spinner {
+	s.ctx.outputMu.Lock()
+	defer s.ctx.outputMu.Unlock()
+	if s.running {
+		return s
+	}
+	s.running = true
+	if !s.tty {
+		fmt.Fprintln(s.ctx.Writer(), s.label)
+		s.ctx.Flush()
+		return s
+	}
+	s.ctx.spinner = s
+	s.draw()
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.loop()
+	return s
+}
+
+// Update replaces label of the spinner, new label is written as a line if
+// writer isn't a terminal
+func (s *Spinner) Update(label string) *Spinner {
+	s.ctx.outputMu.Lock()
+	defer s.ctx.outputMu.Unlock()
+	s.label = label
+	if !s.running {
+		return s
+	}
+	if !s.tty {
+		fmt.Fprintln(s.ctx.Writer(), label)
+		s.ctx.Flush()
+		return s
+	}
+	s.draw()
+	return s
+}
+
+// Stop stops the spinner and clears line of the spinner
+func (s *Spinner) Stop() {
+	s.ctx.outputMu.Lock()
+	if !s.running {
+		s.ctx.outputMu.Unlock()
+		return
+	}
+	s.running = false
+	if !s.tty {
+		s.ctx.outputMu.Unlock()
+		return
+	}
+	close(s.stop)
+	s.ctx.outputMu.Unlock()
+	<-s.done
+
+	s.ctx.outputMu.Lock()
+	defer s.ctx.outputMu.Unlock()
+	s.ctx.spinner = nil
+	fmt.Fprint(s.ctx.Writer(), clearLine)
+	s.ctx.Flush()
+}
+
+func (s *Spinner) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.ctx.outputMu.Lock()
+			s.frame = (s.frame + 1) % len(spinnerFrames)
+			s.draw()
+			s.ctx.outputMu.Unlock()
+		}
+	}
+}
+
+// draw draws current frame and label, outputMu of ctx should be locked
+func (s *Spinner) draw() {
+	fmt.Fprint(s.ctx.Writer(), clearLine+s.ctx.color.Cyan(spinnerFrames[s.frame])+" "+s.label)
+	s.ctx.Flush()
+}
+
+// clearSpinner clears line of running spinner before output written,
+// outputMu of ctx should be locked
+func (ctx *Context) clearSpinner() {
+	if ctx.spinner != nil {
+		fmt.Fprint(ctx.Writer(), clearLine)
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpinnerNonTTY(t *testing.T) {
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w}
+	spinner := ctx.Spinner("downloading")
+	spinner.Update("preparing")
+	spinner.Start().Start()
+	ctx.String("progress\n")
+	spinner.Update("extracting")
+	spinner.Stop()
+	spinner.Stop()
+	assert.Equal(t, "preparing\nprogress\nextracting\n", w.String())
+}
+
+func TestSpinnerTTY(t *testing.T) {
+	clr := color.Color{}
+	clr.Disable()
+	w := bytes.NewBufferString("")
+	ctx := &Context{writer: w, color: clr}
+	spinner := ctx.Spinner("downloading")
+	spinner.tty = true
+	spinner.Start()
+	ctx.String("log line\n")
+	// output helpers clear line of spinner too
+	assert.Nil(t, ctx.JSONlnE(1))
+	assert.Nil(t, ctx.CSVE([]struct{ A int }{{2}}))
+	spinner.Update("extracting")
+	spinner.Stop()
+
+	out := w.String()
+	assert.True(t, strings.HasPrefix(out, clearLine+spinnerFrames[0]+" downloading"+clearLine+"log line\n"+clearLine+"1\n"+clearLine+"A\n2\n"), out)
+	assert.Contains(t, out, " extracting")
+	// Stop clears the line
+	assert.True(t, strings.HasSuffix(out, clearLine), out)
+	assert.Nil(t, ctx.spinner)
+
+	// output after stopped isn't cleared
+	w.Reset()
+	ctx.String("done")
+	assert.Equal(t, "done", w.String())
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+)
+
+// OpenInput opens file named by value of string flag for reading, e.g.
+// `--input data.txt`. Value `-` means Stdin of ctx, which isn't closed by
+// Close of the returned reader, and an error returned if Stdin is nil.
+func (ctx *Context) OpenInput(flag string) (io.ReadCloser, error) {
+	filename, err := ctx.filenameOf(flag)
+	if err != nil {
+		return nil, err
+	}
+	if filename == dashOne {
+		stdin := ctx.Stdin()
+		if stdin == nil {
+			return nil, errStdinNotAvailable
+		}
+		return ioutil.NopCloser(stdin), nil
+	}
+	return os.Open(filename)
+}
+
+// OpenOutput creates or truncates file named by value of string flag for
+// writing, e.g. `--output result.txt`. Value `-` means writer of ctx, which
+// is flushed rather than closed by Close of the returned writer.
+func (ctx *Context) OpenOutput(flag string) (io.WriteCloser, error) {
+	filename, err := ctx.filenameOf(flag)
+	if err != nil {
+		return nil, err
+	}
+	if filename == dashOne {
+		return stdoutCloser{ctx}, nil
+	}
+	return os.Create(filename)
+}
+
+// filenameOf returns value of string flag, an error returned if the flag
+// undefined, not a string flag or empty
+func (ctx *Context) filenameOf(name string) (string, error) {
+	fl, ok := ctx.lookupFlag(name)
+	if !ok {
+		return "", UnknownFlagError{Flag: name, clr: ctx.color}
+	}
+	val := reflect.Indirect(fl.value)
+	if val.Kind() != reflect.String {
+		return "", fmt.Errorf("parameter %s isn't a string flag", ctx.color.Bold(fl.name()))
+	}
+	if val.String() == "" {
+		return "", MissingValueError{Flag: fl.name(), clr: ctx.color}
+	}
+	return val.String(), nil
+}
+
+// stdoutCloser writes to writer of ctx, and flushes it while closed
+type stdoutCloser struct {
+	ctx *Context
+}
+
+func (w stdoutCloser) Write(data []byte) (int, error) { return w.ctx.Write(data) }
+func (w stdoutCloser) Close() error                   { return w.ctx.Flush() }
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextOpenFile(t *testing.T) {
+	type argT struct {
+		Input  string `cli:"i,input"`
+		Output string `cli:"o,output"`
+		N      int    `cli:"n"`
+	}
+	clr := color.Color{}
+	clr.Disable()
+	dir, err := ioutil.TempDir("", "cli-stdio")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	newCtx := func(args ...string) (*Context, *bytes.Buffer) {
+		ctx, err := newContext("", nil, args, []interface{}{new(argT)}, clr)
+		assert.Nil(t, err)
+		w := bytes.NewBufferString("")
+		ctx.writer = w
+		ctx.reader = strings.NewReader("from stdin")
+		return ctx, w
+	}
+
+	// `-` means stdio of ctx
+	ctx, w := newCtx("-i", "-", "--output=-")
+	r, err := ctx.OpenInput("input")
+	if assert.Nil(t, err) {
+		data, _ := ioutil.ReadAll(r)
+		assert.Equal(t, "from stdin", string(data))
+		assert.Nil(t, r.Close())
+	}
+	out, err := ctx.OpenOutput("-o")
+	if assert.Nil(t, err) {
+		io.WriteString(out, "to stdout")
+		assert.Nil(t, out.Close())
+		assert.Equal(t, "to stdout", w.String())
+	}
+
+	// real files
+	filename := filepath.Join(dir, "out.txt")
+	ctx, w = newCtx("-i", filename, "-o", filename)
+	out, err = ctx.OpenOutput("--output")
+	if assert.Nil(t, err) {
+		io.WriteString(out, "content")
+		assert.Nil(t, out.Close())
+	}
+	r, err = ctx.OpenInput("-i")
+	if assert.Nil(t, err) {
+		data, _ := ioutil.ReadAll(r)
+		assert.Equal(t, "content", string(data))
+		assert.Nil(t, r.Close())
+	}
+	assert.Equal(t, "", w.String())
+
+	// errors
+	ctx, _ = newCtx("-i", filepath.Join(dir, "not-exist"), "-o", filepath.Join(dir, "no-dir", "out"), "-n", "1")
+	_, err = ctx.OpenInput("input")
+	assert.True(t, os.IsNotExist(err))
+	_, err = ctx.OpenOutput("output")
+	assert.Error(t, err)
+	_, err = ctx.OpenInput("n")
+	if assert.Error(t, err) {
+		assert.Equal(t, "parameter -n isn't a string flag", err.Error())
+	}
+	_, err = ctx.OpenInput("file")
+	if assert.Error(t, err) {
+		assert.Equal(t, "undefined option file", err.Error())
+	}
+	ctx, _ = newCtx()
+	_, err = ctx.OpenInput("input")
+	if assert.Error(t, err) {
+		assert.Equal(t, "parameter --input invalid: missing value", err.Error())
+	}
+
+	// stdin not available
+	defer func(r io.Reader) { PromptReader = r }(PromptReader)
+	PromptReader = nil
+	ctx, _ = newCtx("-i", "-")
+	ctx.reader = nil
+	_, err = ctx.OpenInput("input")
+	assert.Equal(t, errStdinNotAvailable, err)
+}
@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// TableMaxCellWidth is the max width of cell used by Context.Table,
+// longer cell would be wrapped. 0 means cells are wrapped to fit width of
+// terminal if writer is a terminal, and no limit otherwise.
+var TableMaxCellWidth = 0
+
+// Table writes an aligned and bordered table to writer, e.g.
+//
+//	+------+-----+
+//	| Name | Age |
+//	+------+-----+
+//	| Tom  | 10  |
+//	+------+-----+
+func (ctx *Context) Table(headers []string, rows [][]string) *Context {
+	clr := ctx.Color()
+	bold := func(s string) string { return clr.Bold(s) }
+	maxWidth := TableMaxCellWidth
+	if maxWidth == 0 && ctx.IsTTY() {
+		cols, _, _ := ctx.TerminalSize()
+		maxWidth = fitCellWidth(headers, rows, cols)
+	}
+	ctx.String("%s", renderTable(headers, rows, maxWidth, bold))
+	return ctx
+}
+
+// StructTableE writes rows as a table like Table, rows should be a slice of
+// structs or pointers to structs. Headers are names of fields, or specified
+// by tag `table`, e.g.
+//
+//	type T struct {
+//		Name   string `table:"User Name"`
+//		Age    int
+//		Secret string `table:"-"` // omitted
+//	}
+//
+// Unexported fields are skipped and fields of embedded structs flattened.
+func (ctx *Context) StructTableE(rows interface{}) error {
+	headers, records, err := structTableRecords(rows)
+	if err != nil {
+		return err
+	}
+	ctx.Table(headers, records)
+	return nil
+}
+
+// StructTable writes rows as a table, see StructTableE
+func (ctx *Context) StructTable(rows interface{}) *Context {
+	ctx.StructTableE(rows)
+	return ctx
+}
+
+// structTableRecords converts slice of structs to headers and rows of table
+func structTableRecords(rows interface{}) ([]string, [][]string, error) {
+	val := reflect.ValueOf(rows)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, nil, fmt.Errorf("unsupported type %T for table, want a slice of structs", rows)
+	}
+	typ := val.Type().Elem()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("unsupported element type %s for table, want struct", typ)
+	}
+	fields := structFields(typ, "table", nil)
+	headers := make([]string, 0, len(fields))
+	for _, f := range fields {
+		headers = append(headers, f.name)
+	}
+	records := make([][]string, 0, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		elem := indirectValue(val.Index(i))
+		record := make([]string, len(fields))
+		if elem.IsValid() {
+			for j, f := range fields {
+				record[j] = csvFieldValue(elem, f.index)
+			}
+		}
+		records = append(records, record)
+	}
+	return headers, records, nil
+}
+
+func renderTable(headers []string, rows [][]string, maxWidth int, styleHeader func(string) string) string {
+	ncol := len(headers)
+	for _, row := range rows {
+		if len(row) > ncol {
+			ncol = len(row)
+		}
+	}
+	if ncol == 0 {
+		return ""
+	}
+
+	// split cells to lines and compute widths of columns
+	widths := make([]int, ncol)
+	split := func(row []string) [][]string {
+		cells := make([][]string, ncol)
+		for i := range cells {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			cells[i] = wrapCell(cell, maxWidth)
+			for _, line := range cells[i] {
+				if w := utf8.RuneCountInString(line); w > widths[i] {
+					widths[i] = w
+				}
+			}
+		}
+		return cells
+	}
+	var header [][]string
+	if len(headers) > 0 {
+		header = split(headers)
+	}
+	body := make([][][]string, 0, len(rows))
+	for _, row := range rows {
+		body = append(body, split(row))
+	}
+
+	buff := bytes.NewBufferString("")
+	border := func() {
+		buff.WriteByte('+')
+		for _, w := range widths {
+			buff.WriteString(strings.Repeat("-", w+2))
+			buff.WriteByte('+')
+		}
+		buff.WriteByte('\n')
+	}
+	writeRow := func(cells [][]string, style func(string) string) {
+		height := 1
+		for _, lines := range cells {
+			if len(lines) > height {
+				height = len(lines)
+			}
+		}
+		for l := 0; l < height; l++ {
+			buff.WriteByte('|')
+			for i, lines := range cells {
+				line := ""
+				if l < len(lines) {
+					line = lines[l]
+				}
+				padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(line))
+				if style != nil && line != "" {
+					line = style(line)
+				}
+				buff.WriteString(" " + line + padding + " |")
+			}
+			buff.WriteByte('\n')
+		}
+	}
+
+	border()
+	if header != nil {
+		writeRow(header, styleHeader)
+		border()
+	}
+	if len(body) > 0 {
+		for _, cells := range body {
+			writeRow(cells, nil)
+		}
+		border()
+	}
+	return buff.String()
+}
+
+// fitCellWidth returns max width of cell which makes table fit in width
+// columns, 0 returned if table fits without wrapping
+func fitCellWidth(headers []string, rows [][]string, width int) int {
+	var widths []int
+	measure := func(row []string) {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			for _, line := range strings.Split(cell, "\n") {
+				if w := utf8.RuneCountInString(line); w > widths[i] {
+					widths[i] = w
+				}
+			}
+		}
+	}
+	measure(headers)
+	for _, row := range rows {
+		measure(row)
+	}
+	// borders and paddings take 3 columns for each column and 1 more
+	available := width - 3*len(widths) - 1
+	total, max := 0, 0
+	for _, w := range widths {
+		total += w
+		if w > max {
+			max = w
+		}
+	}
+	if total <= available {
+		return 0
+	}
+	for limit := max - 1; limit > 1; limit-- {
+		total = 0
+		for _, w := range widths {
+			if w > limit {
+				w = limit
+			}
+			total += w
+		}
+		if total <= available {
+			return limit
+		}
+	}
+	return 1
+}
+
+// wrapCell splits cell into lines, each line not longer than maxWidth if maxWidth > 0
+func wrapCell(cell string, maxWidth int) []string {
+	lines := []string{}
+	for _, line := range strings.Split(cell, "\n") {
+		if maxWidth <= 0 || utf8.RuneCountInString(line) <= maxWidth {
+			lines = append(lines, line)
+			continue
+		}
+		current := ""
+		for _, word := range strings.Fields(line) {
+			for utf8.RuneCountInString(word) > maxWidth {
+				if current != "" {
+					lines = append(lines, current)
+					current = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:maxWidth]))
+				word = string(runes[maxWidth:])
+			}
+			if current == "" {
+				current = word
+			} else if utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) <= maxWidth {
+				current += " " + word
+			} else {
+				lines = append(lines, current)
+				current = word
+			}
+		}
+		if current != "" {
+			lines = append(lines, current)
+		}
+	}
+	return lines
+}
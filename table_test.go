@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	for i, tt := range []struct {
+		headers  []string
+		rows     [][]string
+		maxWidth int
+		want     string
+	}{
+		{
+			headers: []string{"Name", "Age"},
+			rows:    [][]string{{"Tom", "10"}, {"Jerry", "8"}},
+			want: `+-------+-----+
+| Name  | Age |
++-------+-----+
+| Tom   | 10  |
+| Jerry | 8   |
++-------+-----+
+`,
+		},
+		// uneven columns and empty rows
+		{
+			headers: []string{"A"},
+			rows:    [][]string{{"1", "22", "333"}, {}, {"x"}},
+			want: `+---+----+-----+
+| A |    |     |
++---+----+-----+
+| 1 | 22 | 333 |
+|   |    |     |
+| x |    |     |
++---+----+-----+
+`,
+		},
+		// no rows
+		{
+			headers: []string{"Key", "Value"},
+			want: `+-----+-------+
+| Key | Value |
++-----+-------+
+`,
+		},
+		// multi-line and wrapped cells
+		{
+			headers:  []string{"ID", "Desc"},
+			rows:     [][]string{{"1", "hello world foo\nbar"}, {"2", "abcdefghij"}},
+			maxWidth: 6,
+			want: `+----+--------+
+| ID | Desc   |
++----+--------+
+| 1  | hello  |
+|    | world  |
+|    | foo    |
+|    | bar    |
+| 2  | abcdef |
+|    | ghij   |
++----+--------+
+`,
+		},
+		{want: ""},
+	} {
+		assert.Equal(t, tt.want, renderTable(tt.headers, tt.rows, tt.maxWidth, nil), "case %d", i)
+	}
+}
+
+func TestFitCellWidth(t *testing.T) {
+	headers := []string{"ID", "Desc"}
+	rows := [][]string{{"1", "hello world foo"}, {"2", "bar"}}
+	for i, tt := range []struct {
+		width int
+		want  int
+	}{
+		{80, 0},
+		// 2+15 columns of content and 7 of borders
+		{24, 0},
+		{23, 14},
+		{14, 5},
+		{5, 1},
+	} {
+		assert.Equal(t, tt.want, fitCellWidth(headers, rows, tt.width), "case %d", i)
+	}
+	assert.Equal(t, 0, fitCellWidth(nil, nil, 10))
+}
+
+func TestContextTable(t *testing.T) {
+	w := bytes.NewBufferString("")
+	clr := color.Color{}
+	clr.Disable()
+	ctx := &Context{writer: w, color: clr}
+	ctx.Table([]string{"A", "B"}, [][]string{{"xyz", "y"}})
+	assert.Equal(t, `+-----+---+
+| A   | B |
++-----+---+
+| xyz | y |
++-----+---+
+`, w.String())
+}
+
+func TestContextStructTable(t *testing.T) {
+	type Base struct {
+		ID int `table:"ID"`
+	}
+	type T struct {
+		Base
+		Name   string `table:"User Name"`
+		Age    int
+		Secret string `table:"-"`
+		note   string
+	}
+	clr := color.Color{}
+	clr.Disable()
+	for i, tt := range []struct {
+		rows interface{}
+		want string
+	}{
+		{
+			rows: []T{{Base{1}, "Tom", 10, "x", "y"}, {Base{2}, "Jerry", 8, "", ""}},
+			want: `+----+-----------+-----+
+| ID | User Name | Age |
++----+-----------+-----+
+| 1  | Tom       | 10  |
+| 2  | Jerry     | 8   |
++----+-----------+-----+
+`,
+		},
+		// pointers, nil row is empty
+		{
+			rows: []*T{{Name: "Tom"}, nil},
+			want: `+----+-----------+-----+
+| ID | User Name | Age |
++----+-----------+-----+
+| 0  | Tom       | 0   |
+|    |           |     |
++----+-----------+-----+
+`,
+		},
+		// empty slice
+		{
+			rows: []T{},
+			want: `+----+-----------+-----+
+| ID | User Name | Age |
++----+-----------+-----+
+`,
+		},
+	} {
+		w := bytes.NewBufferString("")
+		ctx := &Context{writer: w, color: clr}
+		assert.Nil(t, ctx.StructTableE(tt.rows), "case %d", i)
+		assert.Equal(t, tt.want, w.String(), "case %d", i)
+	}
+
+	ctx := &Context{writer: bytes.NewBufferString(""), color: clr}
+	assert.Error(t, ctx.StructTableE(T{}))
+	assert.Error(t, ctx.StructTableE([]int{1}))
+}
@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 const (
@@ -15,14 +18,57 @@ const (
 	tagName   = "name"
 	tagPrompt = "prompt"
 	tagParser = "parser"
-	tagSep    = "sep" // used to seperate key/value pair of map, default is `=`
+	tagSep    = "sep" // used to seperate key/value pair of map, default is `=`, or elements of slice
+	tagEnv    = "env" // comma-separated environment variables as fallback of flag
+
+	tagRequired = "required" // `required:"true"` is equivalent to prefix `*` of cli tag
+
+	tagChoices   = "choices"    // `|`-separated allowed values of flag
+	tagChoicesCI = "choices_ci" // whether choices are case insensitive
+
+	tagMutex         = "mutex"          // `mutex:"output"` allows at most one flag of group `output` set
+	tagMutexRequired = "mutex_required" // `mutex_required:"true"` requires exactly one flag of the group
+
+	tagCount = "count" // `count:"true"` makes an integer flag increase while occurred
+
+	tagFromFile    = "fromfile" // `fromfile:"true"` allows reading value from file by `@filename`
+	fromFilePrefix = "@"        // `@-` reads value from stdin
+
+	tagHidden = "hidden" // `hidden:"true"` omits flag from usage and completion
+
+	tagPersistent = "persistent" // `persistent:"true"` makes flag inherited by sub-commands
+
+	tagSecret  = "secret" // `secret:"true"` masks value of flag while rendered, implied by `pw`
+	secretMask = "****"
+
+	tagDeprecated = "deprecated" // `deprecated:"use --new instead"` warns while flag used
+
+	tagMin = "min" // `min:"1"` is the minimum value of number flag
+	tagMax = "max" // `max:"16"` is the maximum value of number flag
+
+	tagFormat = "format" // `format:"2006-01-02"` is layout of time.Time flag, default is time.RFC3339
+
+	tagNormalize = "normalize" // `normalize:"trim,lower"` normalizes raw value by registered normalizers in order
+
+	tagValidate = "validate" // `validate:"url"` validates converted value by registered validator
+
+	tagCookie = "cookie" // `cookie:"sid"` binds flag from cookie of HTTP request by Context.BindCookies
+	tagHeader = "header" // `header:"X-Request-Id"` binds flag from header of HTTP request by Context.BindHeaders
+
+	tagPositional = "positional" // `positional:"0"` assigns the 0th free arg to field
+
+	tagGroup         = "group"   // `group:"Authentication"` shows flag under section of usage
+	defaultFlagGroup = "Options" // section of ungrouped flags
 
 	dashOne = "-"
 	dashTwo = "--"
 
+	negatablePrefix = "--no-" // `--no-<name>` sets boolean flag `<name>` to false
+
 	sepName = ", "
 
 	defaultSepForKeyValueOfMap = "="
+	pairSep                    = "," // separates inline key/value pairs of map
 )
 
 type tagProperty struct {
@@ -43,8 +89,28 @@ type tagProperty struct {
 	dft           string            `dft:"default value or expression"`
 	name          string            `name:"tag reference name"`
 	prompt        string            `prompt:"prompt string"`
-	sep           string            `sep:"string for seperate kay/value pair of map"`
+	sep           string            `sep:"string for seperate kay/value pair of map, or elements of slice"`
 	parserCreator FlagParserCreator `parser:"parser for flag"`
+	envs          []string          `env:"comma-separated environment variables"`
+	choices       []string          `choices:"a|b|c"`
+	isChoicesCI   bool              `choices_ci:"true"`
+	mutex         string            `mutex:"name of mutually exclusive group"`
+	isRequiredOne bool              `mutex_required:"true"`
+	isCount       bool              `count:"true"`
+	isFromFile    bool              `fromfile:"true"`
+	isHidden      bool              `hidden:"true"`
+	isPersistent  bool              `persistent:"true"`
+	isSecret      bool              `secret:"true"`
+	group         string            `group:"section of usage"`
+	deprecated    string            `deprecated:"guidance for deprecated flag"`
+	min           *float64          `min:"minimum value"`
+	max           *float64          `max:"maximum value"`
+	normalizers   []NormalizerFunc  `normalize:"comma-separated normalizers"`
+	validator     FlagValidatorFunc `validate:"name of validator"`
+	timeFormat    string            `format:"layout of time"`
+	cookie        string            `cookie:"name of cookie"`
+	header        string            `header:"name of header"`
+	position      *int              `positional:"index of free args"`
 
 	// flag names
 	shortNames []string
@@ -111,9 +177,121 @@ func parseTag(fieldName string, structTag reflect.StructTag) (p *tagProperty, is
 	}
 
 	// `sep` TAG
-	p.sep = defaultSepForKeyValueOfMap
-	if sep := tag.Get(tagSep); sep != "" {
-		p.sep = sep
+	p.sep = tag.Get(tagSep)
+
+	// `required` TAG
+	if err = parseBoolTag(&tag, tagRequired, fieldName, &p.isRequired); err != nil {
+		return
+	}
+
+	// `choices` and `choices_ci` TAG
+	if choices := tag.Get(tagChoices); choices != "" {
+		p.choices = strings.Split(choices, "|")
+	}
+	if err = parseBoolTag(&tag, tagChoicesCI, fieldName, &p.isChoicesCI); err != nil {
+		return
+	}
+
+	// `mutex` and `mutex_required` TAG
+	p.mutex = strings.TrimSpace(tag.Get(tagMutex))
+	if err = parseBoolTag(&tag, tagMutexRequired, fieldName, &p.isRequiredOne); err != nil {
+		return
+	}
+	if p.isRequiredOne && p.mutex == "" {
+		err = fmt.Errorf("field %s: mutex_required used without mutex", fieldName)
+		return
+	}
+
+	// `count` TAG
+	if err = parseBoolTag(&tag, tagCount, fieldName, &p.isCount); err != nil {
+		return
+	}
+
+	// `fromfile` TAG
+	if err = parseBoolTag(&tag, tagFromFile, fieldName, &p.isFromFile); err != nil {
+		return
+	}
+
+	// `hidden` TAG
+	if err = parseBoolTag(&tag, tagHidden, fieldName, &p.isHidden); err != nil {
+		return
+	}
+
+	// `persistent` TAG
+	if err = parseBoolTag(&tag, tagPersistent, fieldName, &p.isPersistent); err != nil {
+		return
+	}
+
+	// `secret` TAG
+	if err = parseBoolTag(&tag, tagSecret, fieldName, &p.isSecret); err != nil {
+		return
+	}
+
+	// `deprecated` TAG
+	p.deprecated = strings.TrimSpace(tag.Get(tagDeprecated))
+
+	// `min` and `max` TAG
+	if p.min, err = parseNumberTag(&tag, tagMin, fieldName); err != nil {
+		return
+	}
+	if p.max, err = parseNumberTag(&tag, tagMax, fieldName); err != nil {
+		return
+	}
+	if p.min != nil && p.max != nil && *p.min > *p.max {
+		err = fmt.Errorf("field %s: min %v greater than max %v", fieldName, *p.min, *p.max)
+		return
+	}
+
+	// `format` TAG
+	p.timeFormat = tag.Get(tagFormat)
+
+	// `normalize` TAG
+	if normalize := tag.Get(tagNormalize); normalize != "" {
+		for _, name := range strings.Split(normalize, ",") {
+			name = strings.TrimSpace(name)
+			fn, ok := normalizers[name]
+			if !ok {
+				err = fmt.Errorf("field %s: unknown normalizer %s", fieldName, name)
+				return
+			}
+			p.normalizers = append(p.normalizers, fn)
+		}
+	}
+
+	// `validate` TAG
+	if name := strings.TrimSpace(tag.Get(tagValidate)); name != "" {
+		if p.validator, err = lookupFlagValidator(name); err != nil {
+			err = fmt.Errorf("field %s: %v", fieldName, err)
+			return
+		}
+	}
+
+	// `cookie` TAG
+	p.cookie = strings.TrimSpace(tag.Get(tagCookie))
+
+	// `header` TAG
+	p.header = strings.TrimSpace(tag.Get(tagHeader))
+
+	// `positional` TAG
+	if positional := strings.TrimSpace(tag.Get(tagPositional)); positional != "" {
+		index, e := strconv.Atoi(positional)
+		if e != nil || index < 0 {
+			err = fmt.Errorf("field %s: invalid positional index %s", fieldName, positional)
+			return
+		}
+		p.position = &index
+	}
+
+	// `group` TAG
+	p.group = strings.TrimSpace(tag.Get(tagGroup))
+
+	// `env` TAG
+	if env := tag.Get(tagEnv); env != "" {
+		for _, name := range strings.Split(env, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				p.envs = append(p.envs, name)
+			}
+		}
 	}
 
 	cli = strings.TrimSpace(cli)
@@ -129,6 +307,12 @@ func parseTag(fieldName string, structTag reflect.StructTag) (p *tagProperty, is
 		}
 	}
 
+	// a required flag with default value never missing
+	if p.isRequired && p.dft != "" {
+		err = fmt.Errorf("field %s: %v", fieldName, errRequiredWithDefault)
+		return
+	}
+
 	names := strings.Split(cli, ",")
 	isEmpty = true
 	for _, name := range names {
@@ -145,7 +329,86 @@ func parseTag(fieldName string, structTag reflect.StructTag) (p *tagProperty, is
 		isEmpty = false
 	}
 	if isEmpty {
-		p.longNames = append(p.longNames, dashTwo+fieldName)
+		p.longNames = append(p.longNames, dashTwo+FieldNameConvention.flagName(fieldName))
 	}
 	return
 }
+
+// NameConvention converts name of field to long flag name if the field has
+// no names in tag `cli`
+type NameConvention int
+
+// Conventions of flag names derived from field names
+const (
+	NameAsIs      NameConvention = iota // `MaxRetries` to `--MaxRetries`
+	NameKebabCase                       // `MaxRetries` to `--max-retries`
+	NameSnakeCase                       // `MaxRetries` to `--max_retries`
+)
+
+// FieldNameConvention is used for fields without explicit names. It's
+// NameAsIs by default for compatibility, names of such fields like
+// `--MaxRetries` would break if changed, so kebab-case is opt-in, e.g.
+//
+//	cli.FieldNameConvention = cli.NameKebabCase
+//
+//	type argT struct {
+//		MaxRetries int    // --max-retries
+//		UserID     string // --user-id
+//		Port       int    `cli:"p"` // explicit names override
+//	}
+var FieldNameConvention = NameAsIs
+
+func (c NameConvention) flagName(fieldName string) string {
+	switch c {
+	case NameKebabCase:
+		return splitWords(fieldName, '-')
+	case NameSnakeCase:
+		return splitWords(fieldName, '_')
+	}
+	return fieldName
+}
+
+// splitWords converts camel case name to lower case words joined by sep,
+// acronyms are kept as a word, e.g. "HTTPPort" to "http-port"
+func splitWords(name string, sep rune) string {
+	runes := []rune(name)
+	buf := make([]rune, 0, len(runes)+4)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				buf = append(buf, sep)
+			}
+		}
+		buf = append(buf, unicode.ToLower(r))
+	}
+	return string(buf)
+}
+
+// parseBoolTag parses boolean tag, e.g. `required:"true"`
+func parseBoolTag(tag *multiTag, key, fieldName string, ptr *bool) error {
+	value := tag.Get(key)
+	if value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("tag %s of field %s invalid: %v", key, fieldName, err)
+	}
+	*ptr = b
+	return nil
+}
+
+// parseNumberTag parses number tag, e.g. `min:"1"`, nil returned if tag absent
+func parseNumberTag(tag *multiTag, key, fieldName string) (*float64, error) {
+	value := tag.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("tag %s of field %s invalid: %v", key, fieldName, err)
+	}
+	return &f, nil
+}
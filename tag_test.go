@@ -18,11 +18,13 @@ func TestTag(t *testing.T) {
 		Prompt string `cli:"prompt" prompt:"hello,prompt"`
 		Parser string `cli:"parser" parser:"json"`
 		Sep    string `cli:"sep" sep:":"`
+		Env    string `cli:"env" env:"CLI_ENV1, CLI_ENV2"`
 		Multi  string `cli:"multi" 
 			usage:"multi usage"
 			dft:"dft-value"`
 
 		Required     string `cli:"*r"`
+		Required2    string `cli:"r2" required:"true"`
 		Force        string `cli:"!f"`
 		EditFile     string `edit:"Filename:file"`
 		ShortAndLong string `cli:"x,y,z,xy,yz,xyz"`
@@ -109,11 +111,17 @@ func TestTag(t *testing.T) {
 			assert.False(t, tag.isEdit)
 			assert.Equal(t, tag.longNames, []string{"--sep"})
 			assert.Equal(t, tag.sep, ":")
+		case "Env":
+			assert.Equal(t, tag.longNames, []string{"--env"})
+			assert.Equal(t, tag.envs, []string{"CLI_ENV1", "CLI_ENV2"})
 		case "Required":
 			assert.True(t, tag.isRequired)
 			assert.False(t, tag.isForce)
 			assert.False(t, tag.isPassword)
 			assert.False(t, tag.isEdit)
+		case "Required2":
+			assert.True(t, tag.isRequired)
+			assert.Equal(t, tag.longNames, []string{"--r2"})
 		case "Force":
 			assert.False(t, tag.isRequired)
 			assert.True(t, tag.isForce)
@@ -142,3 +150,37 @@ func TestTag(t *testing.T) {
 		}
 	}
 }
+
+func TestFieldNameConvention(t *testing.T) {
+	type argT struct {
+		MaxRetries int    `usage:"max retries"`
+		UserID     string `dft:"u"`
+		HTTPPort   int
+		Port       int  `cli:"p,port"`
+		V2Enabled  bool `cli:"*"`
+	}
+	defer func(c NameConvention) { FieldNameConvention = c }(FieldNameConvention)
+	for i, tt := range []struct {
+		convention NameConvention
+		names      []string
+	}{
+		{NameAsIs, []string{"--MaxRetries", "--UserID", "--HTTPPort", "--port", "--V2Enabled"}},
+		{NameKebabCase, []string{"--max-retries", "--user-id", "--http-port", "--port", "--v2-enabled"}},
+		{NameSnakeCase, []string{"--max_retries", "--user_id", "--http_port", "--port", "--v2_enabled"}},
+	} {
+		FieldNameConvention = tt.convention
+		typ := reflect.TypeOf(argT{})
+		for j := 0; j < typ.NumField(); j++ {
+			field := typ.Field(j)
+			tag, _, err := parseTag(field.Name, field.Tag)
+			if assert.Nil(t, err, "case %d", i) {
+				assert.Equal(t, tt.names[j], tag.longNames[0], "case %d: %s", i, field.Name)
+			}
+		}
+	}
+
+	FieldNameConvention = NameKebabCase
+	argv := new(argT)
+	assert.Nil(t, Parse([]string{"--max-retries", "3", "-p", "80", "--v2-enabled"}, argv))
+	assert.Equal(t, argT{MaxRetries: 3, UserID: "u", Port: 80, V2Enabled: true}, *argv)
+}
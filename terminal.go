@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/Bowery/prompt"
+	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
+)
+
+// stdoutWriter is default writer of contexts, it's colorable stdout which
+// isn't an *os.File on Windows, so stdout is kept for terminalFile
+type stdoutWriter struct {
+	io.Writer
+	file *os.File
+}
+
+func newStdoutWriter() io.Writer {
+	return &stdoutWriter{Writer: colorable.NewColorableStdout(), file: os.Stdout}
+}
+
+// IsTTY reports whether writer of ctx is a terminal
+func (ctx *Context) IsTTY() bool {
+	return isTerminalWriter(ctx.baseWriter())
+}
+
+// isTerminalWriter reports whether w is a terminal
+func isTerminalWriter(w io.Writer) bool {
+	return terminalFile(w) != nil
+}
+
+// terminalFile returns file of w if w is a terminal, or nil
+func terminalFile(w io.Writer) *os.File {
+	f := writerFile(w)
+	if f != nil && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())) {
+		return f
+	}
+	return nil
+}
+
+// writerFile returns file of w if w is a file or stdout, or nil
+func writerFile(w io.Writer) *os.File {
+	switch w := w.(type) {
+	case *os.File:
+		return w
+	case *stdoutWriter:
+		return w.file
+	}
+	return nil
+}
+
+// TerminalSize returns columns and rows of terminal of writer. Environment
+// variables COLUMNS and LINES are used if writer isn't a terminal or size
+// of the terminal unknown, and 80x24 if they are unset too. The error
+// reading size of terminal is returned along with defaults.
+func (ctx *Context) TerminalSize() (cols, rows int, err error) {
+	return terminalSize(terminalFile(ctx.baseWriter()))
+}
+
+// terminalSize returns size of terminal f, or size by environment variables
+// COLUMNS and LINES if f is nil or size of f unknown, see Context.TerminalSize
+func terminalSize(f *os.File) (cols, rows int, err error) {
+	if f != nil {
+		if cols, rows, err = prompt.TerminalSize(f); err == nil && cols > 0 && rows > 0 {
+			return cols, rows, nil
+		}
+	}
+	cols, colsOK := envSize("COLUMNS")
+	rows, rowsOK := envSize("LINES")
+	if !colsOK {
+		cols = defaultTerminalWidth
+	}
+	if !rowsOK {
+		rows = defaultTerminalHeight
+	}
+	if colsOK || rowsOK {
+		err = nil
+	}
+	return cols, rows, err
+}
+
+// envSize reads positive size from environment variable
+func envSize(name string) (int, bool) {
+	size, err := strconv.Atoi(os.Getenv(name))
+	return size, err == nil && size > 0
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextTerminalSize(t *testing.T) {
+	r, w, err := os.Pipe()
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer r.Close()
+	defer w.Close()
+	ctx := &Context{writer: w}
+	assert.False(t, ctx.IsTTY())
+	assert.False(t, (&Context{writer: bytes.NewBufferString("")}).IsTTY())
+
+	oldCols, oldLines := os.Getenv("COLUMNS"), os.Getenv("LINES")
+	defer func() {
+		os.Setenv("COLUMNS", oldCols)
+		os.Setenv("LINES", oldLines)
+	}()
+	for i, tt := range []struct {
+		cols, lines    string
+		wantCols, rows int
+	}{
+		{cols: "120", lines: "40", wantCols: 120, rows: 40},
+		{cols: "100", lines: "", wantCols: 100, rows: defaultTerminalHeight},
+		{cols: "x", lines: "-1", wantCols: defaultTerminalWidth, rows: defaultTerminalHeight},
+		{cols: "", lines: "", wantCols: defaultTerminalWidth, rows: defaultTerminalHeight},
+	} {
+		os.Setenv("COLUMNS", tt.cols)
+		os.Setenv("LINES", tt.lines)
+		cols, rows, err := ctx.TerminalSize()
+		assert.Nil(t, err, "case %d", i)
+		assert.Equal(t, tt.wantCols, cols, "case %d", i)
+		assert.Equal(t, tt.rows, rows, "case %d", i)
+	}
+}
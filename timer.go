@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"time"
+)
+
+// timeNow returns current time, it's replaced in tests
+var timeNow = time.Now
+
+// StartTimer starts timer named label, timers could be nested or
+// overlapped. Starting a running timer restarts it.
+func (ctx *Context) StartTimer(label string) {
+	ctx.timerMu.Lock()
+	defer ctx.timerMu.Unlock()
+	if ctx.timerStarts == nil {
+		ctx.timerStarts = make(map[string]time.Time)
+	}
+	if _, ok := ctx.timings[label]; !ok {
+		if _, ok := ctx.timerStarts[label]; !ok {
+			ctx.timerLabels = append(ctx.timerLabels, label)
+		}
+	}
+	ctx.timerStarts[label] = timeNow()
+}
+
+// StopTimer stops timer named label and returns elapsed time since it
+// started, 0 returned if the timer isn't running. Elapsed time of a timer
+// started and stopped several times is accumulated in Timings.
+func (ctx *Context) StopTimer(label string) time.Duration {
+	ctx.timerMu.Lock()
+	defer ctx.timerMu.Unlock()
+	start, ok := ctx.timerStarts[label]
+	if !ok {
+		return 0
+	}
+	delete(ctx.timerStarts, label)
+	elapsed := timeNow().Sub(start)
+	if ctx.timings == nil {
+		ctx.timings = make(map[string]time.Duration)
+	}
+	ctx.timings[label] += elapsed
+	return elapsed
+}
+
+// Timings returns accumulated elapsed time of stopped timers by labels
+func (ctx *Context) Timings() map[string]time.Duration {
+	ctx.timerMu.Lock()
+	defer ctx.timerMu.Unlock()
+	timings := make(map[string]time.Duration, len(ctx.timings))
+	for label, d := range ctx.timings {
+		timings[label] = d
+	}
+	return timings
+}
+
+// logTimings logs timings at LevelDebug in order of timers started,
+// it's called after command finished
+func (ctx *Context) logTimings() {
+	timings := ctx.Timings()
+	ctx.timerMu.Lock()
+	labels := append([]string{}, ctx.timerLabels...)
+	ctx.timerMu.Unlock()
+	for _, label := range labels {
+		if d, ok := timings[label]; ok {
+			ctx.Logf(LevelDebug, "timer %s: %v", label, d)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/labstack/gommon/color"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTimeNow replaces timeNow by a clock advanced manually, it returns
+// the advance func and the restore func
+func fakeTimeNow() (func(time.Duration), func()) {
+	now := time.Unix(0, 0)
+	old := timeNow
+	timeNow = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }, func() { timeNow = old }
+}
+
+func TestContextTimers(t *testing.T) {
+	advance, restore := fakeTimeNow()
+	defer restore()
+	ctx := &Context{}
+
+	// nested and overlapped
+	ctx.StartTimer("total")
+	advance(time.Second)
+	ctx.StartTimer("load")
+	advance(2 * time.Second)
+	ctx.StartTimer("parse")
+	advance(3 * time.Second)
+	assert.Equal(t, 5*time.Second, ctx.StopTimer("load"))
+	advance(4 * time.Second)
+	assert.Equal(t, 7*time.Second, ctx.StopTimer("parse"))
+	assert.Equal(t, 10*time.Second, ctx.StopTimer("total"))
+	assert.Equal(t, map[string]time.Duration{
+		"total": 10 * time.Second,
+		"load":  5 * time.Second,
+		"parse": 7 * time.Second,
+	}, ctx.Timings())
+
+	// accumulated, stopping a stopped or unknown timer returns 0
+	ctx.StartTimer("load")
+	advance(time.Second)
+	assert.Equal(t, time.Second, ctx.StopTimer("load"))
+	assert.Equal(t, time.Duration(0), ctx.StopTimer("load"))
+	assert.Equal(t, time.Duration(0), ctx.StopTimer("unknown"))
+	assert.Equal(t, 6*time.Second, ctx.Timings()["load"])
+
+	// running timers excluded, Timings returns a copy
+	ctx.StartTimer("running")
+	timings := ctx.Timings()
+	_, ok := timings["running"]
+	assert.False(t, ok)
+	timings["total"] = 0
+	assert.Equal(t, 10*time.Second, ctx.Timings()["total"])
+}
+
+func TestContextTimingsSummary(t *testing.T) {
+	advance, restore := fakeTimeNow()
+	defer restore()
+	clr := color.New()
+	clr.Disable()
+	for i, tc := range []struct {
+		level  Level
+		stderr string
+	}{
+		{LevelInfo, ""},
+		{LevelDebug, "[DEBUG] timer query: 1.5s\n[DEBUG] timer render: 20ms\n"},
+	} {
+		var stdout, stderr bytes.Buffer
+		level := tc.level
+		root := &Command{
+			Name: "app",
+			Fn: func(ctx *Context) error {
+				ctx.color = *clr
+				ctx.SetLogLevel(level)
+				ctx.StartTimer("query")
+				advance(1500 * time.Millisecond)
+				ctx.StopTimer("query")
+				ctx.StartTimer("render")
+				advance(20 * time.Millisecond)
+				ctx.StopTimer("render")
+				ctx.StartTimer("unstopped")
+				return nil
+			},
+		}
+		assert.Nil(t, root.RunWithOptions(nil, WithStdout(&stdout), WithStderr(&stderr)), "case %d", i)
+		assert.Equal(t, tc.stderr, stderr.String(), "case %d", i)
+	}
+}